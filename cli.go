@@ -0,0 +1,524 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"configurator/config"
+	"configurator/internal/budget"
+	"configurator/internal/ledger"
+	"configurator/internal/logger"
+	"configurator/internal/models"
+)
+
+const usage = `Usage: configurator [flags]
+       configurator <command> [flags]
+
+Without a command the configurator runs the scheduler.
+
+Commands:
+  plan      Show over-budget workloads and the resulting Promtail config without writing it
+  apply     Run one enforcement cycle now
+  reset     Remove all sampling and drop stages added by the configurator
+  history   Show enforcement runs recorded in the history ledger
+  budget    Work with the budget file, see 'configurator budget help'
+
+Run 'configurator <command> -h' for the flags of a command.
+`
+
+// runCommand executes a one-off CLI command and exits with its status
+func runCommand(name string, args []string) {
+	switch name {
+	case "plan":
+		os.Exit(planCommand(args))
+	case "apply":
+		os.Exit(applyCommand(args))
+	case "reset":
+		os.Exit(resetCommand(args))
+	case "history":
+		os.Exit(historyCommand(args))
+	case "budget":
+		os.Exit(budgetCommand(args))
+	case "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
+}
+
+// initCommand loads the configuration for a CLI command.
+// Logs go to stderr so stdout only holds the command output, including the config diff in dry-run mode.
+func initCommand() {
+	initConfig()
+	logger.InitLoggerWithOutput(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	diffOutput = os.Stdout
+}
+
+// initCommandClients initializes the clients needed to evaluate and enforce budgets
+func initCommandClients() {
+	initKubernetes()
+	initBudget()
+	initMetrics()
+	loadSchedulerLocation()
+}
+
+// planCommand computes the over-budget workloads and the resulting Promtail config without writing it
+func planCommand(args []string) int {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	timeRange := fs.String("time-range", "", "PromQL range to measure ingestion over (default: since the last budget reset)")
+	showConfig := fs.Bool("show-config", false, "Print the full resulting Promtail config instead of the unified diff")
+	targetName := fs.String("target", "", "Only plan the target with this name (default: all targets)")
+	_ = fs.Parse(args)
+
+	initCommand()
+	targets, err := selectTargets(*targetName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	initCommandClients()
+	// plan prints the diff itself, regardless of dry-run mode
+	diffOutput = nil
+
+	run := newRun("plan")
+	run.TimeRange = *timeRange
+	if run.TimeRange == "" {
+		run.TimeRange = budgetDayRange(run.StartedAt)
+	}
+
+	fmt.Fprintf(os.Stdout, "Time range: %s\n", run.TimeRange)
+
+	status := 0
+	for _, t := range targets {
+		fmt.Fprintf(os.Stdout, "\nTarget: %s (cluster %s)\n\n", t.Name, t.Cluster)
+		if err := planTarget(run, t, *showConfig); err != nil {
+			log.Error().Err(err).Str("target", t.Name).Msg("Failed to plan target")
+			fmt.Fprintf(os.Stdout, "failed: %v\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// planTarget prints the over-budget workloads of a target and the changes to its Promtail config
+func planTarget(run *models.EnforcementRun, t config.Target, showConfig bool) error {
+	// plan leaves expired exemptions in the ConfigMap for the next run to remove
+	exemptions, err := activeExemptions(t, run.StartedAt, false)
+	if err != nil {
+		return err
+	}
+
+	logBudgets, err := fetchLogBudgets(t)
+	if err != nil {
+		return err
+	}
+
+	overBudgetWorkloads, _, err := evaluateBudgets(run, t, logBudgets, exemptions)
+	if err != nil {
+		return err
+	}
+
+	promtailConfig, err := getPromtailConfig(t)
+	if err != nil {
+		return err
+	}
+
+	samplingRates, err := planSamplingRates(t, promtailConfig, overBudgetWorkloads, exemptions)
+	if err != nil {
+		return fmt.Errorf("failed to plan sampling rates: %w", err)
+	}
+
+	update, err := renderSamplingConfig(t, promtailConfig, samplingRates, false)
+	if err != nil {
+		return fmt.Errorf("failed to render Promtail config: %w", err)
+	}
+
+	printPlanTable(overBudgetWorkloads, samplingRates)
+	printExemptions(run.Workloads, t, exemptions)
+	fmt.Fprintf(os.Stdout, "\nPipeline stage changes:\n%s\n", update.diff.Summary())
+	if showConfig {
+		fmt.Fprintf(os.Stdout, "\nResulting Promtail config:\n%s", update.yamlContent)
+	} else if !update.diff.IsEmpty() {
+		fmt.Fprintf(os.Stdout, "\n%s", update.diff.Unified)
+	}
+	return nil
+}
+
+// applyCommand runs one enforcement cycle now, the same as a scheduled ingestion check
+func applyCommand(args []string) int {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	timeRange := fs.String("time-range", "", "PromQL range to measure ingestion over (default: since the last budget reset)")
+	dryRun := fs.Bool("dry-run", false, "Do not update the Promtail secret, overrides dry_run in config.yaml")
+	targetName := fs.String("target", "", "Only enforce the target with this name (default: all targets)")
+	_ = fs.Parse(args)
+
+	initCommand()
+	if *dryRun {
+		cfg.DryRun = true
+	}
+	targets, err := selectTargets(*targetName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	initCommandClients()
+	initLedger()
+	initNotifier()
+
+	run := newRun(taskApply)
+	run.TimeRange = *timeRange
+	if run.TimeRange == "" {
+		run.TimeRange = budgetDayRange(run.StartedAt)
+	}
+
+	enforceBudgets(run, targets)
+
+	if !run.Success {
+		return 1
+	}
+	return 0
+}
+
+// resetCommand removes all sampling and drop stages added by the configurator and writes the result
+func resetCommand(args []string) int {
+	fs := flag.NewFlagSet("reset", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Do not update the Promtail secret, overrides dry_run in config.yaml")
+	targetName := fs.String("target", "", "Only reset the target with this name (default: all targets)")
+	_ = fs.Parse(args)
+
+	initCommand()
+	if *dryRun {
+		cfg.DryRun = true
+	}
+	targets, err := selectTargets(*targetName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	initKubernetes()
+	loadSchedulerLocation()
+	initLedger()
+	initNotifier()
+
+	run := newRun(taskReset)
+
+	// Removes all sampling and drop stages before writing the config
+	err = forEachTarget(run, targets, func(t config.Target) error {
+		return resetTarget(run, t, true)
+	})
+	finishRun(run, err)
+	if err != nil {
+		log.Error().Err(err).Msg("Reset failed")
+		return 1
+	}
+
+	log.Info().
+		Bool("dry_run", cfg.DryRun).
+		Msg("Reset completed successfully")
+	return 0
+}
+
+// printPlanTable prints the over-budget workloads and every workload that will be sampled
+func printPlanTable(overBudgetWorkloads []models.OverBudgetWorkload, samplingRates map[string]float64) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	overBudget := make(map[string]models.OverBudgetWorkload, len(overBudgetWorkloads))
+	for _, o := range overBudgetWorkloads {
+		overBudget[o.Workload] = o
+	}
+
+	workloads := make([]string, 0, len(samplingRates))
+	for workload := range samplingRates {
+		workloads = append(workloads, workload)
+	}
+	sort.Strings(workloads)
+
+	fmt.Fprintln(w, "WORKLOAD\tINGESTION_GB\tBUDGET_GB\tSAMPLING_%\tREASON")
+	for _, workload := range workloads {
+		if o, ok := overBudget[workload]; ok && o.ProjectedIngestion > 0 {
+			fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\tprojected over budget (%.2f GB by end of day)\n",
+				workload, float64(o.CurrentIngestion), float64(o.Budget), samplingRates[workload], float64(o.ProjectedIngestion))
+		} else if ok {
+			fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\tover budget\n",
+				workload, float64(o.CurrentIngestion), float64(o.Budget), samplingRates[workload])
+		} else {
+			fmt.Fprintf(w, "%s\t-\t-\t%.2f\talready sampled\n", workload, samplingRates[workload])
+		}
+	}
+}
+
+// printExemptions prints the over-budget workloads of a target which are not sampled because of an exemption
+func printExemptions(statuses []models.WorkloadStatus, t config.Target, exemptions map[string]budget.Exemption) {
+	var exempt []models.WorkloadStatus
+	for _, s := range statuses {
+		if s.Target == t.Name && s.Exempt && s.OverBudget {
+			exempt = append(exempt, s)
+		}
+	}
+	if len(exempt) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stdout, "\nExempt from sampling:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "WORKLOAD\tINGESTION_GB\tBUDGET_GB\tOWNER\tREASON\tEXPIRES")
+	for _, s := range exempt {
+		e := exemptions[s.Workload]
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%s\t%s\t%s\n",
+			s.Workload, float64(s.CurrentIngestion), float64(s.DynamicBudget), e.Owner, e.Reason, e.ExpiresAt.Format(time.RFC3339))
+	}
+}
+
+// historyCommand prints the runs recorded in the history ledger over the last N days
+func historyCommand(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	days := fs.Int("days", 7, "Number of days of history to show")
+	workload := fs.String("workload", "", "Only show runs in which this workload was over budget or sampled")
+	output := fs.String("output", "table", "Output format: table or json")
+	_ = fs.Parse(args)
+
+	initCommand()
+
+	if *days <= 0 {
+		fmt.Fprintln(os.Stderr, "-days must be greater than 0")
+		return 2
+	}
+
+	if cfg.History.Backend == "configmap" {
+		initKubernetes()
+	}
+	l, err := newLedger()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open enforcement history ledger")
+		return 1
+	}
+
+	runs, err := l.Query(time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query enforcement history")
+		return 1
+	}
+	if *workload != "" {
+		runs = ledger.FilterWorkload(runs, *workload)
+	}
+
+	if runs == nil {
+		runs = []models.EnforcementRun{}
+	}
+
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(runs); err != nil {
+			log.Error().Err(err).Msg("Failed to encode enforcement history")
+			return 1
+		}
+	case "table":
+		printHistoryTable(runs)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		return 2
+	}
+	return 0
+}
+
+const budgetUsage = `Usage: configurator budget <command> [flags]
+
+Commands:
+  lint       Check the budget file for misspelled keys, invalid budgets, duplicates and missing org/env pairs
+  recommend  Recommend budgets from the daily ingestion of the last days as a budget.yaml fragment
+`
+
+// budgetCommand runs a budget subcommand
+func budgetCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, budgetUsage)
+		return 2
+	}
+	switch args[0] {
+	case "lint":
+		return lintCommand(args[1:])
+	case "recommend":
+		return recommendCommand(args[1:])
+	case "help":
+		fmt.Fprint(os.Stdout, budgetUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown budget command %q\n\n%s", args[0], budgetUsage)
+		return 2
+	}
+}
+
+// lintCommand checks a budget file and prints its problems as path:line:column: message.
+// Without a file argument the budget file and the org/env pairs of config.yaml are checked,
+// with one it runs without config.yaml, e.g. in the CI of the budget repository.
+func lintCommand(args []string) int {
+	fs := flag.NewFlagSet("budget lint", flag.ExitOnError)
+	var required []budget.OrgEnv
+	fs.Func("require", "An org/env pair that must be defined, e.g. invest/prod (repeatable)", func(v string) error {
+		org, env, ok := strings.Cut(v, "/")
+		if !ok || org == "" || env == "" {
+			return fmt.Errorf("expected org/env, got %q", v)
+		}
+		required = append(required, budget.OrgEnv{Org: org, Env: env})
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: configurator budget lint [flags] [budget.yaml]\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	path := fs.Arg(0)
+	if path == "" {
+		initCommand()
+		path = cfg.Budget.ConfigPath
+		required = append(required, targetOrgEnvs(cfg.Targets)...)
+	}
+
+	problems, err := budget.Lint(path, required)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, p := range problems {
+		if p.Line == 0 {
+			fmt.Fprintf(os.Stdout, "%s: %s\n", path, p)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%s:%s\n", path, p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problems found\n", len(problems))
+		return 1
+	}
+	return 0
+}
+
+// recommendCommand recommends budgets for the workloads of every target from their historical ingestion
+// and prints them as a budget.yaml fragment, a diff against the current budgets or JSON
+func recommendCommand(args []string) int {
+	fs := flag.NewFlagSet("budget recommend", flag.ExitOnError)
+	defaults := budget.DefaultRecommendOptions
+	days := fs.Int("days", defaults.Days, "Number of whole days of ingestion to recommend from")
+	basis := fs.String("basis", defaults.Basis, "Daily ingestion statistic to recommend: p50, p95 or max")
+	headroom := fs.Float64("headroom", defaults.Headroom, "Share added on top of the basis, e.g. 0.2 for 20%")
+	output := fs.String("output", "yaml", "Output format: yaml, diff or json")
+	targetName := fs.String("target", "", "Only recommend budgets for the target with this name (default: all targets)")
+	_ = fs.Parse(args)
+
+	o := budget.RecommendOptions{Days: *days, Basis: *basis, Headroom: *headroom}
+	if err := o.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *output != "yaml" && *output != "diff" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		return 2
+	}
+
+	initCommand()
+	targets, err := selectTargets(*targetName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	initBudget()
+	initMetrics()
+	loadSchedulerLocation()
+
+	now := time.Now()
+	if schedulerLocation != nil {
+		now = now.In(schedulerLocation)
+	}
+
+	reports := make([]budget.RecommendationReport, 0, len(targets))
+	status := 0
+	for _, t := range targets {
+		report, err := recommendBudgets(context.Background(), t, budgetConfig, metricsClient, o, now)
+		if err != nil {
+			log.Error().Err(err).Str("target", t.Name).Msg("Failed to recommend budgets")
+			status = 1
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			log.Error().Err(err).Msg("Failed to encode budget recommendations")
+			return 1
+		}
+	case "diff":
+		for _, r := range reports {
+			if r.Diff == "" {
+				fmt.Fprintf(os.Stdout, "# target %s: no changes\n", r.Target)
+				continue
+			}
+			fmt.Fprintf(os.Stdout, "# target %s\n%s", r.Target, r.Diff)
+		}
+	default:
+		for _, r := range reports {
+			fmt.Fprintf(os.Stdout, "# target %s: %s of the last %d days plus %g%% headroom\n%s",
+				r.Target, o.Basis, o.Days, o.Headroom*100, r.Fragment)
+		}
+	}
+	return status
+}
+
+// printHistoryTable prints one line per workload and run
+func printHistoryTable(runs []models.EnforcementRun) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "STARTED\tRUN\tOUTCOME\tTARGET\tWORKLOAD\tINGESTION_GB\tBUDGET_GB\tSAMPLING_%")
+	for _, run := range runs {
+		outcome := "success"
+		if !run.Success {
+			outcome = "failure"
+		}
+		if run.DryRun {
+			outcome += " (dry-run)"
+		}
+		started := run.StartedAt.Format(time.RFC3339)
+
+		if len(run.Workloads) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\t-\t-\n", started, run.ID, outcome)
+			continue
+		}
+		for _, wl := range run.Workloads {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%.2f\t%.2f\t%.2f\n",
+				started,
+				run.ID,
+				outcome,
+				wl.Target,
+				wl.Workload,
+				float64(wl.CurrentIngestion),
+				float64(wl.DynamicBudget),
+				wl.SamplingPercentage,
+			)
+		}
+	}
+}
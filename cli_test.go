@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stesting "k8s.io/client-go/testing"
+
+	"configurator/config"
+)
+
+func TestPlanTarget(t *testing.T) {
+	clientset := setupEnforcement(t, testBudget, newFakeMetrics(map[string]float64{"api": 20, "web": 5}))
+
+	// An expired exemption in the ConfigMap is left for the next run to remove
+	cfg.Exemptions.ConfigMap = config.ConfigMapRef{Namespace: "promtail", Name: "exemptions"}
+	if err := clientset.Tracker().Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "promtail", Name: "exemptions"},
+		Data: map[string]string{exemptionsKey: `
+- workload: web
+  owner: alice
+  reason: INC-1
+  expires_at: 2025-03-01T12:00:00Z
+`},
+	}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	run := newRun("plan")
+	run.TimeRange = "1h"
+	if err := planTarget(run, cfg.Targets[0], false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if writes := writeActions(clientset); len(writes) != 0 {
+		t.Fatalf("expected plan not to write, got %+v", writes)
+	}
+	if sampled := sampledWorkloads(t, clientset); len(sampled) != 1 || sampled["old"] != 50 {
+		t.Errorf("expected only old to be sampled, got %v", sampled)
+	}
+	if len(run.Workloads) != 2 {
+		t.Errorf("expected 2 evaluated workloads, got %+v", run.Workloads)
+	}
+}
+
+func TestApply(t *testing.T) {
+	clientset := setupEnforcement(t, testBudget, newFakeMetrics(map[string]float64{"api": 20, "web": 5}))
+
+	run := newRun(taskApply)
+	run.TimeRange = "1h"
+	enforceBudgets(run, cfg.Targets)
+
+	if !run.Success {
+		t.Fatalf("expected a successful run, got error %q", run.Error)
+	}
+	sampled := sampledWorkloads(t, clientset)
+	if len(sampled) != 2 || sampled["old"] != 50 {
+		t.Fatalf("expected api to be sampled and old to be kept, got %v", sampled)
+	}
+	if sampled["api"] != 50 {
+		t.Errorf("expected api sampled to 50%%, got %v", sampled["api"])
+	}
+
+	w, ok := enforcementState.TargetWorkload("cluster-001", "api")
+	if !ok || !w.Sampled || w.SamplingPercentage != 50 {
+		t.Errorf("expected api to be reported as sampled, got %+v", w)
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	clientset := setupEnforcement(t, testBudget, newFakeMetrics(map[string]float64{"api": 20, "web": 5}))
+	cfg.DryRun = true
+	enforcementState.SetSampling("cluster-001", map[string]float64{"old": 50})
+
+	run := newRun(taskApply)
+	run.TimeRange = "1h"
+	enforceBudgets(run, cfg.Targets)
+
+	if !run.Success {
+		t.Fatalf("expected a successful run, got error %q", run.Error)
+	}
+	for _, a := range writeActions(clientset) {
+		update, ok := a.(k8stesting.UpdateActionImpl)
+		if !ok || len(update.GetUpdateOptions().DryRun) == 0 {
+			t.Errorf("expected only dry-run updates, got %+v", a)
+		}
+	}
+
+	// The sampling in Promtail did not change
+	if w, ok := enforcementState.TargetWorkload("cluster-001", "api"); !ok || w.Sampled {
+		t.Errorf("expected api not to be reported as sampled, got %+v", w)
+	}
+}
+
+func TestReset(t *testing.T) {
+	clientset := setupEnforcement(t, testBudget, newFakeMetrics(nil))
+	enforcementState.SetSampling("cluster-001", map[string]float64{"old": 50})
+
+	run := newRun(taskReset)
+	err := forEachTarget(run, cfg.Targets, func(t config.Target) error {
+		return resetTarget(run, t, true)
+	})
+	finishRun(run, err)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sampled := sampledWorkloads(t, clientset); len(sampled) != 0 {
+		t.Errorf("expected no sampling after reset, got %v", sampled)
+	}
+	if last, ok := enforcementState.LastRun(); !ok || last.Task != taskReset || !last.Success {
+		t.Errorf("expected a successful reset run, got %+v", last)
+	}
+	if w, ok := enforcementState.TargetWorkload("cluster-001", "old"); ok && w.Sampled {
+		t.Errorf("expected old not to be reported as sampled, got %+v", w)
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -23,6 +25,39 @@ type Config struct {
 	Mode       string     `koanf:"mode"`
 	KubeConfig string     `koanf:"kube_config"`
 	DryRun     bool       `koanf:"dry_run"`
+
+	LeaderElection LeaderElection `koanf:"leader_election"`
+	History        History        `koanf:"history"`
+	Slack          Slack          `koanf:"slack"`
+	Targets        []Target       `koanf:"targets"`
+	Exemptions     Exemptions     `koanf:"exemptions"`
+	LogBudgets     LogBudgets     `koanf:"log_budgets"`
+
+	WorkloadIdentity WorkloadIdentity `koanf:"workload_identity"`
+}
+
+// WorkloadIdentity configures how workloads are told apart
+type WorkloadIdentity struct {
+	// Namespaced keys workloads by namespace/name, so workloads of the same name in different namespaces
+	// have their own budget and sampling
+	Namespaced bool `koanf:"namespaced"`
+	// Labels are the log labels whose values identify a workload, e.g. [app] or [app, container]
+	Labels []string `koanf:"labels"`
+}
+
+// Target is a cluster and Promtail secret enforced by the configurator.
+// Unset fields default to the top-level cluster, kube_config, promtail and budget settings.
+type Target struct {
+	Name           string `koanf:"name"`
+	Cluster        string `koanf:"cluster"`
+	KubeConfig     string `koanf:"kube_config"`
+	KubeContext    string `koanf:"kube_context"`
+	Secret         Secret `koanf:"secret"`
+	Org            string `koanf:"org"`
+	Env            string `koanf:"env"`
+	SelectorFormat string `koanf:"selector_format"`
+	// WorkloadLabels identify the workloads of the target, workload_identity.labels by default
+	WorkloadLabels []string `koanf:"workload_labels"`
 }
 
 type Promtail struct {
@@ -46,10 +81,76 @@ type Secret struct {
 }
 
 type Metrics struct {
-	MimirEndpoint string            `koanf:"mimir_endpoint"`
-	MimirTenant   string            `koanf:"mimir_tenant"`
-	Names         map[string]string `koanf:"names"`
-	QueryTimeout  time.Duration     `koanf:"query_timeout"`
+	MimirEndpoint string        `koanf:"mimir_endpoint"`
+	MimirTenant   string        `koanf:"mimir_tenant"`
+	Names         MetricNames   `koanf:"names"`
+	QueryTimeout  time.Duration `koanf:"query_timeout"`
+	// IngestionSource measures ingestion with the Promtail counter in mimir or with LogQL queries in loki
+	IngestionSource string      `koanf:"ingestion_source"`
+	Loki            Loki        `koanf:"loki"`
+	Auth            MetricsAuth `koanf:"auth"`
+}
+
+// MetricsAuth authenticates the Mimir and Loki clients with basic auth or a bearer token, and a client certificate.
+// Secrets are read from files or env vars, never from config.yaml.
+type MetricsAuth struct {
+	Basic  BasicAuth  `koanf:"basic"`
+	Bearer BearerAuth `koanf:"bearer"`
+	TLS    TLSAuth    `koanf:"tls"`
+}
+
+// BasicAuth is the username and the file or env var holding the password
+type BasicAuth struct {
+	Username     string `koanf:"username"`
+	PasswordFile string `koanf:"password_file"`
+	PasswordEnv  string `koanf:"password_env"`
+}
+
+// BearerAuth is the file or env var holding the token, the file is read on every request so rotated tokens are picked up
+type BearerAuth struct {
+	TokenFile string `koanf:"token_file"`
+	TokenEnv  string `koanf:"token_env"`
+}
+
+// TLSAuth is the CA verifying Mimir and the client certificate presented for mTLS
+type TLSAuth struct {
+	CAFile   string `koanf:"ca_file"`
+	CertFile string `koanf:"cert_file"`
+	KeyFile  string `koanf:"key_file"`
+}
+
+// Loki is the Loki query API ingestion is measured with if metrics.ingestion_source is loki
+type Loki struct {
+	Endpoint string `koanf:"endpoint"`
+	// Tenant is sent in the X-Scope-OrgID header, metrics.mimir_tenant by default
+	Tenant string `koanf:"tenant"`
+}
+
+// MetricNames are the metrics and labels queried from Mimir, a metric can carry label matchers in braces
+type MetricNames struct {
+	LogBytes      string `koanf:"log_bytes"`
+	CPURequest    string `koanf:"cpu_request"`
+	MemoryRequest string `koanf:"memory_request"`
+	Replicas      string `koanf:"replicas"`
+	// ClusterMatcher is the operator matching the cluster label, = or =~
+	ClusterMatcher string `koanf:"cluster_matcher"`
+	// Labels group the log bytes metric, ResourceLabels the CPU, memory and replica metrics
+	Labels         MetricLabels   `koanf:"labels"`
+	ResourceLabels ResourceLabels `koanf:"resource_labels"`
+}
+
+// MetricLabels are the labels of the cluster and namespace of a series, the log bytes metric
+// carries the workload labels of the logs
+type MetricLabels struct {
+	Cluster   string `koanf:"cluster"`
+	Namespace string `koanf:"namespace"`
+}
+
+// ResourceLabels are the labels of the resource metrics. Workload are the labels matching
+// the workload labels of the logs, in the same order, the workload labels themselves if empty.
+type ResourceLabels struct {
+	MetricLabels `koanf:",squash"`
+	Workload     []string `koanf:"workload"`
 }
 
 type Scheduling struct {
@@ -58,8 +159,41 @@ type Scheduling struct {
 }
 
 type Cron struct {
-	// IngestionCheck string `koanf:"ingestion_check"`
-	BudgetReset string `koanf:"budget_reset"`
+	IngestionCheck string `koanf:"ingestion_check"`
+	BudgetReset    string `koanf:"budget_reset"`
+}
+
+type LeaderElection struct {
+	Enabled        bool          `koanf:"enabled"`
+	LeaseName      string        `koanf:"lease_name"`
+	LeaseNamespace string        `koanf:"lease_namespace"`
+	LeaseDuration  time.Duration `koanf:"lease_duration"`
+	RenewDeadline  time.Duration `koanf:"renew_deadline"`
+	RetryPeriod    time.Duration `koanf:"retry_period"`
+}
+
+type History struct {
+	Enabled   bool          `koanf:"enabled"`
+	Backend   string        `koanf:"backend"`
+	Path      string        `koanf:"path"`
+	ConfigMap ConfigMapRef  `koanf:"configmap"`
+	Retention time.Duration `koanf:"retention"`
+}
+
+// Exemptions configures the ConfigMap holding workload exemptions in addition to budget.yaml.
+// The ConfigMap is not used if no name is set.
+type Exemptions struct {
+	ConfigMap ConfigMapRef `koanf:"configmap"`
+}
+
+// LogBudgets enables reading budgets from LogBudget custom resources in addition to budget.yaml
+type LogBudgets struct {
+	Enabled bool `koanf:"enabled"`
+}
+
+type ConfigMapRef struct {
+	Name      string `koanf:"name"`
+	Namespace string `koanf:"namespace"`
 }
 
 type Budget struct {
@@ -68,6 +202,51 @@ type Budget struct {
 	Env        string  `koanf:"env"`
 	Multiplier float64 `koanf:"multiplier"`
 	Minimum    float64 `koanf:"mimimum"`
+	// Strategy selects how the dynamic budget of a workload is derived from its resources
+	Strategy BudgetStrategy `koanf:"strategy"`
+	// Allocation is budget to only sample workloads over their budget, or fair_share to also keep
+	// each cluster within the daily_ingestion_cap of its environment
+	Allocation string `koanf:"allocation"`
+	// Forecast samples workloads early if their burn rate projects them over budget by the end of the budget day
+	Forecast Forecast `koanf:"forecast"`
+}
+
+// Forecast projects the end-of-day ingestion of workloads from their burn rate over the trailing window
+type Forecast struct {
+	Enabled bool          `koanf:"enabled"`
+	Window  time.Duration `koanf:"window"`
+}
+
+// BudgetStrategy holds the selected dynamic budget strategy and the parameters of every strategy
+type BudgetStrategy struct {
+	Name       string             `koanf:"name"`
+	CPU        CPUStrategy        `koanf:"cpu"`
+	CPUMemory  CPUMemoryStrategy  `koanf:"cpu_memory"`
+	PerReplica PerReplicaStrategy `koanf:"per_replica"`
+	Flat       FlatStrategy       `koanf:"flat"`
+}
+
+// CPUStrategy grants budget.multiplier GB per standard_cores requested CPU cores
+type CPUStrategy struct {
+	StandardCores float64 `koanf:"standard_cores"`
+}
+
+// CPUMemoryStrategy grants budget.multiplier GB per weighted standard_cores CPU cores and standard_memory_gb memory
+type CPUMemoryStrategy struct {
+	StandardCores    float64 `koanf:"standard_cores"`
+	StandardMemoryGB float64 `koanf:"standard_memory_gb"`
+	CPUWeight        float64 `koanf:"cpu_weight"`
+	MemoryWeight     float64 `koanf:"memory_weight"`
+}
+
+// PerReplicaStrategy grants a budget in GB per replica
+type PerReplicaStrategy struct {
+	BudgetPerReplica float64 `koanf:"budget_per_replica"`
+}
+
+// FlatStrategy grants every workload the same budget in GB
+type FlatStrategy struct {
+	DailyIngestionBudget float64 `koanf:"daily_ingestion_budget"`
 }
 
 type Log struct {
@@ -93,20 +272,20 @@ var (
 	parser = yaml.Parser()
 )
 
-func Init() (*Config, error) {
-	var configFile string
-
+// FilePath returns the path of the config file, taken from the CONFIG_FILE env var
+func FilePath() string {
 	if v := os.Getenv("CONFIG_FILE"); v != "" {
 		log.Debug().Msg(fmt.Sprintf("env CONFIG_FILE=%s found", v))
-		configFile = v
-	} else {
-		log.Debug().
-			Str("default", DefaultConfigFile).
-			Msg("CONFIG_FILE env var not found, using default config path")
-		configFile = DefaultConfigFile
+		return v
 	}
+	log.Debug().
+		Str("default", DefaultConfigFile).
+		Msg("CONFIG_FILE env var not found, using default config path")
+	return DefaultConfigFile
+}
 
-	cfg, err := New(configFile)
+func Init() (*Config, error) {
+	cfg, err := New(FilePath())
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error loading config")
 	}
@@ -130,8 +309,35 @@ func New(filePath string) (Config, error) {
 		return Config{}, err
 	}
 
-	// Set default values for missing fields
-	if config.Cluster == "" {
+	return setDefaults(config), nil
+}
+
+// Load reads the config file like New but reports invalid configs as errors
+// instead of exiting, so a running instance can keep its current config.
+func Load(filePath string) (config Config, err error) {
+	log.Debug().Str("filePath", filePath).Msg("Reloading Config")
+	kc := koanf.New("config")
+	if err := kc.Load(file.Provider(filePath), parser); err != nil {
+		return Config{}, fmt.Errorf("config parsing error: %w", err)
+	}
+	if err := kc.Unmarshal("", &config); err != nil {
+		return Config{}, fmt.Errorf("config unmarshaling error: %w", err)
+	}
+
+	// Missing required fields panic, see setDefaults
+	defer func() {
+		if r := recover(); r != nil {
+			config, err = Config{}, fmt.Errorf("invalid config: %v", r)
+		}
+	}()
+	config = setDefaults(config)
+
+	return config, nil
+}
+
+// setDefaults sets default values for missing fields and panics if a required field is missing
+func setDefaults(config Config) Config {
+	if config.Cluster == "" && len(config.Targets) == 0 {
 		// panic("cluster name is required")
 		log.Panic().Msg("💀 Please provide cluster name!")
 	}
@@ -151,8 +357,12 @@ func New(filePath string) (Config, error) {
 		config.Promtail.Secret.Key = "promtail.yaml"
 		log.Debug().Str("default", config.Promtail.Secret.Namespace).Msg("Promtail secret key is not provided, using default")
 	}
+	if len(config.WorkloadIdentity.Labels) == 0 {
+		config.WorkloadIdentity.Labels = []string{"workload"}
+		log.Debug().Strs("default", config.WorkloadIdentity.Labels).Msg("Workload labels are not provided, using default")
+	}
 	if config.Promtail.Sampling.Selector.Format == "" {
-		config.Promtail.Sampling.Selector.Format = "{workload=\"%s\"} |= \"\""
+		config.Promtail.Sampling.Selector.Format = defaultSelectorFormat(config.WorkloadIdentity.Labels, config.WorkloadIdentity.Namespaced)
 		log.Debug().Str("default", config.Promtail.Sampling.Selector.Format).Msg("Promtail sampling selector is not provided, using default")
 	}
 	if config.Metrics.MimirEndpoint == "" {
@@ -166,6 +376,23 @@ func New(filePath string) (Config, error) {
 		config.Metrics.QueryTimeout = 30 * time.Second
 		log.Debug().Str("default", config.Metrics.QueryTimeout.String()).Msg("Mimir query timeout is not provided, using default")
 	}
+	config.Metrics.Names = setMetricNamesDefaults(config.Metrics.Names)
+	if config.Metrics.IngestionSource == "" {
+		config.Metrics.IngestionSource = "mimir"
+		log.Debug().Str("default", config.Metrics.IngestionSource).Msg("Ingestion source is not provided, using default")
+	}
+	if config.Metrics.IngestionSource != "mimir" && config.Metrics.IngestionSource != "loki" {
+		log.Panic().Str("ingestion_source", config.Metrics.IngestionSource).Msg("💀 metrics.ingestion_source must be one of mimir, loki")
+	}
+	if config.Metrics.IngestionSource == "loki" {
+		if config.Metrics.Loki.Endpoint == "" {
+			log.Panic().Msg("💀 Please provide metrics.loki.endpoint to measure ingestion in Loki!")
+		}
+		if config.Metrics.Loki.Tenant == "" {
+			config.Metrics.Loki.Tenant = config.Metrics.MimirTenant
+			log.Debug().Str("default", config.Metrics.Loki.Tenant).Msg("Loki tenant is not provided, using default")
+		}
+	}
 	if config.Scheduling.TimeZone == "" {
 		config.Scheduling.TimeZone = "Asia/Kolkata"
 		log.Debug().Str("default", config.Scheduling.TimeZone).Msg("Timezone is not provided, using default")
@@ -174,16 +401,75 @@ func New(filePath string) (Config, error) {
 		config.Scheduling.Cron.BudgetReset = "0 0 * * *"
 		log.Debug().Str("default", config.Scheduling.Cron.BudgetReset).Msg("Reset cron is not provided, using default")
 	}
+	if config.Scheduling.Cron.IngestionCheck == "" {
+		config.Scheduling.Cron.IngestionCheck = "*/30 * * * *"
+		log.Debug().Str("default", config.Scheduling.Cron.IngestionCheck).Msg("Ingestion check cron is not provided, using default")
+	}
+	if config.LeaderElection.LeaseName == "" {
+		config.LeaderElection.LeaseName = "configurator"
+		log.Debug().Str("default", config.LeaderElection.LeaseName).Msg("Leader election lease name is not provided, using default")
+	}
+	if config.LeaderElection.LeaseNamespace == "" {
+		config.LeaderElection.LeaseNamespace = config.Promtail.Secret.Namespace
+		log.Debug().Str("default", config.LeaderElection.LeaseNamespace).Msg("Leader election lease namespace is not provided, using default")
+	}
+	if config.LeaderElection.LeaseDuration == 0 {
+		config.LeaderElection.LeaseDuration = 15 * time.Second
+		log.Debug().Str("default", config.LeaderElection.LeaseDuration.String()).Msg("Leader election lease duration is not provided, using default")
+	}
+	if config.LeaderElection.RenewDeadline == 0 {
+		config.LeaderElection.RenewDeadline = 10 * time.Second
+		log.Debug().Str("default", config.LeaderElection.RenewDeadline.String()).Msg("Leader election renew deadline is not provided, using default")
+	}
+	if config.LeaderElection.RetryPeriod == 0 {
+		config.LeaderElection.RetryPeriod = 2 * time.Second
+		log.Debug().Str("default", config.LeaderElection.RetryPeriod.String()).Msg("Leader election retry period is not provided, using default")
+	}
+	if config.History.Backend == "" {
+		config.History.Backend = "configmap"
+		log.Debug().Str("default", config.History.Backend).Msg("History backend is not provided, using default")
+	}
+	if config.History.Backend != "configmap" && config.History.Backend != "file" {
+		log.Panic().Str("backend", config.History.Backend).Msg("💀 history.backend must be one of configmap, file")
+	}
+	if config.History.Path == "" {
+		config.History.Path = "/app/data/history.jsonl"
+		log.Debug().Str("default", config.History.Path).Msg("History file path is not provided, using default")
+	}
+	if config.History.ConfigMap.Name == "" {
+		config.History.ConfigMap.Name = "configurator-history"
+		log.Debug().Str("default", config.History.ConfigMap.Name).Msg("History configmap name is not provided, using default")
+	}
+	if config.History.ConfigMap.Namespace == "" {
+		config.History.ConfigMap.Namespace = config.Promtail.Secret.Namespace
+		log.Debug().Str("default", config.History.ConfigMap.Namespace).Msg("History configmap namespace is not provided, using default")
+	}
+	if config.History.Retention == 0 {
+		config.History.Retention = 30 * 24 * time.Hour
+		log.Debug().Str("default", config.History.Retention.String()).Msg("History retention is not provided, using default")
+	}
+	if config.Exemptions.ConfigMap.Name != "" && config.Exemptions.ConfigMap.Namespace == "" {
+		config.Exemptions.ConfigMap.Namespace = config.Promtail.Secret.Namespace
+		log.Debug().Str("default", config.Exemptions.ConfigMap.Namespace).Msg("Exemptions configmap namespace is not provided, using default")
+	}
+	// A LogBudget only selects workloads in its own namespace, which requires workload keys with their namespace
+	if config.LogBudgets.Enabled && !config.WorkloadIdentity.Namespaced {
+		log.Panic().Msg("💀 log_budgets.enabled requires workload_identity.namespaced")
+	}
+	if config.Slack.WebhookURL == "" {
+		config.Slack.WebhookURL = os.Getenv("SLACK_WEBHOOK_URL")
+	}
+	if config.Slack.Token == "" {
+		config.Slack.Token = os.Getenv("SLACK_TOKEN")
+	}
+	if config.Slack.Username == "" {
+		config.Slack.Username = "configurator"
+		log.Debug().Str("default", config.Slack.Username).Msg("Slack username is not provided, using default")
+	}
 	if config.Budget.ConfigPath == "" {
 		config.Budget.ConfigPath = "/app/budget/budget.yaml"
 		log.Debug().Str("default", config.Budget.ConfigPath).Msg("Budget config path is not provided, using default")
 	}
-	if config.Budget.Org == "" {
-		log.Panic().Msg("💀 Please provide budget.org name!")
-	}
-	if config.Budget.Env == "" {
-		log.Panic().Msg("💀 Please provide budget.env name!")
-	}
 	if config.Budget.Multiplier == 0 {
 		config.Budget.Multiplier = 1.0
 		log.Debug().Float64("default", config.Budget.Multiplier).Msg("Budget baseline multiplier is not provided, using default")
@@ -192,6 +478,21 @@ func New(filePath string) (Config, error) {
 		config.Budget.Minimum = 0.5
 		log.Debug().Float64("default", config.Budget.Minimum).Msg("Budget Minimum is not provided, using default")
 	}
+	config.Budget.Strategy = setBudgetStrategyDefaults(config.Budget.Strategy)
+	if config.Budget.Allocation == "" {
+		config.Budget.Allocation = "budget"
+		log.Debug().Str("default", config.Budget.Allocation).Msg("Budget allocation is not provided, using default")
+	}
+	if config.Budget.Allocation != "budget" && config.Budget.Allocation != "fair_share" {
+		log.Panic().Str("allocation", config.Budget.Allocation).Msg("💀 budget.allocation must be one of budget, fair_share")
+	}
+	if config.Budget.Forecast.Window == 0 {
+		config.Budget.Forecast.Window = time.Hour
+		log.Debug().Str("default", config.Budget.Forecast.Window.String()).Msg("Forecast window is not provided, using default")
+	}
+	if config.Budget.Forecast.Window < 5*time.Minute {
+		log.Panic().Str("window", config.Budget.Forecast.Window.String()).Msg("💀 budget.forecast.window must be at least 5m")
+	}
 	if config.Log.Level == "" {
 		config.Log.Level = "info"
 		log.Debug().Str("default", config.Log.Level).Msg("Log level is not provided, using default")
@@ -222,8 +523,189 @@ func New(filePath string) (Config, error) {
 			Bool("default", config.DryRun).
 			Msg("DryRun is not provided, using default")
 	}
+	if len(config.Targets) == 0 {
+		config.Targets = []Target{{Name: config.Cluster}}
+		log.Debug().Str("default", config.Cluster).Msg("Targets are not provided, using the top-level settings as the only target")
+	}
+	names := make(map[string]struct{}, len(config.Targets))
+	for i := range config.Targets {
+		setTargetDefaults(&config.Targets[i], config)
+		if _, ok := names[config.Targets[i].Name]; ok {
+			log.Panic().Str("target", config.Targets[i].Name).Msg("💀 Target names must be unique!")
+		}
+		names[config.Targets[i].Name] = struct{}{}
+	}
 
-	return config, nil
+	return config
+}
+
+// setBudgetStrategyDefaults fills the unset parameters of the budget strategies.
+// The cpu strategy with 16 standard cores is the default.
+func setBudgetStrategyDefaults(s BudgetStrategy) BudgetStrategy {
+	if s.Name == "" {
+		s.Name = "cpu"
+		log.Debug().Str("default", s.Name).Msg("Budget strategy is not provided, using default")
+	}
+	if s.CPU.StandardCores == 0 {
+		s.CPU.StandardCores = 16
+		log.Debug().Float64("default", s.CPU.StandardCores).Msg("Budget strategy cpu.standard_cores is not provided, using default")
+	}
+	if s.CPUMemory.StandardCores == 0 {
+		s.CPUMemory.StandardCores = 16
+		log.Debug().Float64("default", s.CPUMemory.StandardCores).Msg("Budget strategy cpu_memory.standard_cores is not provided, using default")
+	}
+	if s.CPUMemory.StandardMemoryGB == 0 {
+		s.CPUMemory.StandardMemoryGB = 64
+		log.Debug().Float64("default", s.CPUMemory.StandardMemoryGB).Msg("Budget strategy cpu_memory.standard_memory_gb is not provided, using default")
+	}
+	if s.CPUMemory.CPUWeight == 0 && s.CPUMemory.MemoryWeight == 0 {
+		s.CPUMemory.CPUWeight, s.CPUMemory.MemoryWeight = 0.5, 0.5
+		log.Debug().Float64("default", s.CPUMemory.CPUWeight).Msg("Budget strategy cpu_memory weights are not provided, using default")
+	}
+	if s.PerReplica.BudgetPerReplica == 0 {
+		s.PerReplica.BudgetPerReplica = 1
+		log.Debug().Float64("default", s.PerReplica.BudgetPerReplica).Msg("Budget strategy per_replica.budget_per_replica is not provided, using default")
+	}
+	if s.Flat.DailyIngestionBudget == 0 {
+		s.Flat.DailyIngestionBudget = 1
+		log.Debug().Float64("default", s.Flat.DailyIngestionBudget).Msg("Budget strategy flat.daily_ingestion_budget is not provided, using default")
+	}
+
+	switch s.Name {
+	case "cpu", "cpu_memory", "per_replica", "flat":
+	default:
+		log.Panic().Str("strategy", s.Name).Msg("💀 budget.strategy.name must be one of cpu, cpu_memory, per_replica, flat")
+	}
+	if s.CPU.StandardCores < 0 || s.CPUMemory.StandardCores < 0 || s.CPUMemory.StandardMemoryGB < 0 ||
+		s.CPUMemory.CPUWeight < 0 || s.CPUMemory.MemoryWeight < 0 ||
+		s.PerReplica.BudgetPerReplica < 0 || s.Flat.DailyIngestionBudget < 0 {
+		log.Panic().Str("strategy", s.Name).Msg("💀 budget.strategy parameters must not be negative")
+	}
+	return s
+}
+
+// setMetricNamesDefaults fills the unset metrics and labels with the Promtail custom metric
+// and the workload recording rules. Resource labels default to the log bytes labels.
+func setMetricNamesDefaults(n MetricNames) MetricNames {
+	if n.LogBytes == "" {
+		n.LogBytes = "promtail_custom_processed_log_bytes_total"
+		log.Debug().Str("default", n.LogBytes).Msg("Log bytes metric is not provided, using default")
+	}
+	if n.CPURequest == "" {
+		n.CPURequest = "workload_cpu_request"
+		log.Debug().Str("default", n.CPURequest).Msg("CPU request metric is not provided, using default")
+	}
+	if n.MemoryRequest == "" {
+		n.MemoryRequest = "workload_memory_request"
+		log.Debug().Str("default", n.MemoryRequest).Msg("Memory request metric is not provided, using default")
+	}
+	if n.Replicas == "" {
+		n.Replicas = "workload_replicas"
+		log.Debug().Str("default", n.Replicas).Msg("Replicas metric is not provided, using default")
+	}
+	if n.ClusterMatcher == "" {
+		n.ClusterMatcher = "=~"
+		log.Debug().Str("default", n.ClusterMatcher).Msg("Cluster matcher is not provided, using default")
+	}
+	if n.Labels.Cluster == "" {
+		n.Labels.Cluster = "cluster"
+		log.Debug().Str("default", n.Labels.Cluster).Msg("Cluster label is not provided, using default")
+	}
+	if n.Labels.Namespace == "" {
+		n.Labels.Namespace = "namespace"
+		log.Debug().Str("default", n.Labels.Namespace).Msg("Namespace label is not provided, using default")
+	}
+	if n.ResourceLabels.Cluster == "" {
+		n.ResourceLabels.Cluster = n.Labels.Cluster
+	}
+	if n.ResourceLabels.Namespace == "" {
+		n.ResourceLabels.Namespace = n.Labels.Namespace
+	}
+	return n
+}
+
+// setTargetDefaults fills the unset fields of a target from the top-level settings
+func setTargetDefaults(t *Target, config Config) {
+	if t.Cluster == "" {
+		t.Cluster = config.Cluster
+	}
+	if t.Cluster == "" {
+		log.Panic().Str("target", t.Name).Msg("💀 Please provide the cluster of every target!")
+	}
+	if t.Name == "" {
+		t.Name = t.Cluster
+	}
+	if t.KubeConfig == "" {
+		t.KubeConfig = config.KubeConfig
+	}
+	if t.Secret.Name == "" {
+		t.Secret.Name = config.Promtail.Secret.Name
+	}
+	if t.Secret.Namespace == "" {
+		t.Secret.Namespace = config.Promtail.Secret.Namespace
+	}
+	if t.Secret.Key == "" {
+		t.Secret.Key = config.Promtail.Secret.Key
+	}
+	if t.Org == "" {
+		t.Org = config.Budget.Org
+	}
+	if t.Org == "" {
+		log.Panic().Str("target", t.Name).Msg("💀 Please provide budget.org name!")
+	}
+	if t.Env == "" {
+		t.Env = config.Budget.Env
+	}
+	if t.Env == "" {
+		log.Panic().Str("target", t.Name).Msg("💀 Please provide budget.env name!")
+	}
+	if len(t.WorkloadLabels) == 0 {
+		t.WorkloadLabels = config.WorkloadIdentity.Labels
+	}
+	for _, label := range t.WorkloadLabels {
+		if !labelNamePattern.MatchString(label) {
+			log.Panic().Str("target", t.Name).Str("label", label).
+				Msg("💀 Workload labels must be valid label names, e.g. app_kubernetes_io_name for app.kubernetes.io/name")
+		}
+	}
+	if n := len(config.Metrics.Names.ResourceLabels.Workload); n > 0 && n != len(t.WorkloadLabels) {
+		log.Panic().Str("target", t.Name).Strs("workload_labels", t.WorkloadLabels).
+			Msg("💀 metrics.names.resource_labels.workload must have a label for each workload label")
+	}
+	if t.SelectorFormat == "" {
+		t.SelectorFormat = config.Promtail.Sampling.Selector.Format
+		// Targets with their own workload labels get a default selector of these labels
+		if t.SelectorFormat == defaultSelectorFormat(config.WorkloadIdentity.Labels, config.WorkloadIdentity.Namespaced) {
+			t.SelectorFormat = defaultSelectorFormat(t.WorkloadLabels, config.WorkloadIdentity.Namespaced)
+		}
+	}
+	if config.WorkloadIdentity.Namespaced && !strings.Contains(t.SelectorFormat, "${namespace}") {
+		log.Panic().Str("target", t.Name).Str("selector_format", t.SelectorFormat).
+			Msg("💀 The selector format must use ${namespace} and ${workload} with workload_identity.namespaced")
+	}
+	if len(t.WorkloadLabels) > 1 && !strings.Contains(t.SelectorFormat, "${") {
+		log.Panic().Str("target", t.Name).Str("selector_format", t.SelectorFormat).
+			Msg("💀 The selector format must use a ${<label>} placeholder for each of several workload labels")
+	}
+}
+
+// labelNamePattern matches valid Prometheus and Loki label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// defaultSelectorFormat returns the sampling selector format matching the workload labels,
+// with %s for a single label and placeholders for several labels or namespaced workloads
+func defaultSelectorFormat(labels []string, namespaced bool) string {
+	if len(labels) == 1 && !namespaced {
+		return fmt.Sprintf("{%s=\"%%s\"} |= \"\"", labels[0])
+	}
+	matchers := make([]string, 0, len(labels)+1)
+	if namespaced {
+		matchers = append(matchers, "namespace=\"${namespace}\"")
+	}
+	for _, label := range labels {
+		matchers = append(matchers, fmt.Sprintf("%s=\"${%s}\"", label, label))
+	}
+	return "{" + strings.Join(matchers, ", ") + "} |= \"\""
 }
 
 func (c *Config) String() string {
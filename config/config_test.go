@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+cluster: cluster-001
+metrics:
+  mimir_endpoint: http://mimir:8080
+  mimir_tenant: tenant
+budget:
+  org: org
+  env: prod
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Cluster != "cluster-001" {
+		t.Fatalf("expected cluster-001, got %q", cfg.Cluster)
+	}
+	if cfg.Scheduling.Cron.IngestionCheck != "*/30 * * * *" {
+		t.Fatalf("expected default ingestion check cron, got %q", cfg.Scheduling.Cron.IngestionCheck)
+	}
+	if cfg.Budget.Strategy.Name != "cpu" || cfg.Budget.Strategy.CPU.StandardCores != 16 {
+		t.Fatalf("expected the cpu budget strategy with 16 standard cores, got %+v", cfg.Budget.Strategy)
+	}
+	if cfg.Budget.Allocation != "budget" {
+		t.Fatalf("expected the budget allocation, got %q", cfg.Budget.Allocation)
+	}
+	if cfg.Budget.Forecast.Enabled || cfg.Budget.Forecast.Window != time.Hour {
+		t.Fatalf("expected forecasting to be disabled with a 1h window, got %+v", cfg.Budget.Forecast)
+	}
+
+	// Keys removed from the file must not survive a reload
+	cfg, err = Load(writeConfig(t, testConfig+"dry_run: true\n"))
+	if err != nil || !cfg.DryRun {
+		t.Fatalf("expected dry_run to be set, got %v, %v", cfg.DryRun, err)
+	}
+	cfg, err = Load(writeConfig(t, testConfig))
+	if err != nil || cfg.DryRun {
+		t.Fatalf("expected dry_run to be reset, got %v, %v", cfg.DryRun, err)
+	}
+}
+
+func TestLoadTargets(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Targets) != 1 || cfg.Targets[0].Name != "cluster-001" || cfg.Targets[0].Org != "org" {
+		t.Fatalf("expected the top-level settings as the only target, got %+v", cfg.Targets)
+	}
+
+	cfg, err = Load(writeConfig(t, testConfig+`
+targets:
+  - cluster: cluster-002
+    kube_context: prod-2
+  - name: logging-b
+    cluster: cluster-002
+    env: stage
+    secret:
+      name: promtail-b
+`))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Targets) != 2 {
+		t.Fatalf("expected 2 targets, got %+v", cfg.Targets)
+	}
+	first, second := cfg.Targets[0], cfg.Targets[1]
+	if first.Name != "cluster-002" || first.KubeContext != "prod-2" || first.Secret.Name != "promtail" || first.Env != "prod" {
+		t.Errorf("expected defaults from the top-level settings, got %+v", first)
+	}
+	if second.Name != "logging-b" || second.Secret.Name != "promtail-b" || second.Secret.Namespace != "kube-logging" || second.Env != "stage" {
+		t.Errorf("expected target settings to override the top-level settings, got %+v", second)
+	}
+}
+
+func TestLoadWorkloadLabels(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig+`
+targets:
+  - cluster: cluster-001
+  - cluster: cluster-002
+    workload_labels: [app, container]
+`))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	first, second := cfg.Targets[0], cfg.Targets[1]
+	if len(first.WorkloadLabels) != 1 || first.WorkloadLabels[0] != "workload" || first.SelectorFormat != `{workload="%s"} |= ""` {
+		t.Errorf("expected the workload label and selector by default, got %v and %s", first.WorkloadLabels, first.SelectorFormat)
+	}
+	if want := `{app="${app}", container="${container}"} |= ""`; second.SelectorFormat != want {
+		t.Errorf("expected the selector %s for the target labels, got %s", want, second.SelectorFormat)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "Missing cluster", content: "metrics:\n  mimir_endpoint: http://mimir:8080\n"},
+		{name: "Invalid YAML", content: "cluster: [\n"},
+		{name: "Invalid history backend", content: testConfig + "history:\n  backend: s3\n"},
+		{name: "Duplicate target", content: testConfig + "targets:\n  - cluster: a\n  - cluster: a\n"},
+		{name: "Unknown budget strategy", content: "cluster: a\nbudget:\n  org: org\n  env: prod\n  strategy:\n    name: memory\n"},
+		{name: "Unknown budget allocation", content: "cluster: a\nbudget:\n  org: org\n  env: prod\n  allocation: weighted\n"},
+		{name: "Unknown ingestion source", content: "cluster: a\nmetrics:\n  mimir_endpoint: http://mimir:8080\n  mimir_tenant: tenant\n  ingestion_source: elasticsearch\nbudget:\n  org: org\n  env: prod\n"},
+		{name: "Loki without endpoint", content: "cluster: a\nmetrics:\n  mimir_endpoint: http://mimir:8080\n  mimir_tenant: tenant\n  ingestion_source: loki\nbudget:\n  org: org\n  env: prod\n"},
+		{name: "Short forecast window", content: testConfig + "  forecast:\n    enabled: true\n    window: 1m\n"},
+		{name: "Invalid workload label", content: testConfig + "workload_identity:\n  labels: [app.kubernetes.io/name]\n"},
+		{name: "Several workload labels with %s", content: testConfig + "workload_identity:\n  labels: [app, container]\npromtail:\n  sampling:\n    selector:\n      format: '{app=\"%s\"}'\n"},
+		{name: "LogBudgets without namespaced workloads", content: testConfig + "log_budgets:\n  enabled: true\n"},
+		{name: "Namespaced without namespace placeholder", content: testConfig + "workload_identity:\n  namespaced: true\npromtail:\n  sampling:\n    selector:\n      format: '{workload=\"%s\"}'\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, tt.content)); err == nil {
+				t.Fatalf("expected error for invalid config")
+			}
+		})
+	}
+}
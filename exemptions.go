@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"configurator/config"
+	"configurator/internal/budget"
+)
+
+// exemptionsKey is the key of the exemptions ConfigMap holding the list of exemptions
+const exemptionsKey = "exemptions.yaml"
+
+// activeExemptions returns the exemptions in effect at now for the workloads of a target by workload,
+// from budget.yaml and the exemptions ConfigMap. With prune, expired exemptions are removed from the ConfigMap.
+func activeExemptions(t config.Target, now time.Time, prune bool) (map[string]budget.Exemption, error) {
+	exemptions := append([]budget.Exemption(nil), budgetConfig.Exemptions...)
+
+	if cfg.Exemptions.ConfigMap.Name != "" {
+		stored, err := loadStoredExemptions(now, prune)
+		if err != nil {
+			return nil, err
+		}
+		exemptions = append(exemptions, stored...)
+	}
+
+	for _, e := range exemptions {
+		if e.Expired(now) && e.AppliesTo(t.Name, t.Org, t.Env) {
+			log.Debug().
+				Str("target", t.Name).
+				Str("workload", e.Workload).
+				Time("expired_at", e.ExpiresAt).
+				Msg("Ignoring expired exemption")
+		}
+	}
+
+	return budget.ActiveExemptions(exemptions, t.Name, t.Org, t.Env, now), nil
+}
+
+// loadStoredExemptions reads the exemptions ConfigMap and returns the exemptions which did not expire.
+// With prune, the expired exemptions are removed from the ConfigMap unless running in dry-run mode.
+func loadStoredExemptions(now time.Time, prune bool) ([]budget.Exemption, error) {
+	ref := cfg.Exemptions.ConfigMap
+
+	data, err := k8sClient.FetchConfigMapValue(ref.Namespace, ref.Name, exemptionsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch exemptions: %w", err)
+	}
+	if data == "" {
+		return nil, nil
+	}
+
+	exemptions, err := budget.ParseExemptions(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exemptions configmap %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+
+	active, expired := budget.PruneExemptions(exemptions, now)
+	if len(expired) == 0 || !prune {
+		return active, nil
+	}
+
+	for _, e := range expired {
+		log.Info().
+			Str("workload", e.Workload).
+			Str("owner", e.Owner).
+			Str("reason", e.Reason).
+			Time("expired_at", e.ExpiresAt).
+			Bool("dry_run", cfg.DryRun).
+			Msg("Removing expired exemption")
+	}
+	if cfg.DryRun {
+		return active, nil
+	}
+
+	content, err := budget.MarshalExemptions(active)
+	if err != nil {
+		return nil, err
+	}
+	// A failed cleanup is retried by the next run, the expired exemptions are ignored anyway
+	if err := k8sClient.UpdateConfigMapValue(ref.Namespace, ref.Name, exemptionsKey, content); err != nil {
+		log.Warn().Err(err).Msg("Failed to remove expired exemptions")
+	}
+
+	return active, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+
+	"configurator/config"
+	"configurator/internal/budget"
+	"configurator/internal/metrics"
+	"configurator/internal/models"
+	"configurator/internal/utils"
+)
+
+// forecastEnabled reports whether a run forecasts the end-of-day ingestion of workloads,
+// which needs their ingestion measured since the last budget reset
+func forecastEnabled(run *models.EnforcementRun) bool {
+	return cfg.Budget.Forecast.Enabled && run.TimeRange == budgetDayRange(run.StartedAt)
+}
+
+// applyForecast projects the ingestion of workloads at the end of the budget day from their burn rate and adds
+// the workloads projected over their daily budget to the over-budget workloads, so they are sampled early at the
+// rate that lands the projection on budget. Workloads with weekly or monthly periods and pool members are not
+// forecast, exempt workloads are forecast but never sampled.
+func applyForecast(
+	run *models.EnforcementRun,
+	t config.Target,
+	dayIngestion []models.WorkloadIngestedBytes,
+	dynamicBudget map[string]models.GigaBytes,
+	periods map[string]string,
+	pools []models.BudgetPool,
+	exemptions map[string]budget.Exemption,
+	overBudget []models.OverBudgetWorkload,
+) ([]models.OverBudgetWorkload, []budget.Forecast, error) {
+	metrics.ResetForecastMetrics(t.Name)
+
+	end, err := budgetDayEnd(run.StartedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	burnRates, err := targetMetrics(t).GetBurnRate(t.Cluster, cfg.Budget.Forecast.Window, run.StartedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get burn rates: %w", err)
+	}
+
+	budgets := make(map[string]models.GigaBytes, len(dynamicBudget))
+	for workload, b := range dynamicBudget {
+		if _, ok := periods[workload]; ok {
+			continue
+		}
+		if _, ok := utils.PoolOf(pools, workload); ok {
+			continue
+		}
+		budgets[workload] = b
+	}
+	ingested := make(map[string]models.GigaBytes, len(dayIngestion))
+	clusters := make(map[string]string, len(dayIngestion))
+	for _, w := range dayIngestion {
+		ingested[w.Workload] = models.GigaBytes(w.Value / 1000000000.0)
+		clusters[w.Workload] = w.Cluster
+	}
+
+	forecasts := budget.ProjectIngestion(ingested, burnRates, budgets, end.Sub(run.StartedAt))
+	var projectedOver []models.OverBudgetWorkload
+	for _, f := range forecasts {
+		metrics.RecordForecastMetrics(t.Name, f.Workload, float64(f.BurnRate), float64(f.Projected), float64(f.Overrun()))
+		if !f.OverBudget() {
+			continue
+		}
+
+		_, exempt := exemptions[f.Workload]
+		log.Info().
+			Str("target", t.Name).
+			Str("workload", f.Workload).
+			Float64("budget_gb", float64(f.Budget)).
+			Float64("usage_gb", float64(f.Ingested)).
+			Float64("burn_rate_gb_per_hour", float64(f.BurnRate)).
+			Float64("projected_gb", float64(f.Projected)).
+			Float64("projected_overrun_gb", float64(f.Overrun())).
+			Time("budget_day_end", end).
+			Bool("exempt", exempt).
+			Msg("Workload is projected to exceed its budget by the end of the budget day")
+		if exempt {
+			continue
+		}
+
+		projectedOver = append(projectedOver, models.OverBudgetWorkload{
+			Cluster:            clusters[f.Workload],
+			Workload:           f.Workload,
+			Budget:             f.Budget,
+			CurrentIngestion:   f.Ingested,
+			ProjectedIngestion: f.Projected,
+		})
+	}
+
+	if len(projectedOver) > 0 {
+		log.Info().
+			Str("target", t.Name).
+			Int("count", len(projectedOver)).
+			Msg("Found workloads projected over budget, sampling them early")
+	}
+
+	return utils.MergeOverBudget(overBudget, projectedOver), forecasts, nil
+}
+
+// budgetDayEnd returns the time of the next budget reset after now
+func budgetDayEnd(now time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(cfg.Scheduling.Cron.BudgetReset)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse budget reset cron: %w", err)
+	}
+	if schedulerLocation != nil {
+		now = now.In(schedulerLocation)
+	}
+	return schedule.Next(now), nil
+}
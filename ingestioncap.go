@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"configurator/config"
+	"configurator/internal/budget"
+	"configurator/internal/metrics"
+	"configurator/internal/models"
+	"configurator/internal/utils"
+)
+
+// applyIngestionCap adds the workloads to sample to keep the target's cluster within the daily_ingestion_cap
+// of its environment to the over-budget workloads. A workload found by both keeps the lower budget.
+// Exempt workloads are never capped, but their ingestion counts against the cap.
+func applyIngestionCap(
+	t config.Target,
+	ingestedBytes []models.WorkloadIngestedBytes,
+	overBudget []models.OverBudgetWorkload,
+	exemptions map[string]budget.Exemption,
+) []models.OverBudgetWorkload {
+	ingestionCap, priorities := budgetConfig.ExtractIngestionCap(t.Org, t.Env)
+	if ingestionCap <= 0 {
+		return overBudget
+	}
+
+	priorities = budget.ResolveWorkloadKeys(priorities, workloadNames(ingestedBytes, nil), cfg.WorkloadIdentity.Namespaced)
+
+	fixed := make(map[string]bool, len(exemptions))
+	for workload := range exemptions {
+		fixed[workload] = true
+	}
+	capped := utils.FindCapAbusers(ingestedBytes, ingestionCap, priorities, fixed)
+
+	var total models.GigaBytes
+	for _, w := range ingestedBytes {
+		total += models.GigaBytes(w.Value / 1000000000.0)
+	}
+	metrics.RecordIngestionCapMetrics(t.Name, t.Cluster, float64(total), float64(ingestionCap), len(capped))
+
+	if len(capped) > 0 {
+		log.Info().
+			Str("target", t.Name).
+			Float64("cap_gb", float64(ingestionCap)).
+			Float64("ingestion_gb", float64(total)).
+			Int("count", len(capped)).
+			Msg("Cluster is over its ingestion cap, sampling workloads to their fair share")
+	}
+
+	return utils.MergeOverBudget(overBudget, capped)
+}
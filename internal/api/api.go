@@ -0,0 +1,169 @@
+// Package api provides a read-only HTTP API exposing the current budget enforcement state
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"configurator/internal/budget"
+	"configurator/internal/models"
+)
+
+// RecommendFunc recommends budgets for the workloads of a target from their historical ingestion.
+// An empty target selects the only configured target, the query stops when ctx is done.
+type RecommendFunc func(ctx context.Context, target string, o budget.RecommendOptions) (budget.RecommendationReport, error)
+
+// ErrUnknownTarget is returned by a RecommendFunc for a target that is not configured
+var ErrUnknownTarget = errors.New("unknown target")
+
+// Handler serves the read-only admin API
+type Handler struct {
+	state     *State
+	recommend RecommendFunc
+	mux       *http.ServeMux
+}
+
+// errorResponse is the body returned for failed requests
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler creates a new admin API handler backed by the given state.
+// Budget recommendations are computed with recommend, they are not served if it is nil.
+func NewHandler(state *State, recommend RecommendFunc) *Handler {
+	h := &Handler{
+		state:     state,
+		recommend: recommend,
+		mux:       http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET /api/v1/workloads", h.listWorkloads)
+	// Namespaced workload keys are namespace/name, so the name spans the rest of the path
+	h.mux.HandleFunc("GET /api/v1/workloads/{name...}", h.getWorkload)
+	h.mux.HandleFunc("GET /api/v1/runs/last", h.getLastRun)
+	h.mux.HandleFunc("GET /api/v1/budget/recommendations", h.getRecommendations)
+
+	return h
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// listWorkloads returns the state of all workloads, or only those of the target query parameter
+func (h *Handler) listWorkloads(w http.ResponseWriter, r *http.Request) {
+	workloads := h.state.Workloads()
+	if target := r.URL.Query().Get("target"); target != "" {
+		workloads = h.state.TargetWorkloads(target)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"workloads": workloads,
+	})
+}
+
+// getWorkload returns the state of a workload. A workload known in several targets
+// must be narrowed down with the target query parameter.
+func (h *Handler) getWorkload(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	target := r.URL.Query().Get("target")
+
+	var matches []models.WorkloadStatus
+	for _, workload := range h.state.Workload(name) {
+		if target == "" || workload.Target == target {
+			matches = append(matches, workload)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		writeJSON(w, http.StatusNotFound, errorResponse{
+			Error: fmt.Sprintf("workload %s not found", name),
+		})
+	case 1:
+		writeJSON(w, http.StatusOK, matches[0])
+	default:
+		targets := make([]string, 0, len(matches))
+		for _, m := range matches {
+			targets = append(targets, m.Target)
+		}
+		writeJSON(w, http.StatusBadRequest, errorResponse{
+			Error: fmt.Sprintf("workload %s exists in targets %s, set the target query parameter", name, strings.Join(targets, ", ")),
+		})
+	}
+}
+
+func (h *Handler) getLastRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := h.state.LastRun()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{
+			Error: "no run has finished yet",
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// getRecommendations returns the budget recommendations of the target query parameter. The days, basis
+// and headroom query parameters default to budget.DefaultRecommendOptions, days is at most budget.MaxRecommendDays.
+// The query is canceled when the client goes away.
+func (h *Handler) getRecommendations(w http.ResponseWriter, r *http.Request) {
+	if h.recommend == nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{
+			Error: "budget recommendations are not available",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	o := budget.DefaultRecommendOptions
+	if days := query.Get("days"); days != "" {
+		d, err := strconv.Atoi(days)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid days %q", days)})
+			return
+		}
+		o.Days = d
+	}
+	if basis := query.Get("basis"); basis != "" {
+		o.Basis = basis
+	}
+	if headroom := query.Get("headroom"); headroom != "" {
+		hr, err := strconv.ParseFloat(headroom, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid headroom %q", headroom)})
+			return
+		}
+		o.Headroom = hr
+	}
+	if err := o.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: strings.ReplaceAll(err.Error(), "\n", ", ")})
+		return
+	}
+
+	report, err := h.recommend(r.Context(), query.Get("target"), o)
+	switch {
+	case errors.Is(err, ErrUnknownTarget):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// writeJSON encodes the body as JSON with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("Failed to encode API response")
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"configurator/internal/budget"
+	"configurator/internal/models"
+)
+
+func TestWorkloadEndpoints(t *testing.T) {
+	override := models.GigaBytes(30)
+
+	state := NewState()
+	state.SetWorkloads("cluster-001", []models.WorkloadStatus{
+		{
+			Cluster:          "cluster-001",
+			Workload:         "otel-collector",
+			BudgetOverride:   &override,
+			DynamicBudget:    30,
+			CurrentIngestion: 60,
+			OverBudget:       true,
+		},
+		{
+			Cluster:          "cluster-001",
+			Workload:         "api",
+			DynamicBudget:    2,
+			CurrentIngestion: 1,
+		},
+		{
+			Cluster:          "cluster-001",
+			Workload:         "payments/checkout",
+			DynamicBudget:    5,
+			CurrentIngestion: 10,
+			OverBudget:       true,
+		},
+	})
+	state.SetSampling("cluster-001", map[string]float64{"otel-collector": 50.0, "payments/checkout": 25.0})
+	state.SetWorkloads("cluster-002", []models.WorkloadStatus{
+		{
+			Cluster:          "cluster-002",
+			Workload:         "api",
+			DynamicBudget:    2,
+			CurrentIngestion: 1,
+		},
+	})
+
+	handler := NewHandler(state, nil)
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantSampled  bool
+		wantSampling float64
+	}{
+		{
+			name:         "Sampled workload",
+			path:         "/api/v1/workloads/otel-collector",
+			wantStatus:   http.StatusOK,
+			wantSampled:  true,
+			wantSampling: 50.0,
+		},
+		{
+			name:         "Not sampled workload",
+			path:         "/api/v1/workloads/api?target=cluster-001",
+			wantStatus:   http.StatusOK,
+			wantSampled:  false,
+			wantSampling: 100.0,
+		},
+		{
+			name:         "Namespaced workload",
+			path:         "/api/v1/workloads/payments/checkout",
+			wantStatus:   http.StatusOK,
+			wantSampled:  true,
+			wantSampling: 25.0,
+		},
+		{
+			name:       "Workload in several targets",
+			path:       "/api/v1/workloads/api",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown workload",
+			path:       "/api/v1/workloads/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got models.WorkloadStatus
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got.Sampled != tt.wantSampled {
+				t.Errorf("expected sampled %v, got %v", tt.wantSampled, got.Sampled)
+			}
+			if got.SamplingPercentage != tt.wantSampling {
+				t.Errorf("expected sampling percentage %v, got %v", tt.wantSampling, got.SamplingPercentage)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/workloads", nil))
+
+	var list struct {
+		Workloads []models.WorkloadStatus `json:"workloads"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(list.Workloads) != 4 || list.Workloads[0].Workload != "api" || list.Workloads[0].Target != "cluster-001" {
+		t.Fatalf("expected 4 workloads sorted by name and target, got %+v", list.Workloads)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/workloads?target=cluster-002", nil))
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(list.Workloads) != 1 || list.Workloads[0].Target != "cluster-002" {
+		t.Fatalf("expected only the workloads of cluster-002, got %+v", list.Workloads)
+	}
+}
+
+func TestLastRunEndpoint(t *testing.T) {
+	state := NewState()
+	handler := NewHandler(state, nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/runs/last", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d before any run, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	state.SetLastRun(models.EnforcementRun{ID: "run-1", Task: "ingestion_check", Success: true})
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/runs/last", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var run models.EnforcementRun
+	if err := json.NewDecoder(rec.Body).Decode(&run); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if run.ID != "run-1" {
+		t.Fatalf("expected run-1, got %s", run.ID)
+	}
+}
+
+func TestRecommendationsEndpoint(t *testing.T) {
+	var got budget.RecommendOptions
+	handler := NewHandler(NewState(), func(_ context.Context, target string, o budget.RecommendOptions) (budget.RecommendationReport, error) {
+		if target != "cluster-001" {
+			return budget.RecommendationReport{}, fmt.Errorf("%w %s", ErrUnknownTarget, target)
+		}
+		got = o
+		return budget.RecommendationReport{Target: target, Options: o}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/budget/recommendations?target=cluster-001&days=30&basis=max", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	want := budget.RecommendOptions{Days: 30, Basis: budget.BasisMax, Headroom: budget.DefaultRecommendOptions.Headroom}
+	if got != want {
+		t.Fatalf("expected options %+v, got %+v", want, got)
+	}
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{query: "?target=cluster-002", code: http.StatusNotFound},
+		{query: "?target=cluster-001&days=-1", code: http.StatusBadRequest},
+		{query: "?target=cluster-001&days=100000", code: http.StatusBadRequest},
+		{query: "?target=cluster-001&basis=p99", code: http.StatusBadRequest},
+		{query: "?target=cluster-001&headroom=lots", code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/budget/recommendations"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("expected status %d for %s, got %d", tt.code, tt.query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"sort"
+	"sync"
+
+	"configurator/internal/models"
+)
+
+// State holds the enforcement state observed by the most recent runs.
+// It is written by the scheduled tasks and read by the HTTP handlers.
+type State struct {
+	mu      sync.RWMutex
+	lastRun *models.EnforcementRun
+	targets map[string]*targetState
+}
+
+// targetState is the state of the workloads of a single target
+type targetState struct {
+	workloads map[string]models.WorkloadStatus
+	sampling  map[string]float64
+}
+
+// NewState creates an empty enforcement state
+func NewState() *State {
+	return &State{
+		targets: make(map[string]*targetState),
+	}
+}
+
+// SetLastRun stores the summary of the most recently finished run
+func (s *State) SetLastRun(run models.EnforcementRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRun = &run
+}
+
+// SetWorkloads replaces the last measured budget and ingestion of all workloads of a target
+func (s *State) SetWorkloads(target string, workloads []models.WorkloadStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.targetLocked(target)
+	t.workloads = make(map[string]models.WorkloadStatus, len(workloads))
+	for _, w := range workloads {
+		w.Target = target
+		t.workloads[w.Workload] = w
+	}
+}
+
+// SetSampling replaces the sampling percentages currently configured in the Promtail of a target
+func (s *State) SetSampling(target string, samplingRates map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.targetLocked(target)
+	t.sampling = make(map[string]float64, len(samplingRates))
+	for workload, rate := range samplingRates {
+		t.sampling[workload] = rate
+	}
+}
+
+// LastRun returns the summary of the most recently finished run, if any
+func (s *State) LastRun() (models.EnforcementRun, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.lastRun == nil {
+		return models.EnforcementRun{}, false
+	}
+	return *s.lastRun, true
+}
+
+// Workloads returns the state of every known workload sorted by name and target
+func (s *State) Workloads() []models.WorkloadStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []models.WorkloadStatus{}
+	for target, t := range s.targets {
+		for name := range t.workloads {
+			result = append(result, t.workload(target, name))
+		}
+		// Sampled workloads which were not measured in the last run are still reported
+		for name := range t.sampling {
+			if _, ok := t.workloads[name]; !ok {
+				result = append(result, t.workload(target, name))
+			}
+		}
+	}
+
+	sortStatuses(result)
+	return result
+}
+
+// TargetWorkloads returns the state of every known workload of a target sorted by name
+func (s *State) TargetWorkloads(target string) []models.WorkloadStatus {
+	result := []models.WorkloadStatus{}
+	for _, w := range s.Workloads() {
+		if w.Target == target {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// Workload returns the state of a single workload in every target it is known in
+func (s *State) Workload(name string) []models.WorkloadStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.WorkloadStatus
+	for target, t := range s.targets {
+		_, measured := t.workloads[name]
+		_, sampled := t.sampling[name]
+		if measured || sampled {
+			result = append(result, t.workload(target, name))
+		}
+	}
+
+	sortStatuses(result)
+	return result
+}
+
+// TargetWorkload returns the state of a single workload of a target
+func (s *State) TargetWorkload(target, name string) (models.WorkloadStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.targets[target]
+	if !ok {
+		return models.WorkloadStatus{}, false
+	}
+	_, measured := t.workloads[name]
+	_, sampled := t.sampling[name]
+	if !measured && !sampled {
+		return models.WorkloadStatus{}, false
+	}
+	return t.workload(target, name), true
+}
+
+// targetLocked returns the state of a target, creating it if needed.
+// Callers must hold s.mu.
+func (s *State) targetLocked(target string) *targetState {
+	t, ok := s.targets[target]
+	if !ok {
+		t = &targetState{
+			workloads: make(map[string]models.WorkloadStatus),
+			sampling:  make(map[string]float64),
+		}
+		s.targets[target] = t
+	}
+	return t
+}
+
+// workload merges the measured state and current sampling of a workload.
+// Callers must hold s.mu.
+func (t *targetState) workload(target, name string) models.WorkloadStatus {
+	w, ok := t.workloads[name]
+	if !ok {
+		w = models.WorkloadStatus{Target: target, Workload: name}
+	}
+
+	if rate, ok := t.sampling[name]; ok {
+		w.Sampled = true
+		w.SamplingPercentage = rate
+	} else {
+		w.Sampled = false
+		w.SamplingPercentage = 100.0
+	}
+	return w
+}
+
+func sortStatuses(statuses []models.WorkloadStatus) {
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Workload != statuses[j].Workload {
+			return statuses[i].Workload < statuses[j].Workload
+		}
+		return statuses[i].Target < statuses[j].Target
+	})
+}
@@ -2,10 +2,15 @@ package budget
 
 import (
 	"configurator/internal/models"
+	"errors"
 	"fmt"
+	"maps"
+	"regexp"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
@@ -13,6 +18,10 @@ import (
 
 type Budget struct {
 	Organizations []Organization `koanf:"orgs"`
+	Exemptions    []Exemption    `koanf:"exemptions"`
+	Overrides     []Override     `koanf:"overrides"`
+	// namespaced is set if workload keys are namespace/name, see models.WorkloadKey
+	namespaced bool
 }
 
 type Organization struct {
@@ -22,31 +31,203 @@ type Organization struct {
 type Environment struct {
 	Name      string     `koanf:"name"`
 	Workloads []Workload `koanf:"workloads"`
+	Pools     []Pool     `koanf:"pools"`
+	// DailyIngestionCap limits the total ingestion of each cluster of the environment with the fair_share allocation
+	DailyIngestionCap Size `koanf:"daily_ingestion_cap"`
 }
 
 type Workload struct {
-	Name                 string `koanf:"name"`
-	DailyIngestionBudget int    `koanf:"daily_ingestion_budget"`
+	Name string `koanf:"name"`
+	// Namespace scopes the workload with workload_identity.namespaced, a workload without one applies in every namespace
+	Namespace string `koanf:"namespace"`
+	// DailyIngestionBudget is nil if not set, an explicit 0 is a budget the workload is never sampled for
+	DailyIngestionBudget *Size `koanf:"daily_ingestion_budget"`
+	// Period is daily, weekly or monthly. Weekly and monthly budgets are the daily budget times the days of the period.
+	Period    string `koanf:"period"`
+	CarryOver bool   `koanf:"carry_over"`
+	Prorate   bool   `koanf:"prorate"`
+	// Priority weighs the workload's fair share of the ingestion cap, unset means 1
+	Priority float64 `koanf:"priority"`
 }
 
-// Global koanf instance. Use . as the key path delimiter. This can be / or anything.
-var (
-	k      = koanf.New(".")
-	parser = yaml.Parser()
-)
+// Key returns the key of the workload, namespace/name if it is scoped to a namespace
+func (w Workload) Key() string {
+	return models.WorkloadKey(w.Namespace, w.Name)
+}
 
-func New(path string) (Budget, error) {
+// ResolveWorkloadKeys returns the listed values keyed by the workload keys they apply to among keys.
+// A workload listed without a namespace applies to the workload of that name in every namespace,
+// unless it is also listed with the namespace. Without namespaced keys the listed values are returned as is.
+func ResolveWorkloadKeys[V any](listed map[string]V, keys []string, namespaced bool) map[string]V {
+	resolved := make(map[string]V, len(listed))
+	for key, v := range listed {
+		resolved[key] = v
+	}
+	if !namespaced {
+		return resolved
+	}
+	for _, key := range keys {
+		namespace, name := models.SplitWorkloadKey(key, namespaced)
+		if namespace == "" {
+			continue
+		}
+		if _, ok := listed[key]; ok {
+			continue
+		}
+		if v, ok := listed[name]; ok {
+			resolved[key] = v
+			// The entry without a namespace is replaced by the workloads it applies to
+			delete(resolved, name)
+		}
+	}
+	return resolved
+}
+
+// Pool is a budget shared by a group of workloads, e.g. all services of a team.
+// Members are listed by name or matched by a regular expression on the workload name.
+type Pool struct {
+	Name                 string   `koanf:"name"`
+	DailyIngestionBudget Size     `koanf:"daily_ingestion_budget"`
+	Members              []string `koanf:"members"`
+	Selector             string   `koanf:"selector"`
+}
+
+var parser = yaml.Parser()
+
+// New loads the budget config from path, namespaced is set if workloads are identified by namespace and name.
+// Every call uses a new koanf instance so entries removed from the file are dropped on reload.
+func New(path string, namespaced bool) (Budget, error) {
+	// Use . as the key path delimiter. This can be / or anything.
+	k := koanf.New(".")
 	if err := k.Load(file.Provider(path), parser); err != nil {
 		return Budget{}, fmt.Errorf("error loading budgetConfig: %v", err)
 	}
-	var budgetConfig Budget
-	if err := k.Unmarshal("", &budgetConfig); err != nil {
+	budgetConfig := Budget{namespaced: namespaced}
+	conf := koanf.UnmarshalConf{DecoderConfig: &mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.TextUnmarshallerHookFunc(),
+			timestampToStringHookFunc()),
+		Result:           &budgetConfig,
+		WeaklyTypedInput: true,
+	}}
+	if err := k.UnmarshalWithConf("", &budgetConfig, conf); err != nil {
 		return Budget{}, fmt.Errorf("error unmarshaling budgetConfig: %v", err)
 	}
 	return budgetConfig, nil
 }
 
-func (b *Budget) ExtractBudget(orgName string, envName string) (map[string]models.GigaBytes, error) {
+// Validate checks that every workload has a name and a non-negative budget,
+// that no workload is listed twice in the same environment, that every pool and override is valid
+// and that every exemption is complete
+func (b *Budget) Validate() error {
+	var errs []error
+	for _, org := range b.Organizations {
+		for _, env := range org.Environments {
+			seen := make(map[string]struct{}, len(env.Workloads))
+			for i, workload := range env.Workloads {
+				if workload.Name == "" {
+					errs = append(errs, fmt.Errorf("%s/%s: workload %d has no name", org.Name, env.Name, i))
+					continue
+				}
+				key := workload.Key()
+				if workload.DailyIngestionBudget != nil && *workload.DailyIngestionBudget < 0 {
+					errs = append(errs, fmt.Errorf("%s/%s/%s: daily_ingestion_budget must not be negative", org.Name, env.Name, key))
+				}
+				if workload.Priority < 0 {
+					errs = append(errs, fmt.Errorf("%s/%s/%s: priority must not be negative", org.Name, env.Name, key))
+				}
+				if _, ok := seen[key]; ok {
+					errs = append(errs, fmt.Errorf("%s/%s/%s: workload is listed more than once", org.Name, env.Name, key))
+				}
+				if err := workload.validatePeriod(); err != nil {
+					errs = append(errs, fmt.Errorf("%s/%s/%s: %w", org.Name, env.Name, key, err))
+				}
+				seen[key] = struct{}{}
+			}
+			if env.DailyIngestionCap < 0 {
+				errs = append(errs, fmt.Errorf("%s/%s: daily_ingestion_cap must not be negative", org.Name, env.Name))
+			}
+			errs = append(errs, validatePools(org.Name, env.Name, env.Pools)...)
+		}
+	}
+	for i, o := range b.Overrides {
+		if err := o.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("override %d (%s): %w", i, o.Name, err))
+		}
+	}
+	for i, e := range b.Exemptions {
+		if err := e.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("exemption %d (%s): %w", i, e.Workload, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validatePeriod checks that the period is known and that carry_over and prorate are only set for weekly and monthly periods
+func (w Workload) validatePeriod() error {
+	switch w.Period {
+	case "", PeriodDaily:
+		if w.CarryOver || w.Prorate {
+			return errors.New("carry_over and prorate require a weekly or monthly period")
+		}
+	case PeriodWeekly, PeriodMonthly:
+	default:
+		return fmt.Errorf("unknown period %q, use %s, %s or %s", w.Period, PeriodDaily, PeriodWeekly, PeriodMonthly)
+	}
+	return nil
+}
+
+// validatePools checks that every pool of an environment has a unique name, a positive budget
+// and members or a valid selector, and that no workload is listed in more than one pool
+func validatePools(orgName, envName string, pools []Pool) []error {
+	var errs []error
+	names := make(map[string]struct{}, len(pools))
+	members := make(map[string]string)
+	for i, pool := range pools {
+		if pool.Name == "" {
+			errs = append(errs, fmt.Errorf("%s/%s: pool %d has no name", orgName, envName, i))
+			continue
+		}
+		if _, ok := names[pool.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s/%s/%s: pool is listed more than once", orgName, envName, pool.Name))
+		}
+		names[pool.Name] = struct{}{}
+
+		if pool.DailyIngestionBudget <= 0 {
+			errs = append(errs, fmt.Errorf("%s/%s/%s: pool daily_ingestion_budget must be positive", orgName, envName, pool.Name))
+		}
+		if len(pool.Members) == 0 && pool.Selector == "" {
+			errs = append(errs, fmt.Errorf("%s/%s/%s: pool needs members or a selector", orgName, envName, pool.Name))
+		}
+		if _, err := compileSelector(pool.Selector); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s/%s: invalid pool selector: %w", orgName, envName, pool.Name, err))
+		}
+		for _, member := range pool.Members {
+			if other, ok := members[member]; ok {
+				errs = append(errs, fmt.Errorf("%s/%s/%s: workload %s is already a member of pool %s", orgName, envName, pool.Name, member, other))
+			}
+			members[member] = pool.Name
+		}
+	}
+	return errs
+}
+
+// compileSelector compiles a pool selector, anchored like a PromQL regex matcher
+func compileSelector(selector string) (*regexp.Regexp, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + selector + ")$")
+}
+
+// ExtractBudget returns the budget of every workload listed in the environment in effect at now, or an error
+// if the environment is not defined. Workloads listed without a daily_ingestion_budget, e.g. only for their
+// period or priority, are left out so a LogBudget or the budget strategy sets their budget.
+// Active overrides are applied, and overrides with a daily_ingestion_budget also set the budget of the
+// workloads they name which are not listed.
+// Members of a pool keep their own budget for reporting, but are enforced through their pool, see ExtractPools.
+func (b *Budget) ExtractBudget(orgName string, envName string, now time.Time) (map[string]models.GigaBytes, error) {
 
 	log.Trace().
 		Str("org", orgName).
@@ -54,20 +235,132 @@ func (b *Budget) ExtractBudget(orgName string, envName string) (map[string]model
 		Msg("Extracting budget...")
 
 	budgets := make(map[string]models.GigaBytes)
+	found := false
 	for _, org := range b.Organizations {
 		if org.Name == orgName {
 			for _, env := range org.Environments {
 				if env.Name == envName {
+					found = true
 					for _, workload := range env.Workloads {
-						budgets[workload.Name] = models.GigaBytes(workload.DailyIngestionBudget) // Convert to bytes
+						// Workloads listed without a budget get one from a LogBudget or the strategy
+						if workload.DailyIngestionBudget == nil {
+							continue
+						}
+						budgets[workload.Key()] = workload.DailyIngestionBudget.GigaBytes()
 					}
 				}
 			}
 		}
 	}
+	if !found {
+		return nil, fmt.Errorf("org %s env %s is not defined in the budget config", orgName, envName)
+	}
+
+	active := b.ActiveOverrides(orgName, envName, now)
+	unlisted := make(map[string]models.GigaBytes)
+	for _, o := range active {
+		if o.DailyIngestionBudget == 0 {
+			continue
+		}
+		for _, workload := range o.Workloads {
+			if _, ok := budgets[workload]; !ok {
+				unlisted[workload] = o.DailyIngestionBudget.GigaBytes()
+			}
+		}
+	}
+	// Unlisted workloads start from their override budget, so later overrides in the file still apply to them
+	maps.Copy(budgets, unlisted)
+	ApplyOverrides(active, orgName, envName, b.namespaced, budgets, nil)
+	for _, o := range active {
+		log.Debug().
+			Str("org", orgName).
+			Str("env", envName).
+			Str("override", o.Name).
+			Msg("Budget override is active")
+	}
 	return budgets, nil
 }
 
+// ExtractPeriods returns the workloads of the environment with a weekly or monthly period.
+// Their Daily budget is left for the caller to set from the resolved daily budget of each workload.
+func (b *Budget) ExtractPeriods(orgName string, envName string) []PeriodBudget {
+	var periods []PeriodBudget
+	for _, org := range b.Organizations {
+		if org.Name != orgName {
+			continue
+		}
+		for _, env := range org.Environments {
+			if env.Name != envName {
+				continue
+			}
+			for _, workload := range env.Workloads {
+				if workload.Period == "" || workload.Period == PeriodDaily {
+					continue
+				}
+				periods = append(periods, PeriodBudget{
+					Workload:  workload.Key(),
+					Period:    workload.Period,
+					CarryOver: workload.CarryOver,
+					Prorate:   workload.Prorate,
+				})
+			}
+		}
+	}
+	return periods
+}
+
+// ExtractPools returns the budget pools of the environment
+func (b *Budget) ExtractPools(orgName string, envName string) ([]models.BudgetPool, error) {
+	var pools []models.BudgetPool
+	for _, org := range b.Organizations {
+		if org.Name != orgName {
+			continue
+		}
+		for _, env := range org.Environments {
+			if env.Name != envName {
+				continue
+			}
+			for _, pool := range env.Pools {
+				selector, err := compileSelector(pool.Selector)
+				if err != nil {
+					return nil, fmt.Errorf("invalid selector of pool %s: %w", pool.Name, err)
+				}
+				pools = append(pools, models.BudgetPool{
+					Name:       pool.Name,
+					Budget:     pool.DailyIngestionBudget.GigaBytes(),
+					Members:    pool.Members,
+					Selector:   selector,
+					Namespaced: b.namespaced,
+				})
+			}
+		}
+	}
+	return pools, nil
+}
+
+// ExtractIngestionCap returns the daily ingestion cap of the environment, zero if it has none,
+// and the priority of every workload listed with one
+func (b *Budget) ExtractIngestionCap(orgName string, envName string) (models.GigaBytes, map[string]float64) {
+	priorities := make(map[string]float64)
+	for _, org := range b.Organizations {
+		if org.Name != orgName {
+			continue
+		}
+		for _, env := range org.Environments {
+			if env.Name != envName {
+				continue
+			}
+			for _, workload := range env.Workloads {
+				if workload.Priority > 0 {
+					priorities[workload.Key()] = workload.Priority
+				}
+			}
+			return env.DailyIngestionCap.GigaBytes(), priorities
+		}
+	}
+	return 0, priorities
+}
+
 // Exract only workloads from the budget
 func (b *Budget) ExtractWorkloads(orgName string, envName string) []string {
 	log.Info().Msg(fmt.Sprintf("Extracting workloads for org: %v env: %v", orgName, envName))
@@ -77,7 +370,7 @@ func (b *Budget) ExtractWorkloads(orgName string, envName string) []string {
 			for _, env := range org.Environments {
 				if env.Name == envName {
 					for _, workload := range env.Workloads {
-						workloads = append(workloads, workload.Name)
+						workloads = append(workloads, workload.Key())
 					}
 				}
 			}
@@ -86,21 +379,21 @@ func (b *Budget) ExtractWorkloads(orgName string, envName string) []string {
 	return workloads
 }
 
-// CalculateDynamicBudget calculates the dynamic budget for each workload based on its resource requests.
-func CalculateDynamicBudget(workloadResourceRequests []models.WorkloadResourceRequest, budgetOverideBytes map[string]models.GigaBytes, baselineBudgetMultiplier float64, minimumBudget float64) (map[string]models.GigaBytes, error) {
-
-	var standardCPUCores models.Cores = 16.0
+// CalculateDynamicBudget calculates the dynamic budget for each workload from its resources using the strategy.
+// Budgets below minimumBudget are raised to it and budgets set in budget.yaml override the calculated ones.
+func CalculateDynamicBudget(workloadResourceRequests []models.WorkloadResourceRequest, budgetOverideBytes map[string]models.GigaBytes, strategy Strategy, minimumBudget float64) (map[string]models.GigaBytes, error) {
 
 	b := 0.0
 
 	log.Trace().
+		Str("strategy", fmt.Sprintf("%T", strategy)).
 		Msg("calculating dynamic budget...")
 
 	calculatedBudgetBytes := make(map[string]models.GigaBytes, len(workloadResourceRequests))
 
 	for _, w := range workloadResourceRequests {
 
-		b = float64(w.CPU/standardCPUCores) * baselineBudgetMultiplier
+		b = float64(strategy.Budget(w))
 
 		if b < minimumBudget {
 			b = minimumBudget
@@ -0,0 +1,177 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"configurator/internal/models"
+)
+
+const poolBudget = `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: payments-api
+            daily_ingestion_budget: 10
+        pools:
+          - name: payments
+            daily_ingestion_budget: 50GB
+            members: [payments-api, payments-worker]
+          - name: search
+            daily_ingestion_budget: 20
+            selector: search-.*
+`
+
+func TestExtractPools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.yaml")
+	if err := os.WriteFile(path, []byte(poolBudget), 0o600); err != nil {
+		t.Fatalf("failed to write budget: %v", err)
+	}
+
+	b, err := New(path, true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("expected valid budget, got %v", err)
+	}
+
+	pools, err := b.ExtractPools("invest", "prod")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(pools) != 2 || pools[0].Budget != 50 || pools[1].Budget != 20 {
+		t.Fatalf("expected 2 pools, got %+v", pools)
+	}
+	if !pools[0].Contains("payments-worker") || pools[0].Contains("payments") {
+		t.Errorf("expected payments pool to contain only its members")
+	}
+	if !pools[1].Contains("search-indexer") || pools[1].Contains("my-search-indexer") {
+		t.Errorf("expected search pool selector to be anchored")
+	}
+
+	pools, err = b.ExtractPools("invest", "stage")
+	if err != nil || len(pools) != 0 {
+		t.Fatalf("expected no pools for an unknown env, got %+v, %v", pools, err)
+	}
+}
+
+func TestValidatePools(t *testing.T) {
+	tests := []struct {
+		name  string
+		pools []Pool
+	}{
+		{name: "Missing name", pools: []Pool{{DailyIngestionBudget: 1, Members: []string{"a"}}}},
+		{name: "Missing budget", pools: []Pool{{Name: "p", Members: []string{"a"}}}},
+		{name: "Missing members", pools: []Pool{{Name: "p", DailyIngestionBudget: 1}}},
+		{name: "Invalid selector", pools: []Pool{{Name: "p", DailyIngestionBudget: 1, Selector: "a("}}},
+		{name: "Duplicate pool", pools: []Pool{
+			{Name: "p", DailyIngestionBudget: 1, Members: []string{"a"}},
+			{Name: "p", DailyIngestionBudget: 1, Members: []string{"b"}},
+		}},
+		{name: "Member of two pools", pools: []Pool{
+			{Name: "p", DailyIngestionBudget: 1, Members: []string{"a"}},
+			{Name: "q", DailyIngestionBudget: 1, Members: []string{"a"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Budget{Organizations: []Organization{{
+				Name:         "invest",
+				Environments: []Environment{{Name: "prod", Pools: tt.pools}},
+			}}}
+			if err := b.Validate(); err == nil {
+				t.Fatalf("expected error for invalid pools")
+			}
+		})
+	}
+}
+
+const namespacedBudget = `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: api
+            daily_ingestion_budget: 10
+          - name: api
+            namespace: payments
+            daily_ingestion_budget: 2
+`
+
+func TestNamespacedWorkloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.yaml")
+	if err := os.WriteFile(path, []byte(namespacedBudget), 0o600); err != nil {
+		t.Fatalf("failed to write budget: %v", err)
+	}
+	b, err := New(path, true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("expected a valid budget, got %v", err)
+	}
+	if problems := LintContent([]byte(namespacedBudget), nil); len(problems) != 0 {
+		t.Errorf("expected no lint problems, got %v", problems)
+	}
+
+	budgets, err := b.ExtractBudget("invest", "prod", time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resolved := ResolveWorkloadKeys(budgets, []string{"payments/api", "search/api", "search/indexer"}, true)
+	want := map[string]models.GigaBytes{"payments/api": 2, "search/api": 10}
+	if len(resolved) != len(want) {
+		t.Fatalf("expected %d budgets, got %v", len(want), resolved)
+	}
+	for workload, w := range want {
+		if resolved[workload] != w {
+			t.Errorf("expected budget of %s to be %v, got %v", workload, w, resolved[workload])
+		}
+	}
+
+	// Without namespaces the budgets are used as listed, a / is part of the workload name
+	if resolved := ResolveWorkloadKeys(map[string]models.GigaBytes{"api": 10}, []string{"api", "team/api"}, false); len(resolved) != 1 || resolved["api"] != 10 {
+		t.Errorf("expected only the budget of api to be kept, got %v", resolved)
+	}
+}
+
+func TestExtractBudgetZero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.yaml")
+	content := `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: audit
+            daily_ingestion_budget: 0
+          - name: batch
+            period: weekly
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write budget: %v", err)
+	}
+	b, err := New(path, false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	budgets, err := b.ExtractBudget("invest", "prod", time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// An explicit zero is a budget, a workload without one is left to LogBudgets and the strategy
+	if budget, ok := budgets["audit"]; !ok || budget != 0 {
+		t.Errorf("expected a budget of 0 for audit, got %v", budgets)
+	}
+	if _, ok := budgets["batch"]; ok {
+		t.Errorf("expected no budget for batch, got %v", budgets)
+	}
+}
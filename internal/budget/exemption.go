@@ -0,0 +1,104 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Exemption prevents a workload from being sampled until it expires, even if it is over budget.
+// Target, org and env are optional and narrow down which workloads are exempt.
+type Exemption struct {
+	Workload  string    `koanf:"workload" yaml:"workload"`
+	Target    string    `koanf:"target" yaml:"target,omitempty"`
+	Org       string    `koanf:"org" yaml:"org,omitempty"`
+	Env       string    `koanf:"env" yaml:"env,omitempty"`
+	Owner     string    `koanf:"owner" yaml:"owner"`
+	Reason    string    `koanf:"reason" yaml:"reason"`
+	ExpiresAt time.Time `koanf:"expires_at" yaml:"expires_at"`
+}
+
+// Expired reports whether the exemption is no longer in effect at now
+func (e Exemption) Expired(now time.Time) bool {
+	return !now.Before(e.ExpiresAt)
+}
+
+// AppliesTo reports whether the exemption applies to the workloads of the given target, org and env
+func (e Exemption) AppliesTo(target, org, env string) bool {
+	return (e.Target == "" || e.Target == target) &&
+		(e.Org == "" || e.Org == org) &&
+		(e.Env == "" || e.Env == env)
+}
+
+// Validate checks that the exemption names a workload, an owner, a reason and an expiry
+func (e Exemption) Validate() error {
+	var errs []error
+	if e.Workload == "" {
+		errs = append(errs, errors.New("workload is required"))
+	}
+	if e.Owner == "" {
+		errs = append(errs, errors.New("owner is required"))
+	}
+	if e.Reason == "" {
+		errs = append(errs, errors.New("reason is required"))
+	}
+	if e.ExpiresAt.IsZero() {
+		errs = append(errs, errors.New("expires_at is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// ActiveExemptions returns the exemptions in effect at now for the workloads of a target, by workload name
+func ActiveExemptions(exemptions []Exemption, target, org, env string, now time.Time) map[string]Exemption {
+	active := make(map[string]Exemption)
+	for _, e := range exemptions {
+		if e.Expired(now) || !e.AppliesTo(target, org, env) {
+			continue
+		}
+		// The exemption expiring last wins
+		if current, ok := active[e.Workload]; !ok || e.ExpiresAt.After(current.ExpiresAt) {
+			active[e.Workload] = e
+		}
+	}
+	return active
+}
+
+// PruneExemptions splits exemptions into those still in effect at now and those which expired
+func PruneExemptions(exemptions []Exemption, now time.Time) (active, expired []Exemption) {
+	for _, e := range exemptions {
+		if e.Expired(now) {
+			expired = append(expired, e)
+		} else {
+			active = append(active, e)
+		}
+	}
+	return active, expired
+}
+
+// ParseExemptions parses a YAML list of exemptions, as stored in the exemptions ConfigMap
+func ParseExemptions(data string) ([]Exemption, error) {
+	var exemptions []Exemption
+	if err := yaml.Unmarshal([]byte(data), &exemptions); err != nil {
+		return nil, fmt.Errorf("error parsing exemptions: %w", err)
+	}
+	for i, e := range exemptions {
+		if err := e.Validate(); err != nil {
+			return nil, fmt.Errorf("exemption %d (%s): %w", i, e.Workload, err)
+		}
+	}
+	return exemptions, nil
+}
+
+// MarshalExemptions renders exemptions as the YAML list stored in the exemptions ConfigMap
+func MarshalExemptions(exemptions []Exemption) (string, error) {
+	if len(exemptions) == 0 {
+		return "[]\n", nil
+	}
+	out, err := yaml.Marshal(exemptions)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling exemptions: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,112 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestActiveExemptions(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	exemptions := []Exemption{
+		{Workload: "api", Owner: "alice", Reason: "INC-1", ExpiresAt: now.Add(time.Hour)},
+		{Workload: "api", Owner: "bob", Reason: "INC-2", ExpiresAt: now.Add(2 * time.Hour)},
+		{Workload: "expired", Owner: "alice", Reason: "INC-0", ExpiresAt: now.Add(-time.Hour)},
+		{Workload: "other-env", Env: "stage", Owner: "alice", Reason: "INC-3", ExpiresAt: now.Add(time.Hour)},
+		{Workload: "other-target", Target: "cluster-002", Owner: "alice", Reason: "INC-4", ExpiresAt: now.Add(time.Hour)},
+	}
+
+	active := ActiveExemptions(exemptions, "cluster-001", "invest", "prod", now)
+
+	if len(active) != 1 {
+		t.Fatalf("expected only api to be exempt, got %+v", active)
+	}
+	if active["api"].Owner != "bob" {
+		t.Errorf("expected the exemption expiring last, got %+v", active["api"])
+	}
+
+	kept, expired := PruneExemptions(exemptions, now)
+	if len(kept) != 4 || len(expired) != 1 || expired[0].Workload != "expired" {
+		t.Errorf("expected one expired exemption, got %+v", expired)
+	}
+}
+
+func TestExemptionAppliesTo(t *testing.T) {
+	tests := []struct {
+		exemption Exemption
+		want      bool
+	}{
+		{exemption: Exemption{Workload: "api"}, want: true},
+		{exemption: Exemption{Workload: "api", Target: "cluster-001", Org: "invest", Env: "prod"}, want: true},
+		{exemption: Exemption{Workload: "api", Target: "cluster-002"}, want: false},
+		{exemption: Exemption{Workload: "api", Org: "stocks"}, want: false},
+		{exemption: Exemption{Workload: "api", Env: "stage"}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.exemption.AppliesTo("cluster-001", "invest", "prod"); got != tt.want {
+			t.Errorf("expected %v for %+v, got %v", tt.want, tt.exemption, got)
+		}
+	}
+}
+
+func TestParseExemptions(t *testing.T) {
+	exemptions, err := ParseExemptions(`
+- workload: api
+  owner: alice
+  reason: INC-1
+  expires_at: 2025-03-01T12:00:00Z
+`)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(exemptions) != 1 || !exemptions[0].ExpiresAt.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected one exemption expiring at noon, got %+v", exemptions)
+	}
+
+	out, err := MarshalExemptions(exemptions)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	roundTrip, err := ParseExemptions(out)
+	if err != nil || len(roundTrip) != 1 || roundTrip[0] != exemptions[0] {
+		t.Fatalf("expected exemptions to round trip, got %+v, %v", roundTrip, err)
+	}
+
+	if _, err := ParseExemptions("- workload: api\n"); err == nil {
+		t.Fatalf("expected error for exemption without owner, reason and expiry")
+	}
+}
+
+func TestNewWithExemptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.yaml")
+	content := `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: api
+            daily_ingestion_budget: 10
+exemptions:
+  - workload: api
+    owner: alice
+    reason: INC-1
+    expires_at: 2025-03-01T12:00:00Z
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write budget: %v", err)
+	}
+
+	b, err := New(path, true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("expected valid budget, got %v", err)
+	}
+	if len(b.Exemptions) != 1 || b.Exemptions[0].ExpiresAt.IsZero() {
+		t.Fatalf("expected one exemption with expiry, got %+v", b.Exemptions)
+	}
+}
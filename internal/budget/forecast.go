@@ -0,0 +1,55 @@
+package budget
+
+import (
+	"sort"
+	"time"
+
+	"configurator/internal/models"
+)
+
+// Forecast is the ingestion of a workload projected to the end of the budget day at its current burn rate
+type Forecast struct {
+	Workload string
+	Ingested models.GigaBytes
+	// BurnRate is the current ingestion rate in GB per hour
+	BurnRate  models.GigaBytes
+	Projected models.GigaBytes
+	Budget    models.GigaBytes
+}
+
+// Overrun returns how far the projected ingestion exceeds the budget, zero if it stays within
+func (f Forecast) Overrun() models.GigaBytes {
+	return max(0, f.Projected-f.Budget)
+}
+
+// OverBudget reports whether the workload is projected to exceed its budget before it has
+func (f Forecast) OverBudget() bool {
+	return f.Overrun() > 0 && f.Ingested <= f.Budget
+}
+
+// ProjectIngestion forecasts the ingestion of every workload with a budget at the end of the budget day,
+// remaining from now, if it keeps ingesting at its burn rate in bytes per second. Workloads without
+// a burn rate are projected at their current ingestion. Forecasts are sorted by workload.
+func ProjectIngestion(
+	ingested map[string]models.GigaBytes,
+	burnRates map[string]float64,
+	budgets map[string]models.GigaBytes,
+	remaining time.Duration,
+) []Forecast {
+	forecasts := make([]Forecast, 0, len(budgets))
+	for workload, b := range budgets {
+		if b <= 0 {
+			continue
+		}
+		burnRate := models.GigaBytes(burnRates[workload] * 3600 / 1000000000.0)
+		forecasts = append(forecasts, Forecast{
+			Workload:  workload,
+			Ingested:  ingested[workload],
+			BurnRate:  burnRate,
+			Projected: ingested[workload] + burnRate*models.GigaBytes(max(0, remaining.Hours())),
+			Budget:    b,
+		})
+	}
+	sort.Slice(forecasts, func(i, j int) bool { return forecasts[i].Workload < forecasts[j].Workload })
+	return forecasts
+}
@@ -0,0 +1,35 @@
+package budget
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"configurator/internal/models"
+)
+
+func TestProjectIngestion(t *testing.T) {
+	ingested := map[string]models.GigaBytes{"checkout": 4, "search": 1, "billing": 12}
+	burnRates := map[string]float64{
+		"checkout": 1e9 / 3600.0, // 1GB per hour
+		"search":   0.1e9 / 3600.0,
+	}
+	budgets := map[string]models.GigaBytes{"checkout": 10, "search": 5, "billing": 10, "unused": 0}
+
+	forecasts := ProjectIngestion(ingested, burnRates, budgets, 8*time.Hour)
+	if len(forecasts) != 3 || forecasts[0].Workload != "billing" || forecasts[1].Workload != "checkout" || forecasts[2].Workload != "search" {
+		t.Fatalf("expected forecasts of billing, checkout and search, got %+v", forecasts)
+	}
+
+	billing, checkout, search := forecasts[0], forecasts[1], forecasts[2]
+	if math.Abs(float64(checkout.Projected-12)) > 1e-9 || math.Abs(float64(checkout.Overrun()-2)) > 1e-9 || !checkout.OverBudget() {
+		t.Errorf("expected checkout projected at 12GB, 2GB over budget, got %+v", checkout)
+	}
+	if math.Abs(float64(search.Projected-1.8)) > 1e-9 || search.Overrun() != 0 || search.OverBudget() {
+		t.Errorf("expected search projected at 1.8GB within budget, got %+v", search)
+	}
+	// Workloads already over budget are enforced on their ingestion, not their forecast
+	if billing.Projected != 12 || billing.Overrun() != 2 || billing.OverBudget() {
+		t.Errorf("expected billing projected at its ingestion and not forecast over budget, got %+v", billing)
+	}
+}
@@ -0,0 +1,396 @@
+package budget
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"configurator/internal/models"
+)
+
+// OrgEnv is an organization and environment that must be defined in the budget file
+type OrgEnv struct {
+	Org string
+	Env string
+}
+
+// Problem is an issue found in a budget file, Line is 0 if it has no position
+type Problem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// Lint checks the budget file at path for problems that loading it silently accepts:
+// unknown or misspelled keys, invalid or negative budgets, duplicate names, invalid pools,
+// exemptions and overrides, and org/env pairs in required that are not defined.
+func Lint(path string, required []OrgEnv) ([]Problem, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading budget file: %w", err)
+	}
+	return LintContent(content, required), nil
+}
+
+// LintContent checks the content of a budget file, see Lint
+func LintContent(content []byte, required []OrgEnv) []Problem {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return []Problem{{Message: err.Error()}}
+	}
+
+	l := &linter{orgs: make(map[string]*lintedOrg)}
+	if len(doc.Content) > 0 {
+		l.budget(doc.Content[0])
+	}
+	l.required(required)
+
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		if l.problems[i].Line != l.problems[j].Line {
+			return l.problems[i].Line < l.problems[j].Line
+		}
+		return l.problems[i].Column < l.problems[j].Column
+	})
+	return l.problems
+}
+
+// linter collects the problems of a budget file
+type linter struct {
+	problems []Problem
+	// orgsNode is the orgs list, nil if missing
+	orgsNode *yaml.Node
+	orgs     map[string]*lintedOrg
+}
+
+// lintedOrg records where an organization and its environments are defined
+type lintedOrg struct {
+	node *yaml.Node
+	envs map[string]*yaml.Node
+}
+
+func (l *linter) add(n *yaml.Node, format string, args ...interface{}) {
+	p := Problem{Message: fmt.Sprintf(format, args...)}
+	if n != nil {
+		p.Line, p.Column = n.Line, n.Column
+	}
+	l.problems = append(l.problems, p)
+}
+
+func (l *linter) budget(root *yaml.Node) {
+	fields := l.mapping(root, "budget file", Budget{})
+	if fields == nil {
+		return
+	}
+
+	l.orgsNode = fields["orgs"]
+	for _, org := range l.sequence(fields["orgs"], "orgs") {
+		l.organization(org)
+	}
+
+	for i, n := range l.sequence(fields["exemptions"], "exemptions") {
+		if l.mapping(n, "exemption", Exemption{}) == nil {
+			continue
+		}
+		var e Exemption
+		if err := n.Decode(&e); err != nil {
+			l.add(n, "exemption %d: %v", i, err)
+			continue
+		}
+		if err := e.Validate(); err != nil {
+			l.add(n, "exemption %d (%s): %s", i, e.Workload, strings.ReplaceAll(err.Error(), "\n", ", "))
+		}
+	}
+
+	for i, n := range l.sequence(fields["overrides"], "overrides") {
+		if l.mapping(n, "override", Override{}) == nil {
+			continue
+		}
+		var o Override
+		if err := n.Decode(&o); err != nil {
+			l.add(n, "override %d: %v", i, err)
+			continue
+		}
+		if err := o.Validate(); err != nil {
+			l.add(n, "override %d (%s): %s", i, o.Name, strings.ReplaceAll(err.Error(), "\n", ", "))
+		}
+	}
+}
+
+func (l *linter) organization(n *yaml.Node) {
+	fields := l.mapping(n, "organization", Organization{})
+	if fields == nil {
+		return
+	}
+	name, ok := l.name(n, fields, "organization")
+	if !ok {
+		return
+	}
+
+	org, seen := l.orgs[name]
+	if seen {
+		l.add(n, "organization %q is listed more than once, first at line %d", name, org.node.Line)
+	} else {
+		org = &lintedOrg{node: n, envs: make(map[string]*yaml.Node)}
+		l.orgs[name] = org
+	}
+
+	for _, env := range l.sequence(fields["envs"], "envs") {
+		l.environment(org, name, env)
+	}
+}
+
+func (l *linter) environment(org *lintedOrg, orgName string, n *yaml.Node) {
+	fields := l.mapping(n, "environment", Environment{})
+	if fields == nil {
+		return
+	}
+	name, ok := l.name(n, fields, "environment")
+	if !ok {
+		return
+	}
+	if first, seen := org.envs[name]; seen {
+		l.add(n, "%s/%s: environment is listed more than once, first at line %d", orgName, name, first.Line)
+	} else {
+		org.envs[name] = n
+	}
+	prefix := orgName + "/" + name
+	l.size(fields["daily_ingestion_cap"], prefix, "daily_ingestion_cap", false)
+
+	workloads := make(map[string]*yaml.Node)
+	for _, w := range l.sequence(fields["workloads"], "workloads") {
+		wFields := l.mapping(w, "workload", Workload{})
+		if wFields == nil {
+			continue
+		}
+		wName, ok := l.name(w, wFields, prefix+": workload")
+		if !ok {
+			continue
+		}
+		if ns := wFields["namespace"]; ns != nil {
+			wName = models.WorkloadKey(ns.Value, wName)
+		}
+		if first, seen := workloads[wName]; seen {
+			l.add(w, "%s/%s: workload is listed more than once, first at line %d", prefix, wName, first.Line)
+		} else {
+			workloads[wName] = w
+		}
+		l.size(wFields["daily_ingestion_budget"], prefix+"/"+wName, "daily_ingestion_budget", false)
+		l.period(w, wFields, prefix+"/"+wName)
+		l.priority(wFields["priority"], prefix+"/"+wName)
+	}
+
+	pools := make(map[string]*yaml.Node)
+	members := make(map[string]string)
+	for _, p := range l.sequence(fields["pools"], "pools") {
+		pFields := l.mapping(p, "pool", Pool{})
+		if pFields == nil {
+			continue
+		}
+		pName, ok := l.name(p, pFields, prefix+": pool")
+		if !ok {
+			continue
+		}
+		if first, seen := pools[pName]; seen {
+			l.add(p, "%s/%s: pool is listed more than once, first at line %d", prefix, pName, first.Line)
+		} else {
+			pools[pName] = p
+		}
+
+		if b := pFields["daily_ingestion_budget"]; b == nil {
+			l.add(p, "%s/%s: pool has no daily_ingestion_budget", prefix, pName)
+		} else {
+			l.size(b, prefix+"/"+pName, "daily_ingestion_budget", true)
+		}
+
+		poolMembers := l.sequence(pFields["members"], "members")
+		selector := pFields["selector"]
+		if len(poolMembers) == 0 && selector == nil {
+			l.add(p, "%s/%s: pool needs members or a selector", prefix, pName)
+		}
+		if selector != nil {
+			if _, err := compileSelector(selector.Value); err != nil {
+				l.add(selector, "%s/%s: invalid pool selector: %v", prefix, pName, err)
+			}
+		}
+		for _, m := range poolMembers {
+			if other, ok := members[m.Value]; ok {
+				l.add(m, "%s/%s: workload %s is already a member of pool %s", prefix, pName, m.Value, other)
+				continue
+			}
+			members[m.Value] = pName
+		}
+	}
+}
+
+// size checks a daily_ingestion_budget or daily_ingestion_cap value
+func (l *linter) size(n *yaml.Node, prefix, key string, positive bool) {
+	if n == nil {
+		return
+	}
+	if n.Kind != yaml.ScalarNode {
+		l.add(n, "%s: %s must be a number with an optional unit", prefix, key)
+		return
+	}
+	s, err := ParseSize(n.Value)
+	switch {
+	case err != nil:
+		l.add(n, "%s: %v", prefix, err)
+	case s < 0:
+		l.add(n, "%s: %s must not be negative", prefix, key)
+	case positive && s == 0:
+		l.add(n, "%s: %s must be positive", prefix, key)
+	}
+}
+
+// priority checks the priority weight of a workload
+func (l *linter) priority(n *yaml.Node, prefix string) {
+	if n == nil {
+		return
+	}
+	var priority float64
+	if err := n.Decode(&priority); err != nil || priority < 0 {
+		l.add(n, "%s: priority must be a non-negative number", prefix)
+	}
+}
+
+// period checks the period, carry_over and prorate of a workload
+func (l *linter) period(n *yaml.Node, fields map[string]*yaml.Node, prefix string) {
+	var w Workload
+	if err := n.Decode(&struct {
+		Period    *string `yaml:"period"`
+		CarryOver *bool   `yaml:"carry_over"`
+		Prorate   *bool   `yaml:"prorate"`
+	}{&w.Period, &w.CarryOver, &w.Prorate}); err != nil {
+		l.add(n, "%s: %v", prefix, err)
+		return
+	}
+	if err := w.validatePeriod(); err != nil {
+		node := n
+		if period := fields["period"]; period != nil {
+			node = period
+		}
+		l.add(node, "%s: %v", prefix, err)
+	}
+}
+
+// required reports the org/env pairs that are not defined, at the organization if it exists
+func (l *linter) required(required []OrgEnv) {
+	for _, r := range required {
+		org, ok := l.orgs[r.Org]
+		if !ok {
+			l.add(l.orgsNode, "organization %q is not defined, but env %q of it is used", r.Org, r.Env)
+			continue
+		}
+		if _, ok := org.envs[r.Env]; !ok {
+			l.add(org.node, "%s: environment %q is not defined", r.Org, r.Env)
+		}
+	}
+}
+
+// mapping checks that n is a mapping with only the keys of the koanf tags of v and returns its values by key
+func (l *linter) mapping(n *yaml.Node, what string, v interface{}) map[string]*yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		l.add(n, "%s must be a mapping", what)
+		return nil
+	}
+
+	known := knownKeys(reflect.TypeOf(v))
+	fields := make(map[string]*yaml.Node, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if _, ok := fields[key.Value]; ok {
+			l.add(key, "key %q is set more than once", key.Value)
+			continue
+		}
+		if !slices.Contains(known, key.Value) {
+			msg := fmt.Sprintf("unknown key %q in %s", key.Value, what)
+			if suggestion := closestKey(key.Value, known); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			l.add(key, "%s", msg)
+			continue
+		}
+		if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+			continue
+		}
+		fields[key.Value] = value
+	}
+	return fields
+}
+
+// sequence checks that n is a list, a missing list is empty
+func (l *linter) sequence(n *yaml.Node, key string) []*yaml.Node {
+	if n == nil {
+		return nil
+	}
+	if n.Kind != yaml.SequenceNode {
+		l.add(n, "%s must be a list", key)
+		return nil
+	}
+	return n.Content
+}
+
+// name returns the name field of a mapping, reporting it if missing
+func (l *linter) name(n *yaml.Node, fields map[string]*yaml.Node, what string) (string, bool) {
+	name := fields["name"]
+	if name == nil || name.Kind != yaml.ScalarNode || name.Value == "" {
+		l.add(n, "%s has no name", what)
+		return "", false
+	}
+	return name.Value, true
+}
+
+// knownKeys returns the koanf keys of a struct type
+func knownKeys(t reflect.Type) []string {
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("koanf"); tag != "" {
+			keys = append(keys, tag)
+		}
+	}
+	return keys
+}
+
+// closestKey returns the known key a misspelled key most likely meant, empty if none is close
+func closestKey(key string, known []string) string {
+	best, bestDistance := "", len(key)/3+1
+	for _, k := range known {
+		if d := editDistance(strings.ToLower(key), k); d <= bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
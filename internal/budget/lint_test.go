@@ -0,0 +1,54 @@
+package budget
+
+import (
+	"testing"
+)
+
+func TestLintContent(t *testing.T) {
+	content := `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: api
+            daily_ingestion_budet: 10
+          - name: api
+            daily_ingestion_budget: -5
+          - name: web
+            daily_ingestion_budget: 3G
+  - name: invest
+`
+	problems := LintContent([]byte(content), []OrgEnv{{Org: "invest", Env: "stage"}, {Org: "invest", Env: "prod"}})
+
+	want := []Problem{
+		{Line: 3, Column: 5, Message: `invest: environment "stage" is not defined`},
+		{Line: 8, Column: 13, Message: `unknown key "daily_ingestion_budet" in workload, did you mean "daily_ingestion_budget"?`},
+		{Line: 9, Column: 13, Message: "invest/prod/api: workload is listed more than once, first at line 7"},
+		{Line: 10, Column: 37, Message: "invest/prod/api: daily_ingestion_budget must not be negative"},
+		{Line: 12, Column: 37},
+		{Line: 13, Column: 5, Message: `organization "invest" is listed more than once, first at line 3`},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %d: %v", len(want), len(problems), problems)
+	}
+	for i, p := range problems {
+		if p.Line != want[i].Line || p.Column != want[i].Column {
+			t.Errorf("expected problem %d at %d:%d, got %s", i, want[i].Line, want[i].Column, p)
+		}
+		if want[i].Message != "" && p.Message != want[i].Message {
+			t.Errorf("expected problem %d to be %q, got %q", i, want[i].Message, p.Message)
+		}
+	}
+}
+
+func TestLintContentValid(t *testing.T) {
+	if problems := LintContent([]byte(poolBudget), []OrgEnv{{Org: "invest", Env: "prod"}}); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	problems := LintContent([]byte("orgs: [\n"), nil)
+	if len(problems) != 1 {
+		t.Fatalf("expected a syntax error, got %v", problems)
+	}
+}
@@ -0,0 +1,174 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	"configurator/internal/models"
+)
+
+// Enforcement modes of a LogBudget
+const (
+	// EnforcementModeEnforce samples the selected workloads once they exceed the budget
+	EnforcementModeEnforce = "enforce"
+	// EnforcementModeMonitor only reports the selected workloads exceeding the budget
+	EnforcementModeMonitor = "monitor"
+)
+
+// sourceBudgetFile names budget.yaml as the source of a workload budget
+const sourceBudgetFile = "budget.yaml"
+
+// LogBudget is a namespaced custom resource through which a team sets the budget of its workloads
+type LogBudget struct {
+	Namespace         string
+	Name              string
+	Generation        int64
+	CreationTimestamp time.Time
+	Spec              LogBudgetSpec
+	// SpecError is set if the spec could not be read
+	SpecError error
+}
+
+// LogBudgetSpec is the spec of a LogBudget
+type LogBudgetSpec struct {
+	Selector             LogBudgetSelector `json:"selector"`
+	DailyIngestionBudget Size              `json:"dailyIngestionBudget"`
+	EnforcementMode      string            `json:"enforcementMode,omitempty"`
+}
+
+// LogBudgetSelector selects workloads by name or by a regular expression on the whole name
+type LogBudgetSelector struct {
+	Workloads []string `json:"workloads,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+}
+
+// LogBudgetStatus is the status reported back to a LogBudget
+type LogBudgetStatus struct {
+	ObservedGeneration int64                     `json:"observedGeneration"`
+	LastUpdated        time.Time                 `json:"lastUpdated"`
+	EnforcementMode    string                    `json:"enforcementMode,omitempty"`
+	Workloads          []LogBudgetWorkloadStatus `json:"workloads"`
+	// Error explains why an invalid LogBudget is ignored
+	Error string `json:"error,omitempty"`
+}
+
+// LogBudgetWorkloadStatus is the state of a workload selected by a LogBudget
+type LogBudgetWorkloadStatus struct {
+	Workload           string  `json:"workload"`
+	Target             string  `json:"target,omitempty"`
+	CurrentIngestionGB float64 `json:"currentIngestionGB"`
+	BudgetGB           float64 `json:"budgetGB"`
+	SamplingPercentage float64 `json:"samplingPercentage"`
+	// OverriddenBy is budget.yaml or the LogBudget whose budget applies instead, empty if this one applies
+	OverriddenBy string `json:"overriddenBy,omitempty"`
+}
+
+// Ref returns the namespace/name of the LogBudget
+func (lb LogBudget) Ref() string {
+	return lb.Namespace + "/" + lb.Name
+}
+
+// Mode returns the enforcement mode, enforce if none is set
+func (lb LogBudget) Mode() string {
+	if lb.Spec.EnforcementMode == "" {
+		return EnforcementModeEnforce
+	}
+	return lb.Spec.EnforcementMode
+}
+
+// Validate checks that the LogBudget selects workloads and has a positive budget and a known enforcement mode
+func (lb LogBudget) Validate() error {
+	if lb.SpecError != nil {
+		return fmt.Errorf("invalid spec: %w", lb.SpecError)
+	}
+	var errs []error
+	if len(lb.Spec.Selector.Workloads) == 0 && lb.Spec.Selector.Pattern == "" {
+		errs = append(errs, errors.New("selector needs workloads or a pattern"))
+	}
+	if _, err := compileSelector(lb.Spec.Selector.Pattern); err != nil {
+		errs = append(errs, fmt.Errorf("invalid selector pattern: %w", err))
+	}
+	if lb.Spec.DailyIngestionBudget <= 0 {
+		errs = append(errs, errors.New("dailyIngestionBudget must be positive"))
+	}
+	if mode := lb.Mode(); mode != EnforcementModeEnforce && mode != EnforcementModeMonitor {
+		errs = append(errs, fmt.Errorf("unknown enforcementMode %q, use %s or %s", mode, EnforcementModeEnforce, EnforcementModeMonitor))
+	}
+	return errors.Join(errs...)
+}
+
+// selects reports whether the LogBudget selects the workload key, pattern is its compiled selector pattern.
+// Only workloads in the namespace of the LogBudget are selected, so a team can not set the budget of
+// another team's workloads. Workload keys without a namespace, and any workload if keys are not namespaced,
+// are never selected.
+func (lb LogBudget) selects(workload string, pattern *regexp.Regexp, namespaced bool) bool {
+	namespace, name := models.SplitWorkloadKey(workload, namespaced)
+	if namespace == "" || namespace != lb.Namespace {
+		return false
+	}
+	if slices.Contains(lb.Spec.Selector.Workloads, name) {
+		return true
+	}
+	return pattern != nil && pattern.MatchString(name)
+}
+
+// LogBudgetAssignment is a workload selected by a LogBudget
+type LogBudgetAssignment struct {
+	Workload  string
+	LogBudget LogBudget
+	// OverriddenBy is budget.yaml or the LogBudget whose budget applies instead, empty if this one applies
+	OverriddenBy string
+}
+
+// AssignLogBudgets matches the valid LogBudgets against the known workload keys, namespaced is set if they are namespace/name.
+// A budget in budget.yaml takes precedence over any LogBudget, and of several LogBudgets selecting
+// the same workload the oldest one applies.
+func AssignLogBudgets(logBudgets []LogBudget, workloads []string, configured map[string]models.GigaBytes, namespaced bool) []LogBudgetAssignment {
+	ordered := make([]LogBudget, 0, len(logBudgets))
+	for _, lb := range logBudgets {
+		if lb.Validate() == nil {
+			ordered = append(ordered, lb)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if !ordered[i].CreationTimestamp.Equal(ordered[j].CreationTimestamp) {
+			return ordered[i].CreationTimestamp.Before(ordered[j].CreationTimestamp)
+		}
+		return ordered[i].Ref() < ordered[j].Ref()
+	})
+	patterns := make([]*regexp.Regexp, len(ordered))
+	for i, lb := range ordered {
+		// Validate compiled the pattern already
+		patterns[i], _ = compileSelector(lb.Spec.Selector.Pattern)
+	}
+
+	sorted := append([]string(nil), workloads...)
+	sort.Strings(sorted)
+	sorted = slices.Compact(sorted)
+
+	var assignments []LogBudgetAssignment
+	for _, workload := range sorted {
+		owner := ""
+		if _, ok := configured[workload]; ok {
+			owner = sourceBudgetFile
+		}
+		for i, lb := range ordered {
+			if !lb.selects(workload, patterns[i], namespaced) {
+				continue
+			}
+			assignments = append(assignments, LogBudgetAssignment{
+				Workload:     workload,
+				LogBudget:    lb,
+				OverriddenBy: owner,
+			})
+			if owner == "" {
+				owner = lb.Ref()
+			}
+		}
+	}
+	return assignments
+}
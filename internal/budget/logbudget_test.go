@@ -0,0 +1,108 @@
+package budget
+
+import (
+	"testing"
+	"time"
+
+	"configurator/internal/models"
+)
+
+func TestAssignLogBudgets(t *testing.T) {
+	now := time.Now()
+	logBudgets := []LogBudget{
+		{
+			Namespace:         "search",
+			Name:              "search",
+			CreationTimestamp: now,
+			Spec: LogBudgetSpec{
+				Selector:             LogBudgetSelector{Pattern: "search-.*"},
+				DailyIngestionBudget: 20,
+			},
+		},
+		{
+			Namespace:         "payments",
+			Name:              "payments",
+			CreationTimestamp: now.Add(-time.Hour),
+			Spec: LogBudgetSpec{
+				Selector:             LogBudgetSelector{Workloads: []string{"payments-api", "search-payments"}},
+				DailyIngestionBudget: 50,
+				EnforcementMode:      EnforcementModeMonitor,
+			},
+		},
+		{
+			Namespace: "invalid",
+			Name:      "invalid",
+			Spec:      LogBudgetSpec{Selector: LogBudgetSelector{Workloads: []string{"api"}}},
+		},
+	}
+	configured := map[string]models.GigaBytes{"payments/payments-api": 10}
+
+	workloads := []string{"search/search-indexer", "payments/payments-api", "payments/search-payments", "search/search-payments", "api", "api"}
+	got := AssignLogBudgets(logBudgets, workloads, configured, true)
+
+	want := []struct {
+		workload, logBudget, overriddenBy string
+	}{
+		{"payments/payments-api", "payments/payments", "budget.yaml"},
+		{"payments/search-payments", "payments/payments", ""},
+		{"search/search-indexer", "search/search", ""},
+		{"search/search-payments", "search/search", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d assignments, got %+v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Workload != w.workload || got[i].LogBudget.Ref() != w.logBudget || got[i].OverriddenBy != w.overriddenBy {
+			t.Errorf("expected assignment %d to be %+v, got %s from %s overridden by %q", i, w, got[i].Workload, got[i].LogBudget.Ref(), got[i].OverriddenBy)
+		}
+	}
+}
+
+func TestAssignLogBudgetsOtherNamespace(t *testing.T) {
+	// A LogBudget in namespace a naming a workload of namespace b
+	logBudgets := []LogBudget{{
+		Namespace: "a",
+		Name:      "takeover",
+		Spec: LogBudgetSpec{
+			Selector:             LogBudgetSelector{Workloads: []string{"checkout"}, Pattern: ".*"},
+			DailyIngestionBudget: 1000,
+			EnforcementMode:      EnforcementModeMonitor,
+		},
+	}}
+
+	got := AssignLogBudgets(logBudgets, []string{"b/checkout", "checkout"}, nil, true)
+
+	if len(got) != 0 {
+		t.Fatalf("expected no workloads outside namespace a to be selected, got %+v", got)
+	}
+
+	// Without namespaces a/checkout is a workload name, not namespace a
+	if got := AssignLogBudgets(logBudgets, []string{"a/checkout"}, nil, false); len(got) != 0 {
+		t.Fatalf("expected no workloads to be selected without namespaces, got %+v", got)
+	}
+}
+
+func TestLogBudgetValidate(t *testing.T) {
+	valid := LogBudget{Spec: LogBudgetSpec{
+		Selector:             LogBudgetSelector{Workloads: []string{"api"}},
+		DailyIngestionBudget: 1,
+	}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if valid.Mode() != EnforcementModeEnforce {
+		t.Errorf("expected enforce as the default mode, got %s", valid.Mode())
+	}
+
+	invalid := []LogBudgetSpec{
+		{DailyIngestionBudget: 1},
+		{Selector: LogBudgetSelector{Pattern: "a("}, DailyIngestionBudget: 1},
+		{Selector: LogBudgetSelector{Workloads: []string{"api"}}},
+		{Selector: LogBudgetSelector{Workloads: []string{"api"}}, DailyIngestionBudget: 1, EnforcementMode: "dry-run"},
+	}
+	for _, spec := range invalid {
+		if err := (LogBudget{Spec: spec}).Validate(); err == nil {
+			t.Errorf("expected error for %+v", spec)
+		}
+	}
+}
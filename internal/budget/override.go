@@ -0,0 +1,187 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/robfig/cron/v3"
+
+	"configurator/internal/models"
+)
+
+// dateLayouts are the accepted formats of the from and to dates of an override.
+// Dates without a time zone are read in the scheduling time zone.
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// Override changes budgets during a planned event, e.g. a sale, either between two dates or in
+// the windows starting at each activation of a cron expression. It scales the budgets with
+// Multiplier or replaces them with DailyIngestionBudget.
+type Override struct {
+	Name string `koanf:"name" yaml:"name"`
+	// Org, Env and Workloads limit the override, empty matches all
+	Org       string   `koanf:"org" yaml:"org"`
+	Env       string   `koanf:"env" yaml:"env"`
+	Workloads []string `koanf:"workloads" yaml:"workloads"`
+	// From and To are dates like 2025-10-01 or times like 2025-10-01T09:00, To is exclusive.
+	// A To without a time covers that whole day.
+	From string `koanf:"from" yaml:"from"`
+	To   string `koanf:"to" yaml:"to"`
+	// Cron starts a window of Duration at each activation
+	Cron                 string        `koanf:"cron" yaml:"cron"`
+	Duration             time.Duration `koanf:"duration" yaml:"duration"`
+	Multiplier           float64       `koanf:"multiplier" yaml:"multiplier"`
+	DailyIngestionBudget Size          `koanf:"daily_ingestion_budget" yaml:"daily_ingestion_budget"`
+}
+
+// Validate checks that the override has a name, either dates or a cron window, and either a multiplier or a budget
+func (o Override) Validate() error {
+	var errs []error
+	if o.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+
+	dates := o.From != "" || o.To != ""
+	window := o.Cron != "" || o.Duration != 0
+	switch {
+	case dates && window:
+		errs = append(errs, errors.New("use either from/to or cron/duration, not both"))
+	case dates:
+		from, fromErr := parseOverrideDate(o.From, false, time.UTC)
+		to, toErr := parseOverrideDate(o.To, true, time.UTC)
+		if fromErr != nil {
+			errs = append(errs, fmt.Errorf("invalid from: %w", fromErr))
+		}
+		if toErr != nil {
+			errs = append(errs, fmt.Errorf("invalid to: %w", toErr))
+		}
+		if fromErr == nil && toErr == nil && !to.After(from) {
+			errs = append(errs, errors.New("to must be after from"))
+		}
+	case window:
+		if _, err := cron.ParseStandard(o.Cron); err != nil {
+			errs = append(errs, fmt.Errorf("invalid cron: %w", err))
+		}
+		if o.Duration <= 0 {
+			errs = append(errs, errors.New("duration must be positive"))
+		}
+	default:
+		errs = append(errs, errors.New("from/to or cron/duration is required"))
+	}
+
+	switch {
+	case o.Multiplier < 0 || o.DailyIngestionBudget < 0:
+		errs = append(errs, errors.New("multiplier and daily_ingestion_budget must not be negative"))
+	case o.Multiplier > 0 && o.DailyIngestionBudget > 0:
+		errs = append(errs, errors.New("use either multiplier or daily_ingestion_budget, not both"))
+	case o.Multiplier == 0 && o.DailyIngestionBudget == 0:
+		errs = append(errs, errors.New("multiplier or daily_ingestion_budget is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// parseOverrideDate parses a from or to date in loc. A date without a time ends the day if end is set.
+func parseOverrideDate(value string, end bool, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("date is required")
+	}
+	for _, layout := range dateLayouts {
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			continue
+		}
+		if end && layout == "2006-01-02" {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date like 2006-01-02 or 2006-01-02T15:04", value)
+}
+
+// timestampToStringHookFunc turns the timestamps the YAML parser makes of unquoted dates back into strings,
+// so that the dates of overrides are read in the scheduling time zone rather than UTC
+func timestampToStringHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		ts, ok := data.(time.Time)
+		if !ok || t.Kind() != reflect.String {
+			return data, nil
+		}
+		if ts.Location() != time.UTC {
+			return ts.Format(time.RFC3339), nil
+		}
+		if ts.Equal(ts.Truncate(24 * time.Hour)) {
+			return ts.Format("2006-01-02"), nil
+		}
+		return ts.Format("2006-01-02T15:04:05"), nil
+	}
+}
+
+// Active reports whether the override is in effect at now, dates without a time zone are read in now's location
+func (o Override) Active(now time.Time) bool {
+	if o.Cron != "" {
+		schedule, err := cron.ParseStandard(o.Cron)
+		if err != nil {
+			return false
+		}
+		// The window of the last activation before now is still open
+		return !schedule.Next(now.Add(-o.Duration)).After(now)
+	}
+
+	from, err := parseOverrideDate(o.From, false, now.Location())
+	if err != nil {
+		return false
+	}
+	to, err := parseOverrideDate(o.To, true, now.Location())
+	if err != nil {
+		return false
+	}
+	return !now.Before(from) && now.Before(to)
+}
+
+// Matches reports whether the override applies to the workload of the org and env, namespaced is set
+// if the workload key is namespace/name
+func (o Override) Matches(org, env, workload string, namespaced bool) bool {
+	return (o.Org == "" || o.Org == org) &&
+		(o.Env == "" || o.Env == env) &&
+		(len(o.Workloads) == 0 || slices.ContainsFunc(o.Workloads, func(listed string) bool {
+			return models.MatchesWorkload(listed, workload, namespaced)
+		}))
+}
+
+// Apply returns the budget changed by the override
+func (o Override) Apply(budget models.GigaBytes) models.GigaBytes {
+	if o.DailyIngestionBudget > 0 {
+		return o.DailyIngestionBudget.GigaBytes()
+	}
+	return budget * models.GigaBytes(o.Multiplier)
+}
+
+// ActiveOverrides returns the overrides of the org and env in effect at now, in the order of budget.yaml
+func (b *Budget) ActiveOverrides(orgName, envName string, now time.Time) []Override {
+	var active []Override
+	for _, o := range b.Overrides {
+		if (o.Org == "" || o.Org == orgName) && (o.Env == "" || o.Env == envName) && o.Active(now) {
+			active = append(active, o)
+		}
+	}
+	return active
+}
+
+// ApplyOverrides changes the budgets of the workloads matched by an active override, except those in skip.
+// If several overrides match a workload, the last one in budget.yaml applies.
+func ApplyOverrides(active []Override, orgName, envName string, namespaced bool, budgets map[string]models.GigaBytes, skip map[string]models.GigaBytes) {
+	for workload, b := range budgets {
+		if _, ok := skip[workload]; ok {
+			continue
+		}
+		for i := len(active) - 1; i >= 0; i-- {
+			if active[i].Matches(orgName, envName, workload, namespaced) {
+				budgets[workload] = active[i].Apply(b)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"configurator/internal/models"
+)
+
+const overrideBudget = `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: checkout
+            daily_ingestion_budget: 10
+          - name: search
+            daily_ingestion_budget: 4
+overrides:
+  - name: diwali-sale
+    org: invest
+    from: 2025-10-20
+    to: 2025-10-22
+    multiplier: 2
+  - name: checkout-launch
+    workloads: [checkout, payments]
+    from: 2025-10-21T09:00
+    to: 2025-10-21T18:00
+    daily_ingestion_budget: 50GB
+  - name: payments-peak
+    workloads: [payments]
+    from: 2025-10-21T15:00
+    to: 2025-10-21T16:00
+    multiplier: 2
+  - name: month-end
+    cron: "0 0 28 * *"
+    duration: 72h
+    multiplier: 1.5
+`
+
+func TestOverrideActive(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	dates := Override{From: "2025-10-20", To: "2025-10-22"}
+	window := Override{Cron: "0 0 28 * *", Duration: 72 * time.Hour}
+
+	tests := []struct {
+		name     string
+		override Override
+		now      time.Time
+		want     bool
+	}{
+		{name: "Before from", override: dates, now: time.Date(2025, 10, 19, 23, 59, 0, 0, loc), want: false},
+		{name: "At from", override: dates, now: time.Date(2025, 10, 20, 0, 0, 0, 0, loc), want: true},
+		{name: "Last day of a date range", override: dates, now: time.Date(2025, 10, 22, 23, 0, 0, 0, loc), want: true},
+		{name: "After to", override: dates, now: time.Date(2025, 10, 23, 0, 0, 0, 0, loc), want: false},
+		{name: "Inside a cron window", override: window, now: time.Date(2025, 10, 30, 12, 0, 0, 0, loc), want: true},
+		{name: "After a cron window", override: window, now: time.Date(2025, 10, 31, 0, 0, 0, 0, loc), want: false},
+		{name: "Before a cron window", override: window, now: time.Date(2025, 10, 27, 23, 0, 0, 0, loc), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.override.Active(tt.now); got != tt.want {
+				t.Errorf("expected active %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOverrideValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		override Override
+		wantErr  bool
+	}{
+		{name: "Dates", override: Override{Name: "sale", From: "2025-10-20", To: "2025-10-21", Multiplier: 2}},
+		{name: "Cron window", override: Override{Name: "sale", Cron: "0 9 * * 5", Duration: 8 * time.Hour, DailyIngestionBudget: 20}},
+		{name: "Missing name", override: Override{From: "2025-10-20", To: "2025-10-21", Multiplier: 2}, wantErr: true},
+		{name: "Dates and cron", override: Override{Name: "sale", From: "2025-10-20", To: "2025-10-21", Cron: "0 9 * * 5", Duration: time.Hour, Multiplier: 2}, wantErr: true},
+		{name: "To before from", override: Override{Name: "sale", From: "2025-10-21", To: "2025-10-20", Multiplier: 2}, wantErr: true},
+		{name: "Invalid date", override: Override{Name: "sale", From: "20/10/2025", To: "2025-10-21", Multiplier: 2}, wantErr: true},
+		{name: "Cron without duration", override: Override{Name: "sale", Cron: "0 9 * * 5", Multiplier: 2}, wantErr: true},
+		{name: "Multiplier and budget", override: Override{Name: "sale", From: "2025-10-20", To: "2025-10-21", Multiplier: 2, DailyIngestionBudget: 20}, wantErr: true},
+		{name: "Neither multiplier nor budget", override: Override{Name: "sale", From: "2025-10-20", To: "2025-10-21"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.override.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	active := []Override{
+		{Name: "all", Multiplier: 2},
+		{Name: "checkout", Workloads: []string{"checkout"}, DailyIngestionBudget: 50},
+		{Name: "other env", Env: "stage", Multiplier: 10},
+	}
+	// Without namespaces team/checkout is a workload name of its own
+	budgets := map[string]models.GigaBytes{"checkout": 10, "team/checkout": 10, "search": 4, "configured": 3}
+
+	ApplyOverrides(active, "invest", "prod", false, budgets, map[string]models.GigaBytes{"configured": 3})
+
+	want := map[string]models.GigaBytes{"checkout": 50, "team/checkout": 20, "search": 8, "configured": 3}
+	for workload, w := range want {
+		if budgets[workload] != w {
+			t.Errorf("expected budget of %s to be %v, got %v", workload, w, budgets[workload])
+		}
+	}
+}
+
+func TestExtractBudgetOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.yaml")
+	if err := os.WriteFile(path, []byte(overrideBudget), 0o600); err != nil {
+		t.Fatalf("failed to write budget: %v", err)
+	}
+	b, err := New(path, true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("expected a valid budget, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want map[string]models.GigaBytes
+	}{
+		{
+			name: "No override",
+			now:  time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC),
+			want: map[string]models.GigaBytes{"checkout": 10, "search": 4},
+		},
+		{
+			name: "Sale",
+			now:  time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC),
+			want: map[string]models.GigaBytes{"checkout": 20, "search": 8},
+		},
+		{
+			name: "Launch during the sale",
+			now:  time.Date(2025, 10, 21, 12, 0, 0, 0, time.UTC),
+			want: map[string]models.GigaBytes{"checkout": 50, "search": 8, "payments": 50},
+		},
+		{
+			// The multiplier comes after the launch in the file, so it scales the launch budget of payments
+			name: "Peak during the launch",
+			now:  time.Date(2025, 10, 21, 15, 30, 0, 0, time.UTC),
+			want: map[string]models.GigaBytes{"checkout": 50, "search": 8, "payments": 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budgets, err := b.ExtractBudget("invest", "prod", tt.now)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(budgets) != len(tt.want) {
+				t.Fatalf("expected %d budgets, got %v", len(tt.want), budgets)
+			}
+			for workload, w := range tt.want {
+				if budgets[workload] != w {
+					t.Errorf("expected budget of %s to be %v, got %v", workload, w, budgets[workload])
+				}
+			}
+		})
+	}
+
+	if problems := LintContent([]byte(overrideBudget), nil); len(problems) != 0 {
+		t.Errorf("expected no lint problems, got %v", problems)
+	}
+	problems := LintContent([]byte("overrides:\n  - name: sale\n    from: 2025-10-20\n"), nil)
+	if len(problems) != 1 || problems[0].Line != 2 {
+		t.Errorf("expected one problem at line 2, got %v", problems)
+	}
+}
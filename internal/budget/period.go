@@ -0,0 +1,92 @@
+package budget
+
+import (
+	"time"
+
+	"configurator/internal/models"
+)
+
+// Budget periods of a workload
+const (
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+// PeriodBudget is the budget of a workload enforced over a week or a month instead of the budget day
+type PeriodBudget struct {
+	Workload string
+	Period   string
+	// Daily is the daily budget of the workload from budget.yaml, a LogBudget or the budget strategy
+	// with active overrides applied, the period budget is Daily times the days of the period
+	Daily models.GigaBytes
+	// CarryOver adds the budget left unused in the previous period
+	CarryOver bool
+	// Prorate only allows the share of the period budget for the elapsed part of the period
+	Prorate bool
+}
+
+// PeriodStart returns the start of the period containing now in now's location.
+// Weeks start on Monday, months on the first day.
+func PeriodStart(period string, now time.Time) time.Time {
+	year, month, day := now.Date()
+	switch period {
+	case PeriodWeekly:
+		sinceMonday := (int(now.Weekday()) + 6) % 7
+		return time.Date(year, month, day-sinceMonday, 0, 0, 0, 0, now.Location())
+	case PeriodMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	}
+}
+
+// PeriodEnd returns the start of the period following the one starting at start
+func PeriodEnd(period string, start time.Time) time.Time {
+	switch period {
+	case PeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// periodDays returns the number of calendar days of the period starting at start
+func periodDays(period string, start time.Time) int {
+	end := PeriodEnd(period, start)
+	days := 0
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		days++
+	}
+	return days
+}
+
+// Total returns the budget of the period starting at start
+func (p PeriodBudget) Total(start time.Time) models.GigaBytes {
+	return p.Daily * models.GigaBytes(periodDays(p.Period, start))
+}
+
+// CarriedOver returns the budget left unused in the previous period, given its ingestion.
+// Only the previous period is considered, unused budget does not accumulate over several periods.
+func (p PeriodBudget) CarriedOver(now time.Time, previousIngestion models.GigaBytes) models.GigaBytes {
+	if !p.CarryOver {
+		return 0
+	}
+	previousStart := PeriodStart(p.Period, PeriodStart(p.Period, now).Add(-time.Nanosecond))
+	return max(0, p.Total(previousStart)-previousIngestion)
+}
+
+// Allowed returns the budget available at now for the ingestion since the start of the period.
+// Without Prorate it is the whole period budget, with Prorate the share for the elapsed part of the period.
+// Budget carried over from the previous period is available right away.
+func (p PeriodBudget) Allowed(now time.Time, previousIngestion models.GigaBytes) models.GigaBytes {
+	start := PeriodStart(p.Period, now)
+	allowed := p.Total(start)
+	if p.Prorate {
+		elapsed := now.Sub(start).Seconds() / PeriodEnd(p.Period, start).Sub(start).Seconds()
+		allowed = models.GigaBytes(float64(allowed) * elapsed)
+	}
+	return allowed + p.CarriedOver(now, previousIngestion)
+}
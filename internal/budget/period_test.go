@@ -0,0 +1,89 @@
+package budget
+
+import (
+	"testing"
+	"time"
+
+	"configurator/internal/models"
+)
+
+func TestPeriodStart(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	// Wednesday
+	now := time.Date(2025, 3, 5, 14, 30, 0, 0, loc)
+
+	tests := []struct {
+		period string
+		want   time.Time
+		days   int
+	}{
+		{period: PeriodDaily, want: time.Date(2025, 3, 5, 0, 0, 0, 0, loc), days: 1},
+		{period: PeriodWeekly, want: time.Date(2025, 3, 3, 0, 0, 0, 0, loc), days: 7},
+		{period: PeriodMonthly, want: time.Date(2025, 3, 1, 0, 0, 0, 0, loc), days: 31},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			start := PeriodStart(tt.period, now)
+			if !start.Equal(tt.want) {
+				t.Fatalf("expected period start %v, got %v", tt.want, start)
+			}
+			if days := periodDays(tt.period, start); days != tt.days {
+				t.Errorf("expected %d days, got %d", tt.days, days)
+			}
+		})
+	}
+
+	// A Monday starts its own week
+	monday := time.Date(2025, 3, 10, 0, 0, 0, 0, loc)
+	if start := PeriodStart(PeriodWeekly, monday); !start.Equal(monday) {
+		t.Errorf("expected a Monday to start its week, got %v", start)
+	}
+}
+
+func TestPeriodBudgetAllowed(t *testing.T) {
+	// Half of the week has passed
+	now := time.Date(2025, 3, 6, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		budget   PeriodBudget
+		previous models.GigaBytes
+		want     models.GigaBytes
+	}{
+		{name: "Whole period", budget: PeriodBudget{Period: PeriodWeekly, Daily: 10}, want: 70},
+		{name: "Prorated", budget: PeriodBudget{Period: PeriodWeekly, Daily: 10, Prorate: true}, want: 35},
+		{name: "Carry over", budget: PeriodBudget{Period: PeriodWeekly, Daily: 10, CarryOver: true}, previous: 50, want: 90},
+		{name: "Nothing to carry over", budget: PeriodBudget{Period: PeriodWeekly, Daily: 10, CarryOver: true}, previous: 80, want: 70},
+		{name: "Prorated with carry over", budget: PeriodBudget{Period: PeriodWeekly, Daily: 10, CarryOver: true, Prorate: true}, previous: 60, want: 45},
+		// February 2025 has 28 days
+		{name: "Monthly carry over", budget: PeriodBudget{Period: PeriodMonthly, Daily: 1, CarryOver: true}, previous: 20, want: 39},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.budget.Allowed(now, tt.previous)
+			if diff := float64(got - tt.want); diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("expected %v allowed, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidatePeriod(t *testing.T) {
+	tests := []struct {
+		workload Workload
+		wantErr  bool
+	}{
+		{workload: Workload{Name: "a"}},
+		{workload: Workload{Name: "a", Period: PeriodMonthly, CarryOver: true, Prorate: true}},
+		{workload: Workload{Name: "a", Period: "yearly"}, wantErr: true},
+		{workload: Workload{Name: "a", CarryOver: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.workload.validatePeriod(); (err != nil) != tt.wantErr {
+			t.Errorf("validatePeriod(%+v) error = %v, wantErr %v", tt.workload, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"configurator/internal/models"
+)
+
+// Statistics of the daily ingestion a recommendation can be based on
+const (
+	BasisP50 = "p50"
+	BasisP95 = "p95"
+	BasisMax = "max"
+)
+
+// RecommendOptions select the history and the statistic budgets are recommended from
+type RecommendOptions struct {
+	// Days is the number of whole days of ingestion history
+	Days int `json:"days"`
+	// Basis is the daily ingestion statistic the budget is derived from: p50, p95 or max
+	Basis string `json:"basis"`
+	// Headroom is added on top of the basis, e.g. 0.2 for 20%
+	Headroom float64 `json:"headroom"`
+}
+
+// DefaultRecommendOptions recommend the p95 of the last 14 days plus 20%
+var DefaultRecommendOptions = RecommendOptions{Days: 14, Basis: BasisP95, Headroom: 0.2}
+
+// MaxRecommendDays limits the history of a recommendation, longer range queries are expensive for the metrics backend
+const MaxRecommendDays = 90
+
+// Validate checks that the options select a history of at most MaxRecommendDays, a known basis and a non-negative headroom
+func (o RecommendOptions) Validate() error {
+	var errs []error
+	if o.Days <= 0 {
+		errs = append(errs, errors.New("days must be positive"))
+	}
+	if o.Days > MaxRecommendDays {
+		errs = append(errs, fmt.Errorf("days must be at most %d", MaxRecommendDays))
+	}
+	switch o.Basis {
+	case BasisP50, BasisP95, BasisMax:
+	default:
+		errs = append(errs, fmt.Errorf("unknown basis %q, use %s, %s or %s", o.Basis, BasisP50, BasisP95, BasisMax))
+	}
+	if o.Headroom < 0 {
+		errs = append(errs, errors.New("headroom must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Recommendation is the recommended budget of a workload with the statistics of its daily ingestion
+type Recommendation struct {
+	Workload string `json:"workload"`
+	// Days is the number of days with ingestion
+	Days        int              `json:"days"`
+	P50         models.GigaBytes `json:"p50_gb"`
+	P95         models.GigaBytes `json:"p95_gb"`
+	Max         models.GigaBytes `json:"max_gb"`
+	Recommended models.GigaBytes `json:"recommended_gb"`
+	// Current is the daily_ingestion_budget in budget.yaml, nil if the workload is not listed
+	Current *models.GigaBytes `json:"current_gb,omitempty"`
+}
+
+// RecommendationReport holds the recommendations of an environment, the budget.yaml fragment setting them
+// and its unified diff against the budgets currently listed
+type RecommendationReport struct {
+	Target    string           `json:"target,omitempty"`
+	Org       string           `json:"org"`
+	Env       string           `json:"env"`
+	Options   RecommendOptions `json:"options"`
+	Workloads []Recommendation `json:"workloads"`
+	Fragment  string           `json:"fragment"`
+	Diff      string           `json:"diff"`
+}
+
+// Recommend derives a budget for every workload with daily ingestion, the basis statistic plus headroom
+// rounded up, and compares it with the budgets listed for the org and env
+func (b *Budget) Recommend(orgName, envName string, daily map[string][]models.GigaBytes, o RecommendOptions) (RecommendationReport, error) {
+	if err := o.Validate(); err != nil {
+		return RecommendationReport{}, err
+	}
+
+	current := make(map[string]models.GigaBytes)
+	for _, org := range b.Organizations {
+		if org.Name != orgName {
+			continue
+		}
+		for _, env := range org.Environments {
+			if env.Name != envName {
+				continue
+			}
+			for _, w := range env.Workloads {
+				if w.DailyIngestionBudget != nil && *w.DailyIngestionBudget > 0 {
+					current[w.Key()] = w.DailyIngestionBudget.GigaBytes()
+				}
+			}
+		}
+	}
+	current = ResolveWorkloadKeys(current, slices.Collect(maps.Keys(daily)), b.namespaced)
+
+	recommendations := make([]Recommendation, 0, len(daily))
+	for workload, days := range daily {
+		if len(days) == 0 {
+			continue
+		}
+		r := Recommendation{
+			Workload: workload,
+			Days:     len(days),
+			P50:      Percentile(days, 50),
+			P95:      Percentile(days, 95),
+			Max:      Percentile(days, 100),
+		}
+		basis := r.P95
+		switch o.Basis {
+		case BasisP50:
+			basis = r.P50
+		case BasisMax:
+			basis = r.Max
+		}
+		r.Recommended = roundBudget(basis * models.GigaBytes(1+o.Headroom))
+		if c, ok := current[workload]; ok {
+			r.Current = &c
+		}
+		recommendations = append(recommendations, r)
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Workload < recommendations[j].Workload
+	})
+
+	report := RecommendationReport{
+		Org:       orgName,
+		Env:       envName,
+		Options:   o,
+		Workloads: recommendations,
+		Fragment:  recommendationFragment(orgName, envName, recommendations, false, b.namespaced),
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(recommendationFragment(orgName, envName, recommendations, true, b.namespaced)),
+		B:        difflib.SplitLines(report.Fragment),
+		FromFile: "current",
+		ToFile:   "recommended",
+		Context:  3,
+	})
+	if err != nil {
+		return RecommendationReport{}, fmt.Errorf("failed to create unified diff: %w", err)
+	}
+	report.Diff = diff
+	return report, nil
+}
+
+// Percentile returns the p-th percentile of the values, interpolating between the closest ranks
+func Percentile(values []models.GigaBytes, p float64) models.GigaBytes {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]models.GigaBytes(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*models.GigaBytes(rank-float64(lower))
+}
+
+// roundBudget rounds a budget up to 10MB below a gigabyte and to 100MB above
+func roundBudget(gb models.GigaBytes) models.GigaBytes {
+	if gb < 1 {
+		return models.GigaBytes(math.Ceil(float64(gb)*100) / 100)
+	}
+	return models.GigaBytes(math.Ceil(float64(gb)*10) / 10)
+}
+
+// FormatSize formats a budget as a size budget.yaml reads, in MB below a gigabyte and in GB above
+func FormatSize(gb models.GigaBytes) string {
+	if gb < 1 {
+		return strconv.FormatFloat(math.Round(float64(gb)*1000*100)/100, 'f', -1, 64) + "MB"
+	}
+	return strconv.FormatFloat(math.Round(float64(gb)*1000)/1000, 'f', -1, 64) + "GB"
+}
+
+// recommendationFragment renders the workloads of the recommendations as a budget.yaml fragment,
+// with their recommended budgets or, if current is set, only the listed workloads with their current budgets.
+// The statistics are comments above each workload so the diff only shows changed budgets. Namespaced
+// workload keys are listed with their namespace.
+func recommendationFragment(orgName, envName string, recommendations []Recommendation, current, namespaced bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "orgs:\n  - name: %s\n    envs:\n      - name: %s\n        workloads:\n", orgName, envName)
+	for _, r := range recommendations {
+		size := r.Recommended
+		if current {
+			if r.Current == nil {
+				continue
+			}
+			size = *r.Current
+		}
+		fmt.Fprintf(&sb, "          # p50 %s, p95 %s, max %s over %d days\n", FormatSize(r.P50), FormatSize(r.P95), FormatSize(r.Max), r.Days)
+		namespace, name := models.SplitWorkloadKey(r.Workload, namespaced)
+		fmt.Fprintf(&sb, "          - name: %s\n", name)
+		if namespace != "" {
+			fmt.Fprintf(&sb, "            namespace: %s\n", namespace)
+		}
+		fmt.Fprintf(&sb, "            daily_ingestion_budget: %s\n", FormatSize(size))
+	}
+	return sb.String()
+}
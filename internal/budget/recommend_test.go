@@ -0,0 +1,92 @@
+package budget
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"configurator/internal/models"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []models.GigaBytes{5, 1, 4, 2, 3}
+
+	tests := []struct {
+		p    float64
+		want models.GigaBytes
+	}{
+		{p: 0, want: 1},
+		{p: 50, want: 3},
+		{p: 95, want: 4.8},
+		{p: 100, want: 5},
+	}
+	for _, tt := range tests {
+		if got := Percentile(values, tt.p); math.Abs(float64(got-tt.want)) > 1e-9 {
+			t.Errorf("expected p%v to be %v, got %v", tt.p, tt.want, got)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("expected 0 without values, got %v", got)
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	checkout, search := Size(10), Size(0.3)
+	b := Budget{Organizations: []Organization{{
+		Name: "invest",
+		Environments: []Environment{{
+			Name: "prod",
+			Workloads: []Workload{
+				{Name: "checkout", DailyIngestionBudget: &checkout},
+				{Name: "search", DailyIngestionBudget: &search},
+			},
+		}},
+	}}}
+	daily := map[string][]models.GigaBytes{
+		"checkout": {8, 9, 10, 11, 12},
+		"search":   {0.2, 0.2, 0.2},
+		"payments": {0.5},
+	}
+
+	report, err := b.Recommend("invest", "prod", daily, RecommendOptions{Days: 7, Basis: BasisMax, Headroom: 0.25})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := map[string]models.GigaBytes{"checkout": 15, "payments": 0.63, "search": 0.25}
+	if len(report.Workloads) != len(want) {
+		t.Fatalf("expected %d recommendations, got %+v", len(want), report.Workloads)
+	}
+	for _, r := range report.Workloads {
+		if math.Abs(float64(r.Recommended-want[r.Workload])) > 1e-9 {
+			t.Errorf("expected %s to be recommended %v, got %v", r.Workload, want[r.Workload], r.Recommended)
+		}
+	}
+	if report.Workloads[1].Current != nil {
+		t.Errorf("expected payments to have no current budget, got %v", *report.Workloads[1].Current)
+	}
+
+	if !strings.Contains(report.Fragment, "          - name: checkout\n            daily_ingestion_budget: 15GB\n") {
+		t.Errorf("expected the fragment to set the budget of checkout, got\n%s", report.Fragment)
+	}
+	for _, line := range []string{"-            daily_ingestion_budget: 10GB", "+            daily_ingestion_budget: 15GB", "+          - name: payments"} {
+		if !strings.Contains(report.Diff, line+"\n") {
+			t.Errorf("expected the diff to contain %q, got\n%s", line, report.Diff)
+		}
+	}
+
+	// The fragment is a valid budget file
+	if problems := LintContent([]byte(report.Fragment), []OrgEnv{{Org: "invest", Env: "prod"}}); len(problems) != 0 {
+		t.Errorf("expected no lint problems, got %v", problems)
+	}
+
+	// Without namespaces a / is part of the workload name
+	fragment := recommendationFragment("invest", "prod", []Recommendation{{Workload: "team/search", Recommended: 1}}, false, false)
+	if !strings.Contains(fragment, "          - name: team/search\n            daily_ingestion_budget: 1GB\n") {
+		t.Errorf("expected the fragment to list team/search by name, got\n%s", fragment)
+	}
+
+	if _, err := b.Recommend("invest", "prod", daily, RecommendOptions{Days: 7, Basis: "p99"}); err == nil {
+		t.Errorf("expected an error for an unknown basis")
+	}
+}
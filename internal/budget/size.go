@@ -0,0 +1,77 @@
+package budget
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"configurator/internal/models"
+)
+
+// Size is a data size in gigabytes (10^9 bytes).
+// It is read from a plain number of gigabytes or a number with a unit like 300MB or 1.5GiB.
+type Size float64
+
+// sizeUnits maps the lower case units to their size in bytes.
+// SI units (kB, MB, ...) are powers of 1000, IEC units (KiB, MiB, ...) are powers of 1024.
+var sizeUnits = map[string]float64{
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// ParseSize parses a size like 300MB, 1.5GiB or 2TB. A plain number is read as gigabytes.
+func ParseSize(s string) (Size, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	// Split the number from the unit
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r)
+	})
+	number, unit := s, ""
+	if i >= 0 {
+		number, unit = strings.TrimSpace(s[:i]), s[i:]
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if unit == "" {
+		return Size(value), nil
+	}
+
+	bytes, ok := sizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q, use one of B, kB, MB, GB, TB, PB (powers of 1000) or KiB, MiB, GiB, TiB, PiB (powers of 1024)", s, unit)
+	}
+	return Size(value * bytes / 1e9), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler so sizes can be written as strings in budget.yaml
+func (s *Size) UnmarshalText(text []byte) error {
+	size, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
+
+// GigaBytes returns the size in gigabytes
+func (s Size) GigaBytes() models.GigaBytes {
+	return models.GigaBytes(s)
+}
@@ -0,0 +1,95 @@
+package budget
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"configurator/internal/models"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Size
+		wantErr bool
+	}{
+		{input: "30", want: 30},
+		{input: "0.5", want: 0.5},
+		{input: "300MB", want: 0.3},
+		{input: "300 mb", want: 0.3},
+		{input: "1.5GB", want: 1.5},
+		{input: "1GiB", want: 1.073741824},
+		{input: "512MiB", want: 0.536870912},
+		{input: "2TB", want: 2000},
+		{input: "1TiB", want: 1099.511627776},
+		{input: "500kB", want: 0.0005},
+		{input: "1G", wantErr: true},
+		{input: "1Gb/s", wantErr: true},
+		{input: "GB", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if diff := float64(got - tt.want); diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("ParseSize(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWithSizes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.yaml")
+	content := `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: plain
+            daily_ingestion_budget: 30
+          - name: fractional
+            daily_ingestion_budget: 0.25
+          - name: sidecar
+            daily_ingestion_budget: 300MB
+          - name: iec
+            daily_ingestion_budget: 1.5GiB
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write budget: %v", err)
+	}
+
+	b, err := New(path, true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	budgets, err := b.ExtractBudget("invest", "prod", time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := map[string]models.GigaBytes{
+		"plain":      30,
+		"fractional": 0.25,
+		"sidecar":    0.3,
+		"iec":        1.610612736,
+	}
+	for workload, w := range want {
+		if diff := float64(budgets[workload] - w); diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("expected %s budget %v GB, got %v", workload, w, budgets[workload])
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("orgs:\n  - name: invest\n    envs:\n      - name: prod\n        workloads:\n          - name: a\n            daily_ingestion_budget: 3 parsecs\n"), 0o600); err != nil {
+		t.Fatalf("failed to write budget: %v", err)
+	}
+	if _, err := New(path, true); err == nil {
+		t.Fatalf("expected error for unknown unit")
+	}
+}
@@ -0,0 +1,54 @@
+package budget
+
+import (
+	"configurator/internal/models"
+)
+
+// Strategy derives the dynamic daily budget of a workload from its resources
+type Strategy interface {
+	Budget(w models.WorkloadResourceRequest) models.GigaBytes
+}
+
+// CPUStrategy grants Multiplier GB for every StandardCores requested CPU cores
+type CPUStrategy struct {
+	StandardCores models.Cores
+	Multiplier    float64
+}
+
+func (s CPUStrategy) Budget(w models.WorkloadResourceRequest) models.GigaBytes {
+	return models.GigaBytes(float64(w.CPU/s.StandardCores) * s.Multiplier)
+}
+
+// CPUMemoryStrategy grants Multiplier GB for a workload requesting StandardCores CPU cores and StandardMemory memory,
+// weighting the CPU and memory share, so memory-heavy workloads are not limited to the minimum budget.
+type CPUMemoryStrategy struct {
+	StandardCores  models.Cores
+	StandardMemory models.Bytes
+	CPUWeight      float64
+	MemoryWeight   float64
+	Multiplier     float64
+}
+
+func (s CPUMemoryStrategy) Budget(w models.WorkloadResourceRequest) models.GigaBytes {
+	cpuShare := float64(w.CPU / s.StandardCores)
+	memoryShare := float64(w.Memory / s.StandardMemory)
+	return models.GigaBytes((s.CPUWeight*cpuShare + s.MemoryWeight*memoryShare) * s.Multiplier)
+}
+
+// PerReplicaStrategy grants BudgetPerReplica for every replica of a workload
+type PerReplicaStrategy struct {
+	BudgetPerReplica models.GigaBytes
+}
+
+func (s PerReplicaStrategy) Budget(w models.WorkloadResourceRequest) models.GigaBytes {
+	return models.GigaBytes(w.Replicas) * s.BudgetPerReplica
+}
+
+// FlatStrategy grants every workload the same budget
+type FlatStrategy struct {
+	DailyIngestionBudget models.GigaBytes
+}
+
+func (s FlatStrategy) Budget(models.WorkloadResourceRequest) models.GigaBytes {
+	return s.DailyIngestionBudget
+}
@@ -0,0 +1,58 @@
+package budget
+
+import (
+	"testing"
+
+	"configurator/internal/models"
+)
+
+func TestStrategies(t *testing.T) {
+	// A JVM service requesting little CPU but a lot of memory
+	w := models.WorkloadResourceRequest{Workload: "jvm", CPU: 4, Memory: 96e9, Replicas: 6}
+
+	tests := []struct {
+		name     string
+		strategy Strategy
+		want     models.GigaBytes
+	}{
+		{name: "cpu", strategy: CPUStrategy{StandardCores: 16, Multiplier: 10}, want: 2.5},
+		{
+			name: "cpu_memory",
+			strategy: CPUMemoryStrategy{
+				StandardCores:  16,
+				StandardMemory: 64e9,
+				CPUWeight:      0.5,
+				MemoryWeight:   0.5,
+				Multiplier:     10,
+			},
+			want: 8.75,
+		},
+		{name: "per_replica", strategy: PerReplicaStrategy{BudgetPerReplica: 1.5}, want: 9},
+		{name: "flat", strategy: FlatStrategy{DailyIngestionBudget: 5}, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.strategy.Budget(w); got != tt.want {
+				t.Errorf("expected budget %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCalculateDynamicBudget(t *testing.T) {
+	resources := []models.WorkloadResourceRequest{
+		{Workload: "small", CPU: 0.1},
+		{Workload: "large", CPU: 32},
+		{Workload: "override", CPU: 32},
+	}
+	overrides := map[string]models.GigaBytes{"override": 30}
+
+	got, err := CalculateDynamicBudget(resources, overrides, CPUStrategy{StandardCores: 16, Multiplier: 1}, 0.5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got["small"] != 0.5 || got["large"] != 2 || got["override"] != 30 {
+		t.Fatalf("expected minimum, calculated and overridden budgets, got %v", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 	"k8s.io/client-go/rest"
@@ -14,6 +15,8 @@ import (
 // K8sClient manages Kubernetes secrets
 type K8sClient struct {
 	clientset kubernetes.Interface
+	// dynamic reads custom resources such as LogBudgets
+	dynamic dynamic.Interface
 }
 
 // NewK8sClient creates a new Kubernetes client using provided kubeconfig path.
@@ -42,11 +45,50 @@ func New(kubeconfig string) (*K8sClient, error) {
 		}
 	}
 
+	return newForConfig(config)
+}
+
+// NewForContext creates a Kubernetes client for a context of the given kubeconfig.
+// An empty kubeconfig path uses the default loading rules ($KUBECONFIG or ~/.kube/config).
+func NewForContext(kubeconfig, context string) (*K8sClient, error) {
+	log.Debug().
+		Str("kubeconfig", kubeconfig).
+		Str("context", context).
+		Msg("creating new K8sClient for context")
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		rules,
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig context %s: %w", context, err)
+	}
+
+	return newForConfig(config)
+}
+
+// newForConfig creates the clientsets of a K8sClient
+func newForConfig(config *rest.Config) (*K8sClient, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kubernetes clientset: %w", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes dynamic client: %w", err)
+	}
+
 	log.Debug().Msg("successfully created kubernetes clientset")
-	return &K8sClient{clientset: clientset}, nil
+	return &K8sClient{clientset: clientset, dynamic: dynamicClient}, nil
+}
+
+// NewForClientsets creates a client using the given clientsets, e.g. fake clientsets in tests
+func NewForClientsets(clientset kubernetes.Interface, dynamic dynamic.Interface) *K8sClient {
+	return &K8sClient{clientset: clientset, dynamic: dynamic}
 }
@@ -0,0 +1,113 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FetchConfigMapValue fetches a ConfigMap and returns the value of the given key.
+// A missing ConfigMap or key is not an error and returns an empty string.
+// It includes retry logic with exponential backoff for transient errors
+func (k *K8sClient) FetchConfigMapValue(namespace, name, key string) (string, error) {
+
+	var lastErr error
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := baseDelay * time.Duration(1<<(attempt-1)) // Exponential backoff: 1s, 2s, 4s
+			log.Debug().
+				Str("namespace", namespace).
+				Str("configmap", name).
+				Int("attempt", attempt).
+				Dur("delay", delay).
+				Msg("retrying configmap fetch after delay")
+			time.Sleep(delay)
+		}
+
+		configMap, err := k.clientset.CoreV1().
+			ConfigMaps(namespace).
+			Get(context.TODO(), name, metav1.GetOptions{})
+
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("failed to get configmap: %v", err)
+			log.Debug().
+				Str("namespace", namespace).
+				Str("configmap", name).
+				Err(err).
+				Msg("error fetching configmap, will retry")
+			continue
+		}
+
+		return configMap.Data[key], nil
+	}
+
+	return "", fmt.Errorf("failed to get configmap after %d attempts: %v", maxRetries+1, lastErr)
+}
+
+// UpdateConfigMapValue sets the value of the given key in the ConfigMap, creating the ConfigMap if it does not exist.
+// It includes retry logic with exponential backoff for transient errors
+func (k *K8sClient) UpdateConfigMapValue(namespace, name, key, value string) error {
+
+	var lastErr error
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := baseDelay * time.Duration(1<<(attempt-1)) // Exponential backoff: 1s, 2s, 4s
+			log.Debug().
+				Int("attempt", attempt).
+				Dur("delay", delay).
+				Msg("retrying configmap update after delay")
+			time.Sleep(delay)
+		}
+
+		configMaps := k.clientset.CoreV1().ConfigMaps(namespace)
+
+		configMap, err := configMaps.Get(context.TODO(), name, metav1.GetOptions{})
+
+		if apierrors.IsNotFound(err) {
+			_, err = configMaps.Create(context.TODO(), &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Data: map[string]string{key: value},
+			}, metav1.CreateOptions{})
+		} else if err == nil {
+			if configMap.Data == nil {
+				configMap.Data = make(map[string]string)
+			}
+			configMap.Data[key] = value
+			_, err = configMaps.Update(context.TODO(), configMap, metav1.UpdateOptions{})
+		}
+
+		if err != nil {
+			lastErr = fmt.Errorf("failed to update configmap: %v", err)
+			log.Warn().
+				Str("namespace", namespace).
+				Str("configmap", name).
+				Err(err).
+				Msg("error updating configmap, will retry")
+			continue
+		}
+
+		log.Trace().
+			Str("namespace", namespace).
+			Str("configmap", name).
+			Str("key", key).
+			Msg("ConfigMap updated successfully")
+
+		return nil
+	}
+
+	return fmt.Errorf("failed to update configmap after %d attempts: %v", maxRetries+1, lastErr)
+}
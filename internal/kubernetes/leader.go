@@ -0,0 +1,86 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElectionConfig configures Lease based leader election
+type LeaderElectionConfig struct {
+	LeaseName      string
+	LeaseNamespace string
+	Identity       string
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
+}
+
+// RunLeaderElection campaigns for the configured Lease until ctx is cancelled or leadership is lost,
+// it does not campaign again. onStartedLeading is called once leadership is acquired and its context
+// is cancelled once leadership is lost. onStoppedLeading is called when the election ends, callers exit
+// there on lost leadership so no run in flight keeps writing, and Kubernetes restarts them as a standby.
+// The Lease is released when ctx is cancelled so a standby can take over immediately.
+func (k *K8sClient) RunLeaderElection(
+	ctx context.Context,
+	cfg LeaderElectionConfig,
+	onStartedLeading func(context.Context),
+	onStoppedLeading func(),
+) error {
+	if cfg.LeaseName == "" || cfg.LeaseNamespace == "" {
+		return errors.New("lease name and namespace can not be empty")
+	}
+	if cfg.Identity == "" {
+		return errors.New("leader election identity can not be empty")
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      cfg.LeaseName,
+			Namespace: cfg.LeaseNamespace,
+		},
+		Client: k.clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: cfg.Identity,
+		},
+	}
+
+	electionConfig := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            cfg.LeaseName,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: onStartedLeading,
+			OnStoppedLeading: onStoppedLeading,
+			OnNewLeader: func(identity string) {
+				log.Info().
+					Str("leader", identity).
+					Str("identity", cfg.Identity).
+					Msg("observed new leader")
+			},
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(electionConfig)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().
+		Str("lease", cfg.LeaseNamespace+"/"+cfg.LeaseName).
+		Str("identity", cfg.Identity).
+		Msg("campaigning for leadership")
+	// Run returns once leadership is lost or ctx is cancelled
+	elector.Run(ctx)
+
+	return nil
+}
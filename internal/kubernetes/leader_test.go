@@ -0,0 +1,64 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunLeaderElection(t *testing.T) {
+	// Create a K8sClient with the fake client
+	clientset := fake.NewSimpleClientset()
+	sm := &K8sClient{clientset: clientset}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- sm.RunLeaderElection(
+			ctx,
+			LeaderElectionConfig{
+				LeaseName:      "configurator",
+				LeaseNamespace: "default",
+				Identity:       "replica-1",
+				LeaseDuration:  2 * time.Second,
+				RenewDeadline:  1 * time.Second,
+				RetryPeriod:    100 * time.Millisecond,
+			},
+			func(context.Context) { close(started) },
+			func() {},
+		)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected leadership to be acquired")
+	}
+
+	lease, err := clientset.CoordinationV1().Leases("default").Get(context.TODO(), "configurator", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != "replica-1" {
+		t.Fatalf("expected lease to be held by replica-1, got %v", lease.Spec.HolderIdentity)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestRunLeaderElectionInvalidConfig(t *testing.T) {
+	sm := &K8sClient{clientset: fake.NewSimpleClientset()}
+
+	err := sm.RunLeaderElection(context.TODO(), LeaderElectionConfig{}, func(context.Context) {}, func() {})
+	if err == nil {
+		t.Fatalf("expected error for empty lease config")
+	}
+}
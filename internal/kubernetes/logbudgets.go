@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"configurator/internal/budget"
+)
+
+// LogBudgetResource is the LogBudget custom resource
+var LogBudgetResource = schema.GroupVersionResource{
+	Group:    "tco.groww.in",
+	Version:  "v1alpha1",
+	Resource: "logbudgets",
+}
+
+// ListLogBudgets returns the LogBudgets of all namespaces.
+// If the LogBudget CRD is not installed, no LogBudgets are returned.
+func (k *K8sClient) ListLogBudgets() ([]budget.LogBudget, error) {
+	list, err := k.dynamic.Resource(LogBudgetResource).
+		Namespace(metav1.NamespaceAll).
+		List(context.TODO(), metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		log.Debug().Msg("LogBudget CRD is not installed")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list logbudgets: %w", err)
+	}
+
+	logBudgets := make([]budget.LogBudget, 0, len(list.Items))
+	for _, item := range list.Items {
+		lb := budget.LogBudget{
+			Namespace:         item.GetNamespace(),
+			Name:              item.GetName(),
+			Generation:        item.GetGeneration(),
+			CreationTimestamp: item.GetCreationTimestamp().Time,
+		}
+
+		// Round trip the spec through JSON so budgets can be written as numbers or sizes like 50GB
+		spec, err := json.Marshal(item.Object["spec"])
+		if err == nil {
+			err = json.Unmarshal(spec, &lb.Spec)
+		}
+		if err != nil {
+			// Keep the LogBudget so the error is reported in its status
+			log.Warn().Err(err).
+				Str("logbudget", lb.Ref()).
+				Msg("Invalid LogBudget spec")
+			lb.Spec, lb.SpecError = budget.LogBudgetSpec{}, err
+		}
+		logBudgets = append(logBudgets, lb)
+	}
+	return logBudgets, nil
+}
+
+// UpdateLogBudgetStatus replaces the status of a LogBudget
+func (k *K8sClient) UpdateLogBudgetStatus(namespace, name string, status budget.LogBudgetStatus) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return fmt.Errorf("failed to encode logbudget status: %w", err)
+	}
+
+	_, err = k.dynamic.Resource(LogBudgetResource).
+		Namespace(namespace).
+		Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return fmt.Errorf("failed to update status of logbudget %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"configurator/internal/budget"
+)
+
+func TestLogBudgets(t *testing.T) {
+	logBudget := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "tco.groww.in/v1alpha1",
+		"kind":       "LogBudget",
+		"metadata": map[string]interface{}{
+			"name":       "payments",
+			"namespace":  "payments",
+			"generation": int64(2),
+		},
+		"spec": map[string]interface{}{
+			"selector":             map[string]interface{}{"workloads": []interface{}{"payments-api"}},
+			"dailyIngestionBudget": "50GB",
+			"enforcementMode":      "monitor",
+		},
+	}}
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{LogBudgetResource: "LogBudgetList"},
+		logBudget,
+	)
+	sm := &K8sClient{dynamic: client}
+
+	logBudgets, err := sm.ListLogBudgets()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(logBudgets) != 1 {
+		t.Fatalf("expected 1 LogBudget, got %+v", logBudgets)
+	}
+	lb := logBudgets[0]
+	if lb.Ref() != "payments/payments" || lb.Generation != 2 || lb.Spec.DailyIngestionBudget != 50 || lb.Mode() != budget.EnforcementModeMonitor {
+		t.Fatalf("unexpected LogBudget %+v", lb)
+	}
+
+	status := budget.LogBudgetStatus{
+		ObservedGeneration: 2,
+		LastUpdated:        time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		Workloads: []budget.LogBudgetWorkloadStatus{
+			{Workload: "payments-api", CurrentIngestionGB: 60, BudgetGB: 50, SamplingPercentage: 100},
+		},
+	}
+	if err := sm.UpdateLogBudgetStatus("payments", "payments", status); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	updated, err := client.Resource(LogBudgetResource).Namespace("payments").Get(context.TODO(), "payments", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	workloads, _, _ := unstructured.NestedSlice(updated.Object, "status", "workloads")
+	if len(workloads) != 1 {
+		t.Fatalf("expected the status to be updated, got %v", updated.Object["status"])
+	}
+}
@@ -0,0 +1,109 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"configurator/internal/models"
+)
+
+const (
+	configMapKey = "history.jsonl"
+	// ConfigMaps are limited to 1MiB, keep some room for metadata
+	maxConfigMapBytes = 900 * 1024
+)
+
+// ConfigMapStore reads and writes a single key of a ConfigMap
+type ConfigMapStore interface {
+	FetchConfigMapValue(namespace, name, key string) (string, error)
+	UpdateConfigMapValue(namespace, name, key, value string) error
+}
+
+// ConfigMapLedger stores runs as JSON lines in a Kubernetes ConfigMap.
+// Records older than the retention, and the oldest records once the ConfigMap
+// size limit is reached, are pruned on every append.
+type ConfigMapLedger struct {
+	store     ConfigMapStore
+	namespace string
+	name      string
+	retention time.Duration
+	mu        sync.Mutex
+}
+
+// NewConfigMapLedger creates a ledger backed by the given ConfigMap
+func NewConfigMapLedger(store ConfigMapStore, namespace, name string, retention time.Duration) (*ConfigMapLedger, error) {
+	if namespace == "" || name == "" {
+		return nil, errors.New("ledger configmap name and namespace cannot be empty")
+	}
+	return &ConfigMapLedger{
+		store:     store,
+		namespace: namespace,
+		name:      name,
+		retention: retention,
+	}, nil
+}
+
+// Append implements the Ledger interface
+func (c *ConfigMapLedger) Append(run models.EnforcementRun) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := c.store.FetchConfigMapValue(c.namespace, c.name, configMapKey)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ledger: %w", err)
+	}
+
+	var since time.Time
+	if c.retention > 0 {
+		since = time.Now().Add(-c.retention)
+	}
+	runs, err := decode(data, since)
+	if err != nil {
+		return fmt.Errorf("failed to decode ledger: %w", err)
+	}
+	runs = append(runs, compact(run))
+
+	lines := make([]string, 0, len(runs))
+	size := 0
+	for _, r := range runs {
+		line, err := encode(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode run: %w", err)
+		}
+		lines = append(lines, string(line))
+		size += len(line)
+	}
+
+	// Drop the oldest records until the ledger fits into the ConfigMap
+	pruned := 0
+	for size > maxConfigMapBytes && len(lines) > 1 {
+		size -= len(lines[0])
+		lines = lines[1:]
+		pruned++
+	}
+	if pruned > 0 {
+		log.Warn().
+			Int("pruned", pruned).
+			Str("configmap", c.namespace+"/"+c.name).
+			Msg("ledger configmap is full, pruned oldest records")
+	}
+
+	if err := c.store.UpdateConfigMapValue(c.namespace, c.name, configMapKey, strings.Join(lines, "")); err != nil {
+		return fmt.Errorf("failed to update ledger: %w", err)
+	}
+	return nil
+}
+
+// Query implements the Ledger interface
+func (c *ConfigMapLedger) Query(since time.Time) ([]models.EnforcementRun, error) {
+	data, err := c.store.FetchConfigMapValue(c.namespace, c.name, configMapKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ledger: %w", err)
+	}
+	return decode(data, since)
+}
@@ -0,0 +1,67 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"configurator/internal/models"
+)
+
+// FileLedger stores runs as JSON lines in a local append-only file
+type FileLedger struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileLedger creates a ledger backed by the file at path, creating its directory if needed
+func NewFileLedger(path string) (*FileLedger, error) {
+	if path == "" {
+		return nil, errors.New("ledger file path cannot be empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	return &FileLedger{path: path}, nil
+}
+
+// Append implements the Ledger interface
+func (f *FileLedger) Append(run models.EnforcementRun) error {
+	line, err := encode(compact(run))
+	if err != nil {
+		return fmt.Errorf("failed to encode run: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("failed to write ledger record: %w", err)
+	}
+	return file.Sync()
+}
+
+// Query implements the Ledger interface
+func (f *FileLedger) Query(since time.Time) ([]models.EnforcementRun, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger file: %w", err)
+	}
+
+	return decode(string(data), since)
+}
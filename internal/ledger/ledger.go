@@ -0,0 +1,96 @@
+// Package ledger records every enforcement run in durable storage,
+// so past throttling decisions can be queried after the run has finished.
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"configurator/internal/models"
+)
+
+// Ledger stores enforcement runs and queries them by time
+type Ledger interface {
+	// Append records a finished run
+	Append(run models.EnforcementRun) error
+	// Query returns all runs started at or after since, oldest first
+	Query(since time.Time) ([]models.EnforcementRun, error)
+}
+
+// compact keeps only the workloads that were over budget or sampled by the run.
+// Workloads within budget and not sampled are not interesting for history and
+// would make the ledger grow with the number of workloads in the cluster.
+func compact(run models.EnforcementRun) models.EnforcementRun {
+	workloads := make([]models.WorkloadStatus, 0, len(run.Workloads))
+	for _, w := range run.Workloads {
+		if w.OverBudget || w.Sampled {
+			workloads = append(workloads, w)
+		}
+	}
+	run.Workloads = workloads
+	return run
+}
+
+// encode serialises a run as a single JSON line
+func encode(run models.EnforcementRun) ([]byte, error) {
+	line, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// decode parses JSON lines into runs started at or after since.
+// Malformed lines are skipped so a single corrupt record does not hide the history.
+func decode(data string, since time.Time) ([]models.EnforcementRun, error) {
+	var runs []models.EnforcementRun
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var run models.EnforcementRun
+		if err := json.Unmarshal([]byte(line), &run); err != nil {
+			log.Warn().Err(err).Msg("skipping malformed ledger record")
+			continue
+		}
+
+		if !run.StartedAt.Before(since) {
+			runs = append(runs, run)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt)
+	})
+	return runs, nil
+}
+
+// FilterWorkload returns the runs in which the given workload was over budget or sampled,
+// each holding only that workload
+func FilterWorkload(runs []models.EnforcementRun, workload string) []models.EnforcementRun {
+	var filtered []models.EnforcementRun
+	for _, run := range runs {
+		for _, w := range run.Workloads {
+			if w.Workload == workload {
+				run.Workloads = []models.WorkloadStatus{w}
+				filtered = append(filtered, run)
+				break
+			}
+		}
+	}
+	return filtered
+}
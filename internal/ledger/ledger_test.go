@@ -0,0 +1,124 @@
+package ledger
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"configurator/internal/models"
+)
+
+// fakeConfigMapStore keeps ConfigMap values in memory
+type fakeConfigMapStore struct {
+	data map[string]string
+}
+
+func (f *fakeConfigMapStore) FetchConfigMapValue(namespace, name, key string) (string, error) {
+	return f.data[namespace+"/"+name+"/"+key], nil
+}
+
+func (f *fakeConfigMapStore) UpdateConfigMapValue(namespace, name, key, value string) error {
+	f.data[namespace+"/"+name+"/"+key] = value
+	return nil
+}
+
+func testRun(id string, startedAt time.Time) models.EnforcementRun {
+	return models.EnforcementRun{
+		ID:        id,
+		Task:      "ingestion_check",
+		StartedAt: startedAt,
+		Success:   true,
+		Workloads: []models.WorkloadStatus{
+			{Workload: "otel-collector", OverBudget: true, Sampled: true, SamplingPercentage: 50},
+			{Workload: "api", SamplingPercentage: 100},
+		},
+	}
+}
+
+func TestLedgers(t *testing.T) {
+	fileLedger, err := NewFileLedger(filepath.Join(t.TempDir(), "history", "history.jsonl"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	configMapLedger, err := NewConfigMapLedger(&fakeConfigMapStore{data: map[string]string{}}, "default", "history", 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	ledgers := map[string]Ledger{
+		"file":      fileLedger,
+		"configmap": configMapLedger,
+	}
+
+	now := time.Now()
+
+	for name, l := range ledgers {
+		t.Run(name, func(t *testing.T) {
+			runs, err := l.Query(now.AddDate(0, 0, -7))
+			if err != nil {
+				t.Fatalf("expected no error on empty ledger, got %v", err)
+			}
+			if len(runs) != 0 {
+				t.Fatalf("expected empty ledger, got %d runs", len(runs))
+			}
+
+			for _, run := range []models.EnforcementRun{
+				testRun("old", now.AddDate(0, 0, -10)),
+				testRun("recent", now.AddDate(0, 0, -2)),
+				testRun("latest", now),
+			} {
+				if err := l.Append(run); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+
+			runs, err = l.Query(now.AddDate(0, 0, -7))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(runs) != 2 || runs[0].ID != "recent" || runs[1].ID != "latest" {
+				t.Fatalf("expected runs [recent latest], got %+v", runs)
+			}
+
+			// Workloads within budget and not sampled are not recorded
+			if len(runs[0].Workloads) != 1 || runs[0].Workloads[0].Workload != "otel-collector" {
+				t.Fatalf("expected only otel-collector to be recorded, got %+v", runs[0].Workloads)
+			}
+		})
+	}
+}
+
+func TestConfigMapLedgerRetention(t *testing.T) {
+	store := &fakeConfigMapStore{data: map[string]string{}}
+	l, _ := NewConfigMapLedger(store, "default", "history", 24*time.Hour)
+
+	now := time.Now()
+	_ = l.Append(testRun("expired", now.Add(-48*time.Hour)))
+	_ = l.Append(testRun("current", now))
+
+	stored := store.data["default/history/"+configMapKey]
+	if strings.Contains(stored, "expired") {
+		t.Fatalf("expected expired run to be pruned, got %s", stored)
+	}
+	if !strings.Contains(stored, "current") {
+		t.Fatalf("expected current run to be stored, got %s", stored)
+	}
+}
+
+func TestFilterWorkload(t *testing.T) {
+	now := time.Now()
+	runs := []models.EnforcementRun{
+		compact(testRun("first", now)),
+		{ID: "reset", Task: "budget_reset", StartedAt: now},
+	}
+
+	filtered := FilterWorkload(runs, "otel-collector")
+	if len(filtered) != 1 || filtered[0].ID != "first" {
+		t.Fatalf("expected only run first, got %+v", filtered)
+	}
+
+	if filtered := FilterWorkload(runs, "api"); len(filtered) != 0 {
+		t.Fatalf("expected no runs for api, got %+v", filtered)
+	}
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -10,12 +11,18 @@ import (
 )
 
 func InitLogger(logLevel, logFormat string) {
+	InitLoggerWithOutput(os.Stdout, logLevel, logFormat)
+}
+
+// InitLoggerWithOutput initializes the global logger writing to out,
+// used by CLI commands to keep their stdout free for command output
+func InitLoggerWithOutput(out io.Writer, logLevel, logFormat string) {
 	// Check if JSON logging is required
 	if logFormat == "json" {
-		log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
+		log.Logger = zerolog.New(out).With().Timestamp().Logger()
 	} else {
 		// Set up colored console writer
-		output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+		output := zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}
 
 		output.FormatLevel = func(i interface{}) string {
 			if ll, ok := i.(string); ok {
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Auth configures how a client authenticates against the query API. Secrets are read from files,
+// on every request so rotated files are picked up, or from env vars.
+type Auth struct {
+	// Username and a password from PasswordFile or PasswordEnv enable basic auth
+	Username     string
+	PasswordFile string
+	PasswordEnv  string
+	// BearerTokenFile or BearerTokenEnv enable bearer token auth
+	BearerTokenFile string
+	BearerTokenEnv  string
+	// CAFile verifies the server, CertFile and KeyFile are the client certificate for mTLS
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// Validate checks that at most one of basic and bearer auth is set, each with one source of its secret,
+// and that a client certificate comes with its key
+func (a Auth) Validate() error {
+	var errs []error
+	basic := a.Username != "" || a.PasswordFile != "" || a.PasswordEnv != ""
+	bearer := a.BearerTokenFile != "" || a.BearerTokenEnv != ""
+	if basic && bearer {
+		errs = append(errs, errors.New("basic and bearer auth are mutually exclusive"))
+	}
+	if basic && a.Username == "" {
+		errs = append(errs, errors.New("basic auth needs a username"))
+	}
+	if basic && (a.PasswordFile == "") == (a.PasswordEnv == "") {
+		errs = append(errs, errors.New("basic auth needs either password_file or password_env"))
+	}
+	if a.BearerTokenFile != "" && a.BearerTokenEnv != "" {
+		errs = append(errs, errors.New("bearer auth needs token_file or token_env, not both"))
+	}
+	if (a.CertFile == "") != (a.KeyFile == "") {
+		errs = append(errs, errors.New("a client certificate needs cert_file and key_file"))
+	}
+	return errors.Join(errs...)
+}
+
+// transport returns the round tripper authenticating requests as configured
+func (a Auth) transport() (http.RoundTripper, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+
+	var base http.RoundTripper = http.DefaultTransport
+	if a.CAFile != "" || a.CertFile != "" {
+		tlsConfig, err := a.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		base = transport
+	}
+
+	switch {
+	case a.Username != "":
+		password, err := a.secret(a.PasswordEnv)
+		if err != nil {
+			return nil, err
+		}
+		return &authRoundTripper{RoundTripper: base, set: func(req *http.Request) error {
+			password := password
+			if a.PasswordFile != "" {
+				var err error
+				if password, err = readSecret(a.PasswordFile); err != nil {
+					return err
+				}
+			}
+			req.SetBasicAuth(a.Username, password)
+			return nil
+		}}, nil
+	case a.BearerTokenFile != "" || a.BearerTokenEnv != "":
+		token, err := a.secret(a.BearerTokenEnv)
+		if err != nil {
+			return nil, err
+		}
+		return &authRoundTripper{RoundTripper: base, set: func(req *http.Request) error {
+			token := token
+			if a.BearerTokenFile != "" {
+				var err error
+				if token, err = readSecret(a.BearerTokenFile); err != nil {
+					return err
+				}
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}}, nil
+	}
+	return base, nil
+}
+
+// secret returns the value of the env var, empty if no env var is set
+func (a Auth) secret(env string) (string, error) {
+	if env == "" {
+		return "", nil
+	}
+	value, ok := os.LookupEnv(env)
+	if !ok || value == "" {
+		return "", fmt.Errorf("env var %s is not set", env)
+	}
+	return value, nil
+}
+
+// tlsConfig returns the TLS config trusting the CA and presenting the client certificate,
+// the certificate is loaded on every handshake so rotated files are picked up
+func (a Auth) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if a.CAFile != "" {
+		ca, err := os.ReadFile(a.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", a.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if a.CertFile != "" {
+		// Fail early on an invalid certificate
+		if _, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile); err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			return &cert, nil
+		}
+	}
+	return tlsConfig, nil
+}
+
+// readSecret reads a secret from a file, without surrounding whitespace
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// authRoundTripper sets the credentials of each request
+type authRoundTripper struct {
+	RoundTripper http.RoundTripper
+	set          func(req *http.Request) error
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	if err := rt.set(req); err != nil {
+		return nil, err
+	}
+	return rt.RoundTripper.RoundTrip(req)
+}
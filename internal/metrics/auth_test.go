@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile writes the content to a file in a temporary directory and returns its path
+func writeFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestAuthValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    Auth
+		wantErr bool
+	}{
+		{name: "No auth", auth: Auth{}},
+		{name: "Basic auth", auth: Auth{Username: "configurator", PasswordEnv: "MIMIR_PASSWORD"}},
+		{name: "Bearer token and mTLS", auth: Auth{BearerTokenFile: "token", CAFile: "ca.crt", CertFile: "tls.crt", KeyFile: "tls.key"}},
+		{name: "Basic and bearer auth", auth: Auth{Username: "configurator", PasswordFile: "password", BearerTokenEnv: "TOKEN"}, wantErr: true},
+		{name: "Password without username", auth: Auth{PasswordFile: "password"}, wantErr: true},
+		{name: "Password file and env", auth: Auth{Username: "configurator", PasswordFile: "password", PasswordEnv: "MIMIR_PASSWORD"}, wantErr: true},
+		{name: "Token file and env", auth: Auth{BearerTokenFile: "token", BearerTokenEnv: "TOKEN"}, wantErr: true},
+		{name: "Certificate without key", auth: Auth{CertFile: "tls.crt"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.auth.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAuthBasic(t *testing.T) {
+	t.Setenv("MIMIR_PASSWORD", "secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "configurator" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	transport, err := Auth{Username: "configurator", PasswordEnv: "MIMIR_PASSWORD"}.transport()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected basic auth to be accepted, got %s", resp.Status)
+	}
+
+	if _, err := (Auth{Username: "configurator", PasswordEnv: "MISSING_PASSWORD"}).transport(); err == nil {
+		t.Error("expected an error for an unset env var")
+	}
+}
+
+func TestAuthBearerTokenFile(t *testing.T) {
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenant := r.Header.Get("X-Scope-OrgID"); tenant != "tenant" {
+			t.Errorf("expected the tenant header, got %q", tenant)
+		}
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+	}))
+	defer server.Close()
+
+	tokenFile := writeFile(t, "token", []byte("first\n"))
+	m, err := New(server.URL, "tenant", time.Second, false, testNames, Auth{BearerTokenFile: tokenFile})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := m.GetIngestedGB("cluster-001", "1h"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// A rotated token is sent with the next query
+	if err := os.WriteFile(tokenFile, []byte("second"), 0o600); err != nil {
+		t.Fatalf("failed to rotate token: %v", err)
+	}
+	if _, err := m.GetIngestedGB("cluster-001", "1h"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(tokens) != 2 || tokens[0] != "Bearer first" || tokens[1] != "Bearer second" {
+		t.Errorf("expected the first and the rotated token, got %v", tokens)
+	}
+}
+
+func TestAuthLoki(t *testing.T) {
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+	}))
+	defer server.Close()
+
+	l, err := NewLoki(server.URL, "tenant", time.Second, false, testNames, Auth{BearerTokenFile: writeFile(t, "token", []byte("loki"))}, resourceStub{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := l.GetIngestedGB("cluster-001", "1h"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(tokens) != 1 || tokens[0] != "Bearer loki" {
+		t.Errorf("expected the token to be sent to Loki, got %v", tokens)
+	}
+
+	if _, err := NewLoki(server.URL, "tenant", time.Second, false, testNames, Auth{Username: "configurator"}, resourceStub{}); err == nil {
+		t.Error("expected an error for basic auth without a password")
+	}
+}
+
+func TestAuthMutualTLS(t *testing.T) {
+	// A CA issuing the client certificate
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "configurator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create client certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	// The server certificate of httptest is self-signed, it is its own CA
+	auth := Auth{
+		CAFile:   writeFile(t, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+		CertFile: writeFile(t, "tls.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER})),
+		KeyFile:  writeFile(t, "tls.key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+	transport, err := auth.transport()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the client certificate to be accepted, got %v", err)
+	}
+	resp.Body.Close()
+
+	// Without a client certificate the handshake fails
+	transport, err = Auth{CAFile: auth.CAFile}.transport()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resp, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected the request without a client certificate to fail")
+	}
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/rs/zerolog/log"
+
+	"configurator/internal/models"
+)
+
+// Loki implements the MetricsQuerier interface with LogQL metric queries against the Loki query API.
+// Ingestion is the size of the log lines stored in Loki, whichever agent sent them. Loki does not know
+// the resource requests of workloads, they are queried from resources.
+type Loki struct {
+	url   string
+	orgId string
+	queryAPI
+	// namespaced groups streams by namespace and workload, see models.WorkloadKey
+	namespaced bool
+	// clusterMatcher is the operator matching the cluster label, = or =~
+	clusterMatcher string
+	labels         Labels
+	resources      MetricsQuerier
+}
+
+// NewLoki creates a Loki client querying the streams labelled with the labels of names, authenticated with auth,
+// answering resource queries with resources
+func NewLoki(url string, orgId string, queryTimeout time.Duration, namespaced bool, names Names, auth Auth, resources MetricsQuerier) (*Loki, error) {
+	if url == "" {
+		return nil, errors.New("Loki URL cannot be empty")
+	}
+	if orgId == "" {
+		return nil, errors.New("Loki orgId cannot be empty")
+	}
+	if err := names.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid metric names: %w", err)
+	}
+
+	// The Prometheus compatible query API of Loki is served below /loki
+	queryAPI, err := newQueryAPI("Loki", strings.TrimSuffix(url, "/")+"/loki", orgId, queryTimeout, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Loki{
+		url:            url,
+		orgId:          orgId,
+		queryAPI:       queryAPI,
+		namespaced:     namespaced,
+		clusterMatcher: names.ClusterMatcher,
+		labels:         names.Labels,
+		resources:      resources,
+	}, nil
+}
+
+// streamSelector returns the LogQL stream selector of the logs of a cluster
+func (l *Loki) streamSelector(cluster string) string {
+	return fmt.Sprintf("{%s%s`%s`}", l.labels.Cluster, l.clusterMatcher, cluster)
+}
+
+// GetIngestedGB retrieves the ingested bytes of all workloads in a cluster
+func (l *Loki) GetIngestedGB(cluster string, timeRange string) ([]models.WorkloadIngestedBytes, error) {
+	return l.GetIngestedGBOffset(cluster, timeRange, "")
+}
+
+// GetIngestedGBOffset retrieves the ingested bytes of all workloads in a cluster over
+// timeRange ending offset ago. An empty offset ends now.
+func (l *Loki) GetIngestedGBOffset(cluster string, timeRange string, offset string) ([]models.WorkloadIngestedBytes, error) {
+	if cluster == "" {
+		return nil, errors.New("cluster cannot be empty")
+	}
+
+	if timeRange == "" {
+		log.Info().Msg("timeRange is empty, using default timeRange")
+		timeRange = defaultTimeRange
+	}
+
+	rangeSelector := fmt.Sprintf("%s[%s]", l.streamSelector(cluster), timeRange)
+	if offset != "" {
+		rangeSelector += " offset " + offset
+	}
+	q := fmt.Sprintf("sum by (%s) (bytes_over_time(%s))", groupBy(l.labels, l.namespaced), rangeSelector)
+
+	result, err := l.query(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Loki: %w", err)
+	}
+
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("expected Vector result but got %T", result)
+	}
+
+	ingestedBytesList := make([]models.WorkloadIngestedBytes, 0, len(vector))
+	for _, sample := range vector {
+		ingestedBytesList = append(ingestedBytesList, models.WorkloadIngestedBytes{
+			Cluster:  string(sample.Metric[model.LabelName(l.labels.Cluster)]),
+			Workload: workloadKey(sample.Metric, l.labels, l.namespaced),
+			Value:    float64(sample.Value),
+		})
+	}
+
+	return ingestedBytesList, nil
+}
+
+// GetDailyIngestedGB retrieves the ingested gigabytes of every workload in a cluster for each of the
+// days whole days ending at end. Days without ingestion of a workload are left out of its list.
+// The query and its retries stop when ctx is done.
+func (l *Loki) GetDailyIngestedGB(ctx context.Context, cluster string, days int, end time.Time) (map[string][]models.GigaBytes, error) {
+	if cluster == "" {
+		return nil, errors.New("cluster cannot be empty")
+	}
+	if days <= 0 {
+		return nil, errors.New("days must be positive")
+	}
+
+	q := fmt.Sprintf("sum by (%s) (bytes_over_time(%s[1d]))", groupBy(l.labels, l.namespaced), l.streamSelector(cluster))
+
+	// Each step measures the day before it
+	result, err := l.queryRangeContext(ctx, q, v1.Range{
+		Start: end.Add(-time.Duration(days-1) * 24 * time.Hour),
+		End:   end,
+		Step:  24 * time.Hour,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily ingestion: %w", err)
+	}
+
+	matrix, ok := result.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("expected Matrix result but got %T", result)
+	}
+
+	daily := make(map[string][]models.GigaBytes, len(matrix))
+	for _, series := range matrix {
+		workload := workloadKey(series.Metric, l.labels, l.namespaced)
+		for _, sample := range series.Values {
+			daily[workload] = append(daily[workload], models.GigaBytes(float64(sample.Value)/1000000000.0))
+		}
+	}
+
+	return daily, nil
+}
+
+// GetBurnRate retrieves the average ingestion rate of every workload in a cluster over the window
+// ending at end, in bytes per second. The rate is sampled every burnRateStep with a range query.
+func (l *Loki) GetBurnRate(cluster string, window time.Duration, end time.Time) (map[string]float64, error) {
+	if cluster == "" {
+		return nil, errors.New("cluster cannot be empty")
+	}
+	if window < burnRateStep {
+		return nil, fmt.Errorf("window must be at least %s", burnRateStep)
+	}
+
+	q := fmt.Sprintf("sum by (%s) (bytes_rate(%s[%s]))", groupBy(l.labels, l.namespaced), l.streamSelector(cluster), model.Duration(burnRateStep))
+
+	result, err := l.queryRange(q, v1.Range{
+		Start: end.Add(-window + burnRateStep),
+		End:   end,
+		Step:  burnRateStep,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query burn rate: %w", err)
+	}
+
+	return averageRates(result, l.labels, l.namespaced)
+}
+
+// GetAvgWorkloadResourceRequest retrieves the average CPU and memory requests of workloads from the resources querier
+func (l *Loki) GetAvgWorkloadResourceRequest(cluster string, timeRange string) ([]models.WorkloadResourceRequest, error) {
+	return l.resources.GetAvgWorkloadResourceRequest(cluster, timeRange)
+}
+
+// GetAvgWorkloadReplicas retrieves the average number of replicas of workloads from the resources querier
+func (l *Loki) GetAvgWorkloadReplicas(cluster string, timeRange string) (map[string]float64, error) {
+	return l.resources.GetAvgWorkloadReplicas(cluster, timeRange)
+}
+
+// WithWorkloadLabels returns a copy of the client identifying workloads by the labels, in Loki and in the resources querier
+func (l *Loki) WithWorkloadLabels(labels []string) MetricsQuerier {
+	c := *l
+	c.labels.Workload = labels
+	c.resources = l.resources.WithWorkloadLabels(labels)
+	return &c
+}
+
+// String returns a string representation of the Loki struct
+func (l *Loki) String() string {
+	return fmt.Sprintf("Loki{url: %s, orgId: %s, queryTimeout: %s}", l.url, l.orgId, l.queryTimeout)
+}
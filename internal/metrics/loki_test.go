@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"configurator/internal/models"
+)
+
+// lokiStandIn serves the query API of Loki, answering every query with the result and recording the queries
+func lokiStandIn(t *testing.T, result string, queries *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenant := r.Header.Get("X-Scope-OrgID"); tenant != "tenant" {
+			t.Errorf("expected the tenant header, got %q", tenant)
+		}
+		if r.URL.Path != "/loki/api/v1/query" && r.URL.Path != "/loki/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		*queries = append(*queries, r.FormValue("query"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":`+result+`}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// resourceStub answers the resource queries of Loki
+type resourceStub struct {
+	MetricsQuerier
+	labels []string
+}
+
+func (r resourceStub) GetAvgWorkloadResourceRequest(cluster string, _ string) ([]models.WorkloadResourceRequest, error) {
+	return []models.WorkloadResourceRequest{{Cluster: cluster, Workload: "checkout", CPU: 2}}, nil
+}
+
+func (r resourceStub) WithWorkloadLabels(labels []string) MetricsQuerier {
+	return resourceStub{labels: labels}
+}
+
+func TestLokiIngestedGB(t *testing.T) {
+	var queries []string
+	server := lokiStandIn(t, `{"resultType":"vector","result":[
+		{"metric":{"cluster":"cluster-001","workload":"checkout"},"value":[1760000000,"2500000000"]},
+		{"metric":{"cluster":"cluster-001","workload":"search"},"value":[1760000000,"1000"]}
+	],"stats":{}}`, &queries)
+
+	names := testNames
+	names.ClusterMatcher = "=~"
+	l, err := NewLoki(server.URL, "tenant", time.Second, false, names, Auth{}, resourceStub{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	ingested, err := l.GetIngestedGBOffset("cluster-001", "24h", "1d")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := "sum by (cluster, workload) (bytes_over_time({cluster=~`cluster-001`}[24h] offset 1d))"; len(queries) != 1 || queries[0] != want {
+		t.Errorf("expected query %s, got %v", want, queries)
+	}
+	if len(ingested) != 2 || ingested[0].Workload != "checkout" || ingested[0].Value != 2500000000 || ingested[0].Cluster != "cluster-001" {
+		t.Errorf("expected the ingestion of checkout and search, got %+v", ingested)
+	}
+
+	// Workloads of a target with other labels, resources still come from the resources querier
+	target := l.WithWorkloadLabels([]string{"app", "container"})
+	queries = nil
+	if _, err := target.GetIngestedGB("cluster-001", "1h"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := "sum by (cluster, app, container) (bytes_over_time({cluster=~`cluster-001`}[1h]))"; len(queries) != 1 || queries[0] != want {
+		t.Errorf("expected query %s, got %v", want, queries)
+	}
+	resources, err := target.GetAvgWorkloadResourceRequest("cluster-001", "1h")
+	if err != nil || len(resources) != 1 || resources[0].CPU != 2 {
+		t.Errorf("expected the resources of the resources querier, got %+v, %v", resources, err)
+	}
+	if got := target.(*Loki).resources.(resourceStub).labels; len(got) != 2 {
+		t.Errorf("expected the resources querier to use the target labels, got %v", got)
+	}
+}
+
+func TestLokiDailyIngestedGB(t *testing.T) {
+	var queries []string
+	server := lokiStandIn(t, `{"resultType":"matrix","result":[
+		{"metric":{"cluster":"cluster-001","namespace":"shop","workload":"checkout"},"values":[[1760000000,"1000000000"],[1760086400,"3000000000"]]}
+	]}`, &queries)
+
+	l, err := NewLoki(server.URL+"/", "tenant", time.Second, true, testNames, Auth{}, resourceStub{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	daily, err := l.GetDailyIngestedGB(context.Background(), "cluster-001", 2, time.Date(2025, 10, 21, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := "sum by (cluster, namespace, workload) (bytes_over_time({cluster=`cluster-001`}[1d]))"; len(queries) != 1 || queries[0] != want {
+		t.Errorf("expected query %s, got %v", want, queries)
+	}
+	if got := daily["shop/checkout"]; len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("expected 1GB and 3GB for shop/checkout, got %v", daily)
+	}
+}
+
+func TestLokiDailyIngestedGBCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	l, err := NewLoki(server.URL, "tenant", time.Second, false, testNames, Auth{}, resourceStub{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// A failing query is retried for up to an hour unless the caller gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := l.GetDailyIngestedGB(ctx, "cluster-001", 2, time.Now()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the query to stop with its context, took %s", elapsed)
+	}
+}
+
+func TestLokiBurnRate(t *testing.T) {
+	var queries []string
+	server := lokiStandIn(t, `{"resultType":"matrix","result":[
+		{"metric":{"cluster":"cluster-001","workload":"checkout"},"values":[[1760000000,"1000"],[1760000300,"3000"]]},
+		{"metric":{"cluster":"cluster-001","workload":"search"},"values":[]}
+	]}`, &queries)
+
+	l, err := NewLoki(server.URL, "tenant", time.Second, false, testNames, Auth{}, resourceStub{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := l.GetBurnRate("cluster-001", time.Minute, time.Now()); err == nil {
+		t.Error("expected an error for a window shorter than the step")
+	}
+
+	rates, err := l.GetBurnRate("cluster-001", time.Hour, time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := "sum by (cluster, workload) (bytes_rate({cluster=`cluster-001`}[5m]))"; len(queries) != 1 || queries[0] != want {
+		t.Errorf("expected query %s, got %v", want, queries)
+	}
+	if _, ok := rates["search"]; len(rates) != 1 || rates["checkout"] != 2000 || ok {
+		t.Errorf("expected the average rate of checkout only, got %v", rates)
+	}
+}
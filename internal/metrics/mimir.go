@@ -6,9 +6,6 @@ import (
 	"time"
 
 	"net/http"
-
-	"github.com/prometheus/client_golang/api"
-	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
 // RoundTrip implements the http.RoundTripper interface
@@ -17,35 +14,30 @@ func (rt *HeaderRoundTripper) RoundTrip(req *http.Request) (*http.Response, erro
 	return rt.RoundTripper.RoundTrip(req)
 }
 
-// New creates and initializes a new Mimir client
-func New(url string, orgId string, queryTimeout time.Duration) (*Mimir, error) {
+// New creates and initializes a new Mimir client querying the metrics and labels of names.
+// With namespaced, workloads are told apart by namespace and name.
+func New(url string, orgId string, queryTimeout time.Duration, namespaced bool, names Names, auth Auth) (*Mimir, error) {
 	if url == "" {
 		return nil, errors.New("Mimir URL cannot be empty")
 	}
 	if orgId == "" {
 		return nil, errors.New("Mimir orgId cannot be empty")
 	}
-
-	// Create the config with authentication
-	config := api.Config{
-		Address: url,
-		RoundTripper: &HeaderRoundTripper{
-			RoundTripper: http.DefaultTransport,
-			OrgID:        orgId,
-		},
+	if err := names.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid metric names: %w", err)
 	}
 
-	// Create a new Prometheus API client
-	client, err := api.NewClient(config)
+	queryAPI, err := newQueryAPI("Mimir", url, orgId, queryTimeout, auth)
 	if err != nil {
-		return nil, fmt.Errorf("error creating Mimir client: %w", err)
+		return nil, err
 	}
 
 	return &Mimir{
-		url:          url,
-		orgId:        orgId,
-		queryTimeout: queryTimeout,
-		client:       v1.NewAPI(client),
+		url:        url,
+		orgId:      orgId,
+		queryAPI:   queryAPI,
+		namespaced: namespaced,
+		names:      names,
 	}, nil
 }
 
@@ -2,25 +2,33 @@ package metrics
 
 import (
 	"configurator/internal/models"
+	"context"
 	"time"
 
 	"net/http"
-
-	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
 // MetricsQuerier defines the interface for querying metrics
 type MetricsQuerier interface {
 	GetIngestedGB(cluster string, timeRange string) ([]models.WorkloadIngestedBytes, error)
+	GetIngestedGBOffset(cluster string, timeRange string, offset string) ([]models.WorkloadIngestedBytes, error)
 	GetAvgWorkloadResourceRequest(cluster string, timeRange string) ([]models.WorkloadResourceRequest, error)
+	GetAvgWorkloadReplicas(cluster string, timeRange string) (map[string]float64, error)
+	GetDailyIngestedGB(ctx context.Context, cluster string, days int, end time.Time) (map[string][]models.GigaBytes, error)
+	// GetBurnRate retrieves the average ingestion rate of every workload over the window ending at end, in bytes per second
+	GetBurnRate(cluster string, window time.Duration, end time.Time) (map[string]float64, error)
+	// WithWorkloadLabels returns a querier identifying workloads by the labels
+	WithWorkloadLabels(labels []string) MetricsQuerier
 }
 
 // Mimir implements the MetricsQuerier interface for Mimir/Prometheus metrics
 type Mimir struct {
-	url          string
-	orgId        string
-	queryTimeout time.Duration
-	client       v1.API
+	url   string
+	orgId string
+	queryAPI
+	// namespaced groups series by namespace and workload, see models.WorkloadKey
+	namespaced bool
+	names      Names
 }
 
 // HeaderRoundTripper adds the X-Scope-OrgID header to each request
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Labels are the labels identifying the cluster, namespace and workload of a series.
+// A workload is identified by the values of one or more labels.
+type Labels struct {
+	Cluster   string
+	Namespace string
+	Workload  []string
+}
+
+// Names are the metrics and labels queried from Mimir. A metric can carry label matchers,
+// e.g. kube_pod_container_resource_requests{resource="cpu"}.
+type Names struct {
+	LogBytes      string
+	CPURequest    string
+	MemoryRequest string
+	Replicas      string
+	// ClusterMatcher is the operator matching the cluster label, = or =~
+	ClusterMatcher string
+	// Labels group the log bytes metric
+	Labels Labels
+	// ResourceLabels group the CPU, memory and replica metrics, their workload labels default to those of Labels
+	ResourceLabels Labels
+}
+
+// Validate checks that the metrics and labels are valid names and the cluster matcher a known operator
+func (n Names) Validate() error {
+	var errs []error
+	for _, m := range []struct{ name, metric string }{
+		{"log_bytes", n.LogBytes},
+		{"cpu_request", n.CPURequest},
+		{"memory_request", n.MemoryRequest},
+		{"replicas", n.Replicas},
+	} {
+		if err := validateMetric(m.metric); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		}
+	}
+	if n.ClusterMatcher != "=" && n.ClusterMatcher != "=~" {
+		errs = append(errs, fmt.Errorf("cluster_matcher: unknown operator %q, use = or =~", n.ClusterMatcher))
+	}
+	errs = append(errs, n.Labels.validate("labels"), n.ResourceLabels.validate("resource_labels"))
+	if len(n.Labels.Workload) == 0 {
+		errs = append(errs, errors.New("labels.workload: no workload labels"))
+	}
+	if len(n.ResourceLabels.Workload) > 0 && len(n.ResourceLabels.Workload) != len(n.Labels.Workload) {
+		errs = append(errs, fmt.Errorf("resource_labels.workload: %d labels for %d workload labels", len(n.ResourceLabels.Workload), len(n.Labels.Workload)))
+	}
+	return errors.Join(errs...)
+}
+
+func (l Labels) validate(prefix string) error {
+	var errs []error
+	fields := []struct{ name, label string }{
+		{"cluster", l.Cluster},
+		{"namespace", l.Namespace},
+	}
+	for _, label := range l.Workload {
+		fields = append(fields, struct{ name, label string }{"workload", label})
+	}
+	for _, f := range fields {
+		if !model.LabelName(f.label).IsValid() {
+			errs = append(errs, fmt.Errorf("%s.%s: invalid label name %q", prefix, f.name, f.label))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateMetric checks a metric name with optional label matchers in braces
+func validateMetric(metric string) error {
+	name, matchers, hasMatchers := strings.Cut(metric, "{")
+	if !model.IsValidMetricName(model.LabelValue(name)) {
+		return fmt.Errorf("invalid metric name %q", name)
+	}
+	if hasMatchers && (!strings.HasSuffix(matchers, "}") || strings.TrimSpace(strings.TrimSuffix(matchers, "}")) == "") {
+		return fmt.Errorf("invalid label matchers in %q", metric)
+	}
+	return nil
+}
+
+// selector returns the series selector of the metric limited to the cluster
+func (n Names) selector(metric string, labels Labels, cluster string) string {
+	matcher := fmt.Sprintf("%s%s'%s'", labels.Cluster, n.ClusterMatcher, cluster)
+	if matchers, ok := strings.CutSuffix(metric, "}"); ok {
+		return matchers + ", " + matcher + "}"
+	}
+	return metric + "{" + matcher + "}"
+}
+
+// resourceLabels returns the labels of the resource metrics with the workload labels of the log bytes metric if unset
+func (n Names) resourceLabels() Labels {
+	labels := n.ResourceLabels
+	if len(labels.Workload) == 0 {
+		labels.Workload = n.Labels.Workload
+	}
+	return labels
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+var testNames = Names{
+	LogBytes:       "promtail_custom_processed_log_bytes_total",
+	CPURequest:     `kube_pod_container_resource_requests{resource="cpu"}`,
+	MemoryRequest:  `kube_pod_container_resource_requests{resource="memory"}`,
+	Replicas:       "workload_replicas",
+	ClusterMatcher: "=",
+	Labels:         Labels{Cluster: "cluster", Namespace: "namespace", Workload: []string{"workload"}},
+	ResourceLabels: Labels{Cluster: "k8s_cluster", Namespace: "namespace", Workload: []string{"container"}},
+}
+
+func TestNamesValidate(t *testing.T) {
+	if err := testNames.Validate(); err != nil {
+		t.Fatalf("expected valid names, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(n *Names)
+	}{
+		{name: "Empty metric", modify: func(n *Names) { n.Replicas = "" }},
+		{name: "Invalid metric name", modify: func(n *Names) { n.LogBytes = "log-bytes" }},
+		{name: "Unclosed matchers", modify: func(n *Names) { n.CPURequest = `requests{resource="cpu"` }},
+		{name: "Unknown cluster matcher", modify: func(n *Names) { n.ClusterMatcher = "!=" }},
+		{name: "Invalid label", modify: func(n *Names) { n.ResourceLabels.Workload = []string{"app.kubernetes.io/name"} }},
+		{name: "No workload labels", modify: func(n *Names) { n.Labels.Workload = nil }},
+		{name: "Resource labels of another dimension", modify: func(n *Names) { n.ResourceLabels.Workload = []string{"app", "container"} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNames
+			tt.modify(&n)
+			if err := n.Validate(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestQueries(t *testing.T) {
+	m := &Mimir{names: testNames}
+
+	if got, want := m.names.selector(m.names.LogBytes, m.names.Labels, "cluster-001"), `promtail_custom_processed_log_bytes_total{cluster='cluster-001'}`; got != want {
+		t.Errorf("expected selector %s, got %s", want, got)
+	}
+	if got, want := m.names.selector(m.names.CPURequest, m.names.ResourceLabels, "cluster-001"), `kube_pod_container_resource_requests{resource="cpu", k8s_cluster='cluster-001'}`; got != want {
+		t.Errorf("expected selector %s, got %s", want, got)
+	}
+	if got, want := m.groupBy(m.names.resourceLabels()), "k8s_cluster, container"; got != want {
+		t.Errorf("expected grouping %s, got %s", want, got)
+	}
+
+	m.namespaced = true
+	if got, want := m.groupBy(m.names.resourceLabels()), "k8s_cluster, namespace, container"; got != want {
+		t.Errorf("expected grouping %s, got %s", want, got)
+	}
+
+	// Resource metrics use the workload labels of the target unless they have their own
+	m = m.WithWorkloadLabels([]string{"app", "component"}).(*Mimir)
+	m.names.ResourceLabels.Workload = nil
+	if got, want := m.groupBy(m.names.resourceLabels()), "k8s_cluster, namespace, app, component"; got != want {
+		t.Errorf("expected grouping %s, got %s", want, got)
+	}
+	series := model.Metric{"namespace": "shop", "app": "checkout", "component": "server"}
+	if got, want := m.workloadKey(series, m.names.Labels), "shop/checkout:server"; got != want {
+		t.Errorf("expected workload key %s, got %s", want, got)
+	}
+}
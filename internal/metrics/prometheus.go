@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -17,7 +19,25 @@ var (
 			Name: MetricsPrefix + "task_executions_total",
 			Help: "Total number of task executions",
 		},
-		[]string{"status"},
+		[]string{"task", "status"},
+	)
+
+	// targetExecutionCount tracks the outcome of each task for every target
+	targetExecutionCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: MetricsPrefix + "target_executions_total",
+			Help: "Total number of task executions per target",
+		},
+		[]string{"task", "target", "status"},
+	)
+
+	// targetLastSuccess tracks when a task last succeeded for every target
+	targetLastSuccess = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: MetricsPrefix + "target_last_success_timestamp_seconds",
+			Help: "Timestamp of the last successful task execution per target",
+		},
+		[]string{"task", "target"},
 	)
 
 	// SamplingMetrics tracks sampling information for workloads
@@ -28,6 +48,77 @@ var (
 		},
 		[]string{"workload", "cluster", "metric_type"},
 	)
+
+	// poolMetrics tracks the total ingestion and budget of budget pools
+	poolMetrics = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: MetricsPrefix + "pool_budget_info",
+			Help: "Information about budget pools including their total ingestion and budget",
+		},
+		[]string{"pool", "cluster", "metric_type"},
+	)
+
+	// ingestionCapMetrics tracks the total ingestion and ingestion cap of clusters
+	ingestionCapMetrics = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: MetricsPrefix + "ingestion_cap_info",
+			Help: "Information about cluster ingestion caps including the total ingestion, the cap and the number of capped workloads",
+		},
+		[]string{"target", "cluster", "metric_type"},
+	)
+
+	// forecastMetrics tracks the burn rate and projected end-of-day ingestion of workloads
+	forecastMetrics = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: MetricsPrefix + "ingestion_forecast_info",
+			Help: "Forecast of workload ingestion including the burn rate in GB per hour, the projected ingestion at the end of the budget day and the projected overrun of the budget",
+		},
+		[]string{"target", "workload", "metric_type"},
+	)
+
+	// leaderStatus tracks whether this instance currently holds the leader Lease
+	leaderStatus = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: MetricsPrefix + "leader",
+			Help: "Whether this instance is the leader running the scheduled tasks (1) or a standby (0)",
+		},
+	)
+
+	// exemptOverBudget tracks the workloads which are over budget but not sampled because of an exemption
+	exemptOverBudget = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: MetricsPrefix + "exempt_over_budget_workload",
+			Help: "Workloads over budget which are not sampled because of an exemption, the value is the expiry timestamp of the exemption",
+		},
+		[]string{"target", "workload", "owner"},
+	)
+
+	// configReloads tracks reloads of the config and budget files
+	configReloads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: MetricsPrefix + "config_reloads_total",
+			Help: "Total number of config file reloads",
+		},
+		[]string{"file", "status"},
+	)
+
+	// configLastReloadSuccessful tracks whether the last reload of a config file was applied
+	configLastReloadSuccessful = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: MetricsPrefix + "config_last_reload_successful",
+			Help: "Whether the last reload of the config file was applied (1) or rejected and the previous config kept (0)",
+		},
+		[]string{"file"},
+	)
+
+	// configLastReloadSuccessTime tracks when a config file was last applied
+	configLastReloadSuccessTime = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: MetricsPrefix + "config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last applied reload of the config file",
+		},
+		[]string{"file"},
+	)
 )
 
 // RecordSamplingMetrics records sampling metrics for a workload
@@ -37,11 +128,77 @@ func RecordSamplingMetrics(workload, cluster string, currentIngestion, budget, s
 	samplingMetrics.WithLabelValues(workload, cluster, "sampling_percentage").Set(samplingPercentage)
 }
 
-// RecordTaskExecution records the execution of the task job
-func RecordTaskExecution(success bool) {
+// RecordPoolMetrics records the total ingestion and budget of a budget pool
+func RecordPoolMetrics(pool, cluster string, currentIngestion, budget float64) {
+	poolMetrics.WithLabelValues(pool, cluster, "current_ingestion").Set(currentIngestion)
+	poolMetrics.WithLabelValues(pool, cluster, "daily_budget").Set(budget)
+}
+
+// RecordIngestionCapMetrics records the total ingestion, ingestion cap and number of capped workloads of a target's cluster
+func RecordIngestionCapMetrics(target, cluster string, currentIngestion, ingestionCap float64, capped int) {
+	ingestionCapMetrics.WithLabelValues(target, cluster, "current_ingestion").Set(currentIngestion)
+	ingestionCapMetrics.WithLabelValues(target, cluster, "daily_cap").Set(ingestionCap)
+	ingestionCapMetrics.WithLabelValues(target, cluster, "capped_workloads").Set(float64(capped))
+}
+
+// ResetForecastMetrics removes the forecasts recorded for a target
+func ResetForecastMetrics(target string) {
+	forecastMetrics.DeletePartialMatch(prometheus.Labels{"target": target})
+}
+
+// RecordForecastMetrics records the burn rate, projected ingestion and projected overrun of a workload
+func RecordForecastMetrics(target, workload string, burnRate, projectedIngestion, projectedOverrun float64) {
+	forecastMetrics.WithLabelValues(target, workload, "burn_rate").Set(burnRate)
+	forecastMetrics.WithLabelValues(target, workload, "projected_ingestion").Set(projectedIngestion)
+	forecastMetrics.WithLabelValues(target, workload, "projected_overrun").Set(projectedOverrun)
+}
+
+// RecordTaskExecution records the execution of the given task job
+func RecordTaskExecution(task string, success bool) {
+	if success {
+		cronExecutionCount.WithLabelValues(task, "success").Inc()
+	} else {
+		cronExecutionCount.WithLabelValues(task, "failure").Inc()
+	}
+}
+
+// RecordTargetExecution records the execution of the given task for a single target
+func RecordTargetExecution(task, target string, success bool) {
+	if success {
+		targetExecutionCount.WithLabelValues(task, target, "success").Inc()
+		targetLastSuccess.WithLabelValues(task, target).SetToCurrentTime()
+	} else {
+		targetExecutionCount.WithLabelValues(task, target, "failure").Inc()
+	}
+}
+
+// ResetExemptOverBudget removes the exempt over-budget workloads recorded for a target
+func ResetExemptOverBudget(target string) {
+	exemptOverBudget.DeletePartialMatch(prometheus.Labels{"target": target})
+}
+
+// RecordExemptOverBudget records a workload which is over budget but exempt from sampling until expiresAt
+func RecordExemptOverBudget(target, workload, owner string, expiresAt time.Time) {
+	exemptOverBudget.WithLabelValues(target, workload, owner).Set(float64(expiresAt.Unix()))
+}
+
+// RecordLeadership records whether this instance currently holds leadership
+func RecordLeadership(isLeader bool) {
+	if isLeader {
+		leaderStatus.Set(1)
+	} else {
+		leaderStatus.Set(0)
+	}
+}
+
+// RecordConfigReload records the outcome of reloading the given config file
+func RecordConfigReload(file string, success bool) {
 	if success {
-		cronExecutionCount.WithLabelValues("success").Inc()
+		configReloads.WithLabelValues(file, "success").Inc()
+		configLastReloadSuccessful.WithLabelValues(file).Set(1)
+		configLastReloadSuccessTime.WithLabelValues(file).SetToCurrentTime()
 	} else {
-		cronExecutionCount.WithLabelValues("failure").Inc()
+		configReloads.WithLabelValues(file, "failure").Inc()
+		configLastReloadSuccessful.WithLabelValues(file).Set(0)
 	}
 }
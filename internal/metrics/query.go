@@ -5,45 +5,108 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"github.com/rs/zerolog/log"
 )
 
-// Constants for metric names and defaults
+// Constants for defaults
 const (
-	logBytesMetric              = "promtail_custom_processed_log_bytes_total"
-	workloadCPURequestMetric    = "workload_cpu_request"
-	workloadMemoryRequestMetric = "workload_memory_request"
-	defaultTimeRange            = "24h"
+	defaultTimeRange = "24h"
+	// burnRateStep is the resolution of burn rate range queries and the range each rate is measured over
+	burnRateStep = 5 * time.Minute
 )
 
-// query executes a PromQL query against the Mimir instance with retry logic
-func (m *Mimir) query(query string) (model.Value, error) {
+// queryAPI runs queries against a Prometheus compatible query API, such as the ones of Mimir and Loki
+type queryAPI struct {
+	// name of the queried system in logs and errors
+	name         string
+	queryTimeout time.Duration
+	client       v1.API
+}
+
+// newQueryAPI creates a client of the query API at url sending the tenant in the X-Scope-OrgID header,
+// authenticated as configured by auth
+func newQueryAPI(name string, url string, tenant string, queryTimeout time.Duration, auth Auth) (queryAPI, error) {
+	transport, err := auth.transport()
+	if err != nil {
+		return queryAPI{}, fmt.Errorf("invalid %s auth: %w", name, err)
+	}
+	client, err := api.NewClient(api.Config{
+		Address: url,
+		RoundTripper: &HeaderRoundTripper{
+			RoundTripper: transport,
+			OrgID:        tenant,
+		},
+	})
+	if err != nil {
+		return queryAPI{}, fmt.Errorf("error creating %s client: %w", name, err)
+	}
+	return queryAPI{name: name, queryTimeout: queryTimeout, client: v1.NewAPI(client)}, nil
+}
+
+// query executes an instant query with retry logic
+func (m *queryAPI) query(query string) (model.Value, error) {
+	log.Trace().
+		Str("source", m.name).
+		Str("query", query).
+		Msg("Querying metrics")
+
+	var result model.Value
+	err := m.retry(context.Background(), func(ctx context.Context) (v1.Warnings, error) {
+		var warnings v1.Warnings
+		var err error
+		result, warnings, err = m.client.Query(ctx, query, time.Now(), v1.WithTimeout(m.queryTimeout))
+		return warnings, err
+	})
+	return result, err
+}
+
+// queryRange executes a range query with retry logic
+func (m *queryAPI) queryRange(query string, r v1.Range) (model.Value, error) {
+	return m.queryRangeContext(context.Background(), query, r)
+}
+
+// queryRangeContext executes a range query with retry logic until it succeeds or ctx is done
+func (m *queryAPI) queryRangeContext(ctx context.Context, query string, r v1.Range) (model.Value, error) {
 	log.Trace().
+		Str("source", m.name).
 		Str("query", query).
-		Msg("Querying Mimir")
+		Time("start", r.Start).
+		Time("end", r.End).
+		Dur("step", r.Step).
+		Msg("Querying metrics range")
 
 	var result model.Value
-	var warnings v1.Warnings
+	err := m.retry(ctx, func(ctx context.Context) (v1.Warnings, error) {
+		var warnings v1.Warnings
+		var err error
+		result, warnings, err = m.client.QueryRange(ctx, query, r, v1.WithTimeout(m.queryTimeout))
+		return warnings, err
+	})
+	return result, err
+}
 
+// retry runs a query with the query timeout, retrying with exponential backoff until it succeeds or ctx is done
+func (m *queryAPI) retry(ctx context.Context, run func(ctx context.Context) (v1.Warnings, error)) error {
 	operation := func() error {
-		ctx, cancel := context.WithTimeout(context.Background(), m.queryTimeout)
+		ctx, cancel := context.WithTimeout(ctx, m.queryTimeout)
 		defer cancel()
 
-		log.Debug().Str("timeout", m.queryTimeout.String()).Msg("Querying Mimir with timeout")
-		var err error
-		result, warnings, err = m.client.Query(ctx, query, time.Now(), v1.WithTimeout(m.queryTimeout))
+		log.Debug().Str("source", m.name).Str("timeout", m.queryTimeout.String()).Msg("Querying metrics with timeout")
+		warnings, err := run(ctx)
 
 		if err != nil {
-			log.Error().Err(err).Msg("Error querying Mimir")
+			log.Error().Err(err).Str("source", m.name).Msg("Error querying metrics")
 			return err
 		}
 		if len(warnings) > 0 {
-			log.Warn().Strs("warnings", warnings).Msg("Warnings from Mimir query")
+			log.Warn().Str("source", m.name).Strs("warnings", warnings).Msg("Warnings from metrics query")
 		}
 		return nil
 	}
@@ -58,22 +121,90 @@ func (m *Mimir) query(query string) (model.Value, error) {
 
 	err := backoff.RetryNotify(
 		operation,
-		expBackoff,
+		backoff.WithContext(expBackoff, ctx),
 		func(err error, duration time.Duration) {
 			log.Warn().Err(err).Dur("retry_in", duration).Msg("Query failed, will retry")
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to query Mimir after retries: %w", err)
+		return fmt.Errorf("failed to query %s after retries: %w", m.name, err)
 	}
 
 	log.Trace().Dur("duration", time.Since(startTime)).Msg("Query completed")
 
-	return result, nil
+	return nil
+}
+
+// averageRates returns the average of the samples of each workload in a matrix of rates
+func averageRates(result model.Value, labels Labels, namespaced bool) (map[string]float64, error) {
+	matrix, ok := result.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("expected Matrix result but got %T", result)
+	}
+
+	rates := make(map[string]float64, len(matrix))
+	for _, series := range matrix {
+		if len(series.Values) == 0 {
+			continue
+		}
+		var sum float64
+		for _, sample := range series.Values {
+			sum += float64(sample.Value)
+		}
+		rates[workloadKey(series.Metric, labels, namespaced)] = sum / float64(len(series.Values))
+	}
+	return rates, nil
+}
+
+// groupBy returns the labels identifying the workload of a series
+func (m *Mimir) groupBy(labels Labels) string {
+	return groupBy(labels, m.namespaced)
+}
+
+// workloadKey returns the key of the workload of a series
+func (m *Mimir) workloadKey(metric model.Metric, labels Labels) string {
+	return workloadKey(metric, labels, m.namespaced)
+}
+
+// groupBy returns the labels identifying the workload of a series, with the namespace if workloads are namespaced
+func groupBy(labels Labels, namespaced bool) string {
+	grouping := []string{labels.Cluster}
+	if namespaced {
+		grouping = append(grouping, labels.Namespace)
+	}
+	return strings.Join(append(grouping, labels.Workload...), ", ")
+}
+
+// workloadKey returns the key of the workload of a series, the values of its workload labels
+// prefixed with its namespace if workloads are namespaced
+func workloadKey(metric model.Metric, labels Labels, namespaced bool) string {
+	values := make([]string, len(labels.Workload))
+	for i, label := range labels.Workload {
+		values[i] = string(metric[model.LabelName(label)])
+	}
+	name := models.JoinWorkloadName(values)
+	if namespaced {
+		return models.WorkloadKey(string(metric[model.LabelName(labels.Namespace)]), name)
+	}
+	return name
+}
+
+// WithWorkloadLabels returns a copy of the client identifying workloads by the labels,
+// e.g. for a target whose workloads are labelled differently
+func (m *Mimir) WithWorkloadLabels(labels []string) MetricsQuerier {
+	c := *m
+	c.names.Labels.Workload = labels
+	return &c
 }
 
 // GetIngestedGB retrieves the ingested gigabytes for all workloads in a cluster
 func (m *Mimir) GetIngestedGB(cluster string, timeRange string) ([]models.WorkloadIngestedBytes, error) {
+	return m.GetIngestedGBOffset(cluster, timeRange, "")
+}
+
+// GetIngestedGBOffset retrieves the ingested gigabytes for all workloads in a cluster over
+// timeRange ending offset ago, e.g. the previous budget period. An empty offset ends now.
+func (m *Mimir) GetIngestedGBOffset(cluster string, timeRange string, offset string) ([]models.WorkloadIngestedBytes, error) {
 	log.Trace().Msg("Fetching total ingestion for workloads")
 
 	if cluster == "" {
@@ -85,12 +216,11 @@ func (m *Mimir) GetIngestedGB(cluster string, timeRange string) ([]models.Worklo
 		timeRange = defaultTimeRange
 	}
 
-	q := fmt.Sprintf(
-		"sum by (cluster, workload) (increase(%s{cluster=~'%s'}[%s]))",
-		logBytesMetric,
-		cluster,
-		timeRange,
-	)
+	selector := fmt.Sprintf("%s[%s]", m.names.selector(m.names.LogBytes, m.names.Labels, cluster), timeRange)
+	if offset != "" {
+		selector += " offset " + offset
+	}
+	q := fmt.Sprintf("sum by (%s) (increase(%s))", m.groupBy(m.names.Labels), selector)
 
 	result, err := m.query(q)
 	if err != nil {
@@ -108,8 +238,8 @@ func (m *Mimir) GetIngestedGB(cluster string, timeRange string) ([]models.Worklo
 
 	for _, sample := range matrix {
 		ingestedBytesList = append(ingestedBytesList, models.WorkloadIngestedBytes{
-			Cluster:  string(sample.Metric["cluster"]),
-			Workload: string(sample.Metric["workload"]),
+			Cluster:  string(sample.Metric[model.LabelName(m.names.Labels.Cluster)]),
+			Workload: m.workloadKey(sample.Metric, m.names.Labels),
 			Value:    float64(sample.Value),
 		})
 	}
@@ -130,17 +260,17 @@ func (m *Mimir) GetAvgWorkloadResourceRequest(cluster string, timeRange string)
 
 	// Query CPU requests
 	cpuQuery := fmt.Sprintf(
-		"sum by (cluster, workload) (avg_over_time(%s{cluster=~'%s'}[%s]))",
-		workloadCPURequestMetric,
-		cluster,
+		"sum by (%s) (avg_over_time(%s[%s]))",
+		m.groupBy(m.names.resourceLabels()),
+		m.names.selector(m.names.CPURequest, m.names.resourceLabels(), cluster),
 		timeRange,
 	)
 
 	// Query memory requests
 	memQuery := fmt.Sprintf(
-		"sum by (cluster, workload) (avg_over_time(%s{cluster=~'%s'}[%s]))",
-		workloadMemoryRequestMetric,
-		cluster,
+		"sum by (%s) (avg_over_time(%s[%s]))",
+		m.groupBy(m.names.resourceLabels()),
+		m.names.selector(m.names.MemoryRequest, m.names.resourceLabels(), cluster),
 		timeRange,
 	)
 
@@ -171,11 +301,11 @@ func (m *Mimir) GetAvgWorkloadResourceRequest(cluster string, timeRange string)
 	memoryRequest := make(map[string]models.Bytes)
 
 	for _, sample := range memVector {
-		memoryRequest[string(sample.Metric["workload"])] = models.Bytes(sample.Value)
+		memoryRequest[m.workloadKey(sample.Metric, m.names.resourceLabels())] = models.Bytes(sample.Value)
 	}
 
 	for _, sample := range cpuVector {
-		cpuRequest[string(sample.Metric["workload"])] = models.Cores(sample.Value)
+		cpuRequest[m.workloadKey(sample.Metric, m.names.resourceLabels())] = models.Cores(sample.Value)
 	}
 
 	// Combine results into workload resources
@@ -192,3 +322,106 @@ func (m *Mimir) GetAvgWorkloadResourceRequest(cluster string, timeRange string)
 
 	return workloadsResources, nil
 }
+
+// GetAvgWorkloadReplicas retrieves the average number of replicas of workloads
+func (m *Mimir) GetAvgWorkloadReplicas(cluster string, timeRange string) (map[string]float64, error) {
+	if cluster == "" {
+		return nil, errors.New("cluster cannot be empty")
+	}
+
+	if timeRange == "" {
+		log.Info().Msg("timeRange is empty, using default timeRange")
+		timeRange = defaultTimeRange
+	}
+
+	q := fmt.Sprintf(
+		"sum by (%s) (avg_over_time(%s[%s]))",
+		m.groupBy(m.names.resourceLabels()),
+		m.names.selector(m.names.Replicas, m.names.resourceLabels(), cluster),
+		timeRange,
+	)
+
+	result, err := m.query(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replica metrics: %w", err)
+	}
+
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("expected Vector result for replicas but got %T", result)
+	}
+
+	replicas := make(map[string]float64, len(vector))
+	for _, sample := range vector {
+		replicas[m.workloadKey(sample.Metric, m.names.resourceLabels())] = float64(sample.Value)
+	}
+
+	return replicas, nil
+}
+
+// GetDailyIngestedGB retrieves the ingested gigabytes of every workload in a cluster for each of the
+// days whole days ending at end. Days without ingestion of a workload are left out of its list.
+// The query and its retries stop when ctx is done.
+func (m *Mimir) GetDailyIngestedGB(ctx context.Context, cluster string, days int, end time.Time) (map[string][]models.GigaBytes, error) {
+	if cluster == "" {
+		return nil, errors.New("cluster cannot be empty")
+	}
+	if days <= 0 {
+		return nil, errors.New("days must be positive")
+	}
+
+	q := fmt.Sprintf("sum by (%s) (increase(%s[1d]))", m.groupBy(m.names.Labels), m.names.selector(m.names.LogBytes, m.names.Labels, cluster))
+
+	// Each step measures the day before it
+	result, err := m.queryRangeContext(ctx, q, v1.Range{
+		Start: end.Add(-time.Duration(days-1) * 24 * time.Hour),
+		End:   end,
+		Step:  24 * time.Hour,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily ingestion: %w", err)
+	}
+
+	matrix, ok := result.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("expected Matrix result but got %T", result)
+	}
+
+	daily := make(map[string][]models.GigaBytes, len(matrix))
+	for _, series := range matrix {
+		workload := m.workloadKey(series.Metric, m.names.Labels)
+		for _, sample := range series.Values {
+			daily[workload] = append(daily[workload], models.GigaBytes(float64(sample.Value)/1000000000.0))
+		}
+	}
+
+	return daily, nil
+}
+
+// GetBurnRate retrieves the average ingestion rate of every workload in a cluster over the window
+// ending at end, in bytes per second. The rate is sampled every burnRateStep with a range query.
+func (m *Mimir) GetBurnRate(cluster string, window time.Duration, end time.Time) (map[string]float64, error) {
+	if cluster == "" {
+		return nil, errors.New("cluster cannot be empty")
+	}
+	if window < burnRateStep {
+		return nil, fmt.Errorf("window must be at least %s", burnRateStep)
+	}
+
+	q := fmt.Sprintf("sum by (%s) (rate(%s[%s]))",
+		m.groupBy(m.names.Labels),
+		m.names.selector(m.names.LogBytes, m.names.Labels, cluster),
+		model.Duration(burnRateStep),
+	)
+
+	result, err := m.queryRange(q, v1.Range{
+		Start: end.Add(-window + burnRateStep),
+		End:   end,
+		Step:  burnRateStep,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query burn rate: %w", err)
+	}
+
+	return averageRates(result, m.names.Labels, m.namespaced)
+}
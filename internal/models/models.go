@@ -1,5 +1,12 @@
 package models
 
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
 // will be replaced by WorkloadIngestedBytes
 type IngestedBytes struct {
 	Cluster  string
@@ -18,6 +25,8 @@ type WorkloadResourceRequest struct {
 	Workload string
 	CPU      Cores
 	Memory   Bytes
+	// Replicas is only fetched for strategies based on the replica count
+	Replicas float64
 }
 
 type OverBudgetWorkload struct {
@@ -25,6 +34,78 @@ type OverBudgetWorkload struct {
 	Workload         string
 	Budget           GigaBytes
 	CurrentIngestion GigaBytes
+	// Pool is the budget pool the workload is sampled for, empty for workloads over their own budget
+	Pool string
+	// Capped is set if the workload is sampled to its fair share of the cluster's ingestion cap
+	Capped bool
+	// ProjectedIngestion is the ingestion forecast for the end of the budget day if the workload is
+	// sampled early because of it, zero otherwise
+	ProjectedIngestion GigaBytes
+}
+
+// BudgetPool is a budget shared by its member workloads
+type BudgetPool struct {
+	Name    string
+	Budget  GigaBytes
+	Members []string
+	// Selector matches additional member workloads by name, nil if not set
+	Selector *regexp.Regexp
+	// Namespaced is set if workload keys are namespace/name, see WorkloadKey
+	Namespaced bool
+}
+
+// Contains reports whether the workload is a member of the pool
+func (p BudgetPool) Contains(workload string) bool {
+	if slices.ContainsFunc(p.Members, func(member string) bool { return MatchesWorkload(member, workload, p.Namespaced) }) {
+		return true
+	}
+	return p.Selector != nil && p.Selector.MatchString(workload)
+}
+
+// WorkloadKey identifies a workload by its name, prefixed with its namespace as namespace/name
+// if workloads are namespace-aware
+func WorkloadKey(namespace, workload string) string {
+	if namespace == "" {
+		return workload
+	}
+	return namespace + "/" + workload
+}
+
+// SplitWorkloadKey returns the namespace and name of a workload key. Keys of workloads which are not
+// namespace-aware are names, which may contain a /, and have an empty namespace.
+func SplitWorkloadKey(key string, namespaced bool) (namespace, workload string) {
+	if !namespaced {
+		return "", key
+	}
+	if namespace, workload, ok := strings.Cut(key, "/"); ok {
+		return namespace, workload
+	}
+	return "", key
+}
+
+// WorkloadNameSeparator joins the values of the labels a workload is identified by, e.g. checkout:server
+// for the app and container labels
+const WorkloadNameSeparator = ":"
+
+// JoinWorkloadName returns the name of a workload identified by the values of several labels
+func JoinWorkloadName(values []string) string {
+	return strings.Join(values, WorkloadNameSeparator)
+}
+
+// SplitWorkloadName returns the values of the n labels a workload name is made of
+func SplitWorkloadName(name string, n int) []string {
+	return strings.SplitN(name, WorkloadNameSeparator, n)
+}
+
+// MatchesWorkload reports whether a workload listed in the configuration, as name or namespace/name,
+// refers to the workload key. A name without a namespace matches the workload in every namespace
+// if workload keys are namespaced.
+func MatchesWorkload(listed, key string, namespaced bool) bool {
+	if listed == key {
+		return true
+	}
+	namespace, workload := SplitWorkloadKey(key, namespaced)
+	return namespace != "" && !strings.Contains(listed, "/") && listed == workload
 }
 
 // Common workload struct - for future use
@@ -45,3 +126,46 @@ type MiliCores float64
 type Cores float64
 type Bytes float64
 type GigaBytes float64
+
+// WorkloadStatus is the enforcement state of a single workload as observed by a run
+type WorkloadStatus struct {
+	Target           string     `json:"target,omitempty"`
+	Cluster          string     `json:"cluster"`
+	Workload         string     `json:"workload"`
+	BudgetOverride   *GigaBytes `json:"budget_override_gb,omitempty"`
+	DynamicBudget    GigaBytes  `json:"dynamic_budget_gb"`
+	CurrentIngestion GigaBytes  `json:"current_ingestion_gb"`
+	OverBudget       bool       `json:"over_budget"`
+	Exempt           bool       `json:"exempt,omitempty"`
+	Pool             string     `json:"pool,omitempty"`
+	// Period is weekly or monthly for workloads whose ingestion and budget cover that period, empty for daily budgets
+	Period string `json:"period,omitempty"`
+	// Capped is set if the workload is sampled to its fair share of the cluster's ingestion cap
+	Capped bool `json:"capped,omitempty"`
+	// ProjectedIngestion is the ingestion forecast for the end of the budget day, nil if not forecast
+	ProjectedIngestion *GigaBytes `json:"projected_ingestion_gb,omitempty"`
+	Sampled            bool       `json:"sampled"`
+	SamplingPercentage float64    `json:"sampling_percentage"`
+}
+
+// EnforcementRun summarises a single execution of a scheduled task
+type EnforcementRun struct {
+	ID         string           `json:"id"`
+	Task       string           `json:"task"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	Success    bool             `json:"success"`
+	Error      string           `json:"error,omitempty"`
+	DryRun     bool             `json:"dry_run"`
+	TimeRange  string           `json:"time_range,omitempty"`
+	Targets    []TargetResult   `json:"targets,omitempty"`
+	Workloads  []WorkloadStatus `json:"workloads,omitempty"`
+}
+
+// TargetResult is the outcome of a run for a single target
+type TargetResult struct {
+	Target  string `json:"target"`
+	Cluster string `json:"cluster"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
@@ -0,0 +1,100 @@
+// Package notify sends a summary of sampling changes made by a run to external channels
+package notify
+
+import (
+	"sort"
+
+	"configurator/internal/models"
+)
+
+// Notifier delivers run summaries
+type Notifier interface {
+	Notify(summary Summary) error
+}
+
+// WorkloadChange describes the sampling of a single workload before and after a run
+type WorkloadChange struct {
+	Workload                   string
+	CurrentIngestion           models.GigaBytes
+	Budget                     models.GigaBytes
+	PreviousSamplingPercentage float64
+	SamplingPercentage         float64
+}
+
+// Summary describes the sampling changes made by a single run
+type Summary struct {
+	RunID        string
+	Task         string
+	Cluster      string
+	DryRun       bool
+	NewlySampled []WorkloadChange
+	StillSampled []WorkloadChange
+	Released     []WorkloadChange
+	// ConfigChanges lists the pipeline stages added and removed, empty if none
+	ConfigChanges string
+}
+
+// BuildSummary compares the sampling rates before and after a run.
+// statuses provide the last measured ingestion and budget of each workload.
+func BuildSummary(previous, current map[string]float64, statuses []models.WorkloadStatus) Summary {
+	byWorkload := make(map[string]models.WorkloadStatus, len(statuses))
+	for _, s := range statuses {
+		byWorkload[s.Workload] = s
+	}
+
+	change := func(workload string) WorkloadChange {
+		c := WorkloadChange{
+			Workload:                   workload,
+			CurrentIngestion:           byWorkload[workload].CurrentIngestion,
+			Budget:                     byWorkload[workload].DynamicBudget,
+			PreviousSamplingPercentage: 100.0,
+			SamplingPercentage:         100.0,
+		}
+		if rate, ok := previous[workload]; ok {
+			c.PreviousSamplingPercentage = rate
+		}
+		if rate, ok := current[workload]; ok {
+			c.SamplingPercentage = rate
+		}
+		return c
+	}
+
+	var summary Summary
+	for workload := range current {
+		if _, ok := previous[workload]; ok {
+			summary.StillSampled = append(summary.StillSampled, change(workload))
+		} else {
+			summary.NewlySampled = append(summary.NewlySampled, change(workload))
+		}
+	}
+	for workload := range previous {
+		if _, ok := current[workload]; !ok {
+			summary.Released = append(summary.Released, change(workload))
+		}
+	}
+
+	sortChanges(summary.NewlySampled)
+	sortChanges(summary.StillSampled)
+	sortChanges(summary.Released)
+
+	return summary
+}
+
+// Changed reports whether the run changed the sampling of any workload or the Promtail config
+func (s Summary) Changed() bool {
+	if len(s.NewlySampled) > 0 || len(s.Released) > 0 || s.ConfigChanges != "" {
+		return true
+	}
+	for _, c := range s.StillSampled {
+		if c.PreviousSamplingPercentage != c.SamplingPercentage {
+			return true
+		}
+	}
+	return false
+}
+
+func sortChanges(changes []WorkloadChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Workload < changes[j].Workload
+	})
+}
@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+	slackTimeout        = 10 * time.Second
+)
+
+// SlackOptions configures the Slack notifier.
+// Either WebhookURL or Token must be set, the webhook is used when both are.
+type SlackOptions struct {
+	WebhookURL string
+	Token      string
+	ProxyURL   string
+	Username   string
+	Channel    string
+}
+
+// Slack posts run summaries to a Slack channel
+type Slack struct {
+	opts   SlackOptions
+	apiURL string
+	client *http.Client
+}
+
+// slackMessage is the payload accepted by both incoming webhooks and chat.postMessage
+type slackMessage struct {
+	Text     string `json:"text"`
+	Username string `json:"username,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+}
+
+// slackAPIResponse is the body returned by chat.postMessage
+type slackAPIResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// NewSlack creates a new Slack notifier
+func NewSlack(opts SlackOptions) (*Slack, error) {
+	if opts.WebhookURL == "" && opts.Token == "" {
+		return nil, errors.New("slack webhook URL or token must be provided")
+	}
+	if opts.WebhookURL == "" && opts.Channel == "" {
+		return nil, errors.New("slack channel must be provided when using a token")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slack proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &Slack{
+		opts:   opts,
+		apiURL: slackPostMessageURL,
+		client: &http.Client{Transport: transport, Timeout: slackTimeout},
+	}, nil
+}
+
+// Notify implements the Notifier interface
+func (s *Slack) Notify(summary Summary) error {
+	payload, err := json.Marshal(slackMessage{
+		Text:     formatSlackText(summary),
+		Username: s.opts.Username,
+		Channel:  s.opts.Channel,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode slack message: %w", err)
+	}
+
+	target := s.opts.WebhookURL
+	if target == "" {
+		target = s.apiURL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if s.opts.WebhookURL == "" {
+		req.Header.Set("Authorization", "Bearer "+s.opts.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post slack message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// chat.postMessage reports errors in the body with a 200 status
+	if s.opts.WebhookURL == "" {
+		var apiResp slackAPIResponse
+		if err := json.Unmarshal(body, &apiResp); err != nil {
+			return fmt.Errorf("failed to decode slack response: %w", err)
+		}
+		if !apiResp.OK {
+			return fmt.Errorf("slack API error: %s", apiResp.Error)
+		}
+	}
+
+	log.Debug().
+		Str("run_id", summary.RunID).
+		Msg("Posted run summary to slack")
+	return nil
+}
+
+// formatSlackText renders a summary as Slack mrkdwn
+func formatSlackText(summary Summary) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "*Log sampling update* for cluster `%s` (%s run `%s`)", summary.Cluster, summary.Task, summary.RunID)
+	if summary.DryRun {
+		b.WriteString(" _[dry-run]_")
+	}
+	b.WriteString("\n")
+
+	writeSection := func(title string, changes []WorkloadChange, line func(WorkloadChange) string) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "*%s (%d)*\n", title, len(changes))
+		for _, c := range changes {
+			fmt.Fprintf(&b, "• `%s`: %s\n", c.Workload, line(c))
+		}
+	}
+
+	writeSection("Newly sampled", summary.NewlySampled, func(c WorkloadChange) string {
+		return fmt.Sprintf("%.2f GB ingested / %.2f GB budget, sampling %.2f%%",
+			float64(c.CurrentIngestion), float64(c.Budget), c.SamplingPercentage)
+	})
+	writeSection("Still sampled", summary.StillSampled, func(c WorkloadChange) string {
+		return fmt.Sprintf("%.2f GB ingested / %.2f GB budget, sampling %.2f%% → %.2f%%",
+			float64(c.CurrentIngestion), float64(c.Budget), c.PreviousSamplingPercentage, c.SamplingPercentage)
+	})
+	writeSection("Released", summary.Released, func(c WorkloadChange) string {
+		return fmt.Sprintf("was sampling %.2f%%, now shipping all logs", c.PreviousSamplingPercentage)
+	})
+
+	if summary.ConfigChanges != "" {
+		fmt.Fprintf(&b, "*Promtail config changes*\n```\n%s```\n", summary.ConfigChanges)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"configurator/internal/models"
+)
+
+func TestBuildSummary(t *testing.T) {
+	previous := map[string]float64{"still": 50.0, "released": 20.0}
+	current := map[string]float64{"still": 40.0, "new": 10.0}
+	statuses := []models.WorkloadStatus{
+		{Workload: "new", CurrentIngestion: 50, DynamicBudget: 5},
+	}
+
+	summary := BuildSummary(previous, current, statuses)
+
+	if len(summary.NewlySampled) != 1 || summary.NewlySampled[0].Workload != "new" {
+		t.Fatalf("expected new to be newly sampled, got %+v", summary.NewlySampled)
+	}
+	if summary.NewlySampled[0].CurrentIngestion != 50 || summary.NewlySampled[0].Budget != 5 {
+		t.Fatalf("expected ingestion and budget of new, got %+v", summary.NewlySampled[0])
+	}
+	if len(summary.StillSampled) != 1 || summary.StillSampled[0].PreviousSamplingPercentage != 50.0 {
+		t.Fatalf("expected still to be still sampled, got %+v", summary.StillSampled)
+	}
+	if len(summary.Released) != 1 || summary.Released[0].Workload != "released" {
+		t.Fatalf("expected released to be released, got %+v", summary.Released)
+	}
+	if !summary.Changed() {
+		t.Fatalf("expected summary to report a change")
+	}
+
+	unchanged := BuildSummary(map[string]float64{"a": 50.0}, map[string]float64{"a": 50.0}, nil)
+	if unchanged.Changed() {
+		t.Fatalf("expected summary without changes, got %+v", unchanged)
+	}
+}
+
+func TestSlackWebhook(t *testing.T) {
+	var got slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	slack, err := NewSlack(SlackOptions{
+		WebhookURL: server.URL,
+		Username:   "configurator",
+		Channel:    "#logs",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = slack.Notify(Summary{
+		RunID:   "run-1",
+		Task:    "ingestion_check",
+		Cluster: "cluster-001",
+		NewlySampled: []WorkloadChange{
+			{Workload: "otel-collector", CurrentIngestion: 60, Budget: 30, PreviousSamplingPercentage: 100, SamplingPercentage: 50},
+		},
+		Released: []WorkloadChange{
+			{Workload: "api", PreviousSamplingPercentage: 25, SamplingPercentage: 100},
+		},
+		ConfigChanges: "scrape_config kubernetes-pods:\n  + {match: {selector: '{workload=\"otel-collector\"}'}}\n",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got.Username != "configurator" || got.Channel != "#logs" {
+		t.Fatalf("expected username and channel to be set, got %+v", got)
+	}
+	for _, want := range []string{
+		"cluster-001",
+		"*Newly sampled (1)*",
+		"`otel-collector`: 60.00 GB ingested / 30.00 GB budget, sampling 50.00%",
+		"*Released (1)*",
+		"`api`: was sampling 25.00%",
+		"*Promtail config changes*\n```\nscrape_config kubernetes-pods:",
+	} {
+		if !strings.Contains(got.Text, want) {
+			t.Errorf("expected message to contain %q, got:\n%s", want, got.Text)
+		}
+	}
+}
+
+func TestSlackWebhookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	slack, _ := NewSlack(SlackOptions{WebhookURL: server.URL})
+	if err := slack.Notify(Summary{RunID: "run-1"}); err == nil {
+		t.Fatalf("expected error for failed webhook")
+	}
+}
+
+func TestSlackToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  bool
+	}{
+		{name: "Message posted", response: `{"ok":true}`, wantErr: false},
+		{name: "API error", response: `{"ok":false,"error":"channel_not_found"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var auth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			slack, err := NewSlack(SlackOptions{Token: "xoxb-test", Channel: "#logs"})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			slack.apiURL = server.URL
+
+			err = slack.Notify(Summary{RunID: "run-1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if auth != "Bearer xoxb-test" {
+				t.Fatalf("expected bearer token, got %q", auth)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package promtail
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigDiff describes the changes between two promtail configurations
+type ConfigDiff struct {
+	ScrapeConfigs []ScrapeConfigDiff
+	// Unified is a textual unified diff of the two configurations
+	Unified string
+}
+
+// ScrapeConfigDiff lists the pipeline stages added to and removed from a scrape config.
+// Each stage is rendered as a single line of YAML flow syntax.
+type ScrapeConfigDiff struct {
+	JobName string
+	Added   []string
+	Removed []string
+}
+
+// Diff compares two promtail configurations given as YAML.
+// Both configurations should be produced by ToYAML so formatting differences are not reported.
+func Diff(before, after string) (*ConfigDiff, error) {
+	beforeConfig, err := New(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse current config: %w", err)
+	}
+	afterConfig, err := New(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new config: %w", err)
+	}
+
+	unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: "current",
+		ToFile:   "new",
+		Context:  3,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create unified diff: %w", err)
+	}
+
+	diff := &ConfigDiff{Unified: unified}
+
+	beforeStages := make(map[string][]string)
+	for _, sc := range beforeConfig.ScrapeConfigs {
+		stages, err := renderStages(sc.PipelineStages)
+		if err != nil {
+			return nil, err
+		}
+		beforeStages[sc.JobName] = stages
+	}
+
+	seen := make(map[string]struct{})
+	for _, sc := range afterConfig.ScrapeConfigs {
+		stages, err := renderStages(sc.PipelineStages)
+		if err != nil {
+			return nil, err
+		}
+		seen[sc.JobName] = struct{}{}
+
+		d := ScrapeConfigDiff{
+			JobName: sc.JobName,
+			Added:   subtractStages(stages, beforeStages[sc.JobName]),
+			Removed: subtractStages(beforeStages[sc.JobName], stages),
+		}
+		if len(d.Added) > 0 || len(d.Removed) > 0 {
+			diff.ScrapeConfigs = append(diff.ScrapeConfigs, d)
+		}
+	}
+
+	// Scrape configs which no longer exist lose all their stages
+	for _, sc := range beforeConfig.ScrapeConfigs {
+		if _, ok := seen[sc.JobName]; !ok && len(beforeStages[sc.JobName]) > 0 {
+			diff.ScrapeConfigs = append(diff.ScrapeConfigs, ScrapeConfigDiff{
+				JobName: sc.JobName,
+				Removed: beforeStages[sc.JobName],
+			})
+		}
+	}
+
+	return diff, nil
+}
+
+// IsEmpty reports whether both configurations are identical
+func (d *ConfigDiff) IsEmpty() bool {
+	return d.Unified == "" && len(d.ScrapeConfigs) == 0
+}
+
+// Summary renders the stage level changes, one line per added or removed stage
+func (d *ConfigDiff) Summary() string {
+	if len(d.ScrapeConfigs) == 0 {
+		return "no pipeline stage changes"
+	}
+
+	var b strings.Builder
+	for _, sc := range d.ScrapeConfigs {
+		fmt.Fprintf(&b, "scrape_config %s:\n", sc.JobName)
+		for _, stage := range sc.Removed {
+			fmt.Fprintf(&b, "  - %s\n", stage)
+		}
+		for _, stage := range sc.Added {
+			fmt.Fprintf(&b, "  + %s\n", stage)
+		}
+	}
+	return b.String()
+}
+
+// renderStages renders every pipeline stage as a single line of YAML flow syntax
+func renderStages(stages []PipelineStage) ([]string, error) {
+	rendered := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		line, err := renderStage(stage)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, line)
+	}
+	return rendered, nil
+}
+
+// renderStage renders a pipeline stage as a single line of YAML flow syntax
+func renderStage(stage PipelineStage) (string, error) {
+	type flowStage struct {
+		Stage PipelineStage `yaml:"stage,flow"`
+	}
+
+	out, err := yaml.Marshal(flowStage{Stage: stage})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal pipeline stage: %w", err)
+	}
+
+	line := strings.TrimSpace(string(out))
+	line = strings.TrimPrefix(line, "stage: ")
+	// Long flow values may still be folded onto multiple lines
+	return strings.Join(strings.Fields(line), " "), nil
+}
+
+// subtractStages returns the stages of a that are not in b, respecting duplicates
+func subtractStages(a, b []string) []string {
+	remaining := make(map[string]int, len(b))
+	for _, s := range b {
+		remaining[s]++
+	}
+
+	var result []string
+	for _, s := range a {
+		if remaining[s] > 0 {
+			remaining[s]--
+			continue
+		}
+		result = append(result, s)
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package promtail
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	format := "{workload=\"%s\"} |= \"\""
+
+	p, err := New(sampleConfig)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	before, _ := p.ToYAML()
+
+	p.AddSamplingStages(map[string]float64{"test-workload": 50.0}, Selector{Format: format})
+	p.removeDropStage("somesource", "somevalue")
+	after, _ := p.ToYAML()
+
+	diff, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []ScrapeConfigDiff{
+		{
+			JobName: "kubernetes-pods",
+			Added:   []string{`{match: {pipeline_name: automated_sampling, selector: '{workload="test-workload"} |= ""', stages: [{sampling: {rate: 0.5}}]}}`},
+			Removed: []string{`{drop: {drop_counter_reason: too_many_logs, separator: ;, source: somesource, value: somevalue}}`},
+		},
+	}
+	if !reflect.DeepEqual(diff.ScrapeConfigs, want) {
+		t.Errorf("Diff() ScrapeConfigs = %+v, want %+v", diff.ScrapeConfigs, want)
+	}
+
+	if !strings.Contains(diff.Unified, "+      pipeline_name: automated_sampling") {
+		t.Errorf("expected unified diff to contain the added sampling stage, got:\n%s", diff.Unified)
+	}
+	if !strings.Contains(diff.Unified, "-      source: somesource") {
+		t.Errorf("expected unified diff to contain the removed drop stage, got:\n%s", diff.Unified)
+	}
+
+	unchanged, err := Diff(before, before)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !unchanged.IsEmpty() {
+		t.Errorf("expected empty diff, got %+v", unchanged)
+	}
+}
@@ -3,16 +3,21 @@ package promtail
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/rs/zerolog/log"
+
+	"configurator/internal/models"
 )
 
-func (pCfg *PromtailConfig) DropLogs(newWorkloads []string) (isConfigUpdated bool) {
+func (pCfg *PromtailConfig) DropLogs(selector Selector, newWorkloads []string) (isConfigUpdated bool) {
 
 	isConfigUpdated = false
 
 	// Check if the workloads are already dropped
-	alreadyDroppedWorkloads, err := pCfg.getDroppedWorkloads()
+	alreadyDroppedWorkloads, err := pCfg.getDroppedWorkloads(selector)
 	if err != nil {
 		log.Error().
 			Caller().
@@ -43,7 +48,8 @@ func (pCfg *PromtailConfig) DropLogs(newWorkloads []string) (isConfigUpdated boo
 			Str("workload", workload).
 			Msg("dropping logs")
 
-		pCfg.appendDropStage("workload", workload, "too_many_logs")
+		source, value := dropSource(selector, workload)
+		pCfg.appendDropStage(source, value, "too_many_logs")
 
 		isConfigUpdated = true
 	}
@@ -54,12 +60,28 @@ func (pCfg *PromtailConfig) DropLogs(newWorkloads []string) (isConfigUpdated boo
 // Handle missing values
 func parseDropStage(m map[interface{}]interface{}) (*DropStage, error) {
 
-	var source, value, dropCounterReason string
+	var source interface{}
+	var value, separator, dropCounterReason string
 
-	if s, ok := m["source"].(string); ok {
+	switch s := m["source"].(type) {
+	case string:
 		source = s
-	} else {
-		return nil, errors.New("can't parse drop stage, source field is missing or not a string")
+	case []interface{}:
+		names := make([]string, 0, len(s))
+		for _, name := range s {
+			n, ok := name.(string)
+			if !ok {
+				return nil, errors.New("can't parse drop stage, source list must only contain strings")
+			}
+			names = append(names, n)
+		}
+		source = names
+	default:
+		return nil, errors.New("can't parse drop stage, source field is missing or not a string or list")
+	}
+
+	if sep, ok := m["separator"].(string); ok {
+		separator = sep
 	}
 
 	if dcr, ok := m["drop_counter_reason"].(string); ok {
@@ -77,9 +99,9 @@ func parseDropStage(m map[interface{}]interface{}) (*DropStage, error) {
 	// return newDropStage(source, value, dropCounterReason)
 	return &DropStage{
 		Source:            source,
+		Separator:         separator,
 		DropCounterReason: dropCounterReason,
 		Value:             value,
-		// Separator:         separator,
 	}, nil
 }
 
@@ -129,8 +151,8 @@ func (pCfg *PromtailConfig) extractDropStages() ([]*DropStage, error) {
 	return dropStages, nil
 }
 
-// Get already dropped workloads
-func (pCfg *PromtailConfig) getDroppedWorkloads() ([]string, error) {
+// Get already dropped workloads, identified by the labels of the selector
+func (pCfg *PromtailConfig) getDroppedWorkloads(selector Selector) ([]string, error) {
 	var droppedWorkloads []string
 	dropStages, err := pCfg.extractDropStages()
 	if err != nil {
@@ -140,18 +162,21 @@ func (pCfg *PromtailConfig) getDroppedWorkloads() ([]string, error) {
 		return nil, err
 	}
 	for _, dropStage := range dropStages {
-		if dropStage.Source == "workload" && dropStage.DropCounterReason == "too_many_logs" {
-			droppedWorkloads = append(droppedWorkloads, dropStage.Value)
+		if dropStage.DropCounterReason != "too_many_logs" {
+			continue
+		}
+		if workload, ok := droppedWorkload(selector, dropStage); ok {
+			droppedWorkloads = append(droppedWorkloads, workload)
 		}
 	}
 	return droppedWorkloads, nil
 }
 
 // AddDropStage adds a new drop stage to the pipeline stages.
-func (pCfg *PromtailConfig) appendDropStage(source string, value string, reason string) {
+func (pCfg *PromtailConfig) appendDropStage(source interface{}, value string, reason string) {
 
 	log.Trace().
-		Str("source", source).
+		Interface("source", source).
 		Str("value", value).
 		Str("reason", reason).
 		Msg("adding DropStage")
@@ -160,7 +185,9 @@ func (pCfg *PromtailConfig) appendDropStage(source string, value string, reason
 		Source:            source,
 		DropCounterReason: reason,
 		Value:             value,
-		// Separator:         ";",
+	}
+	if _, ok := source.([]string); ok {
+		newDropStageMap.Separator = models.WorkloadNameSeparator
 	}
 
 	for i, scrapeConfig := range pCfg.ScrapeConfigs {
@@ -171,8 +198,8 @@ func (pCfg *PromtailConfig) appendDropStage(source string, value string, reason
 }
 
 // removeDropStage remove a drop stage to the pipeline stages by source and value.
-func (p *PromtailConfig) removeDropStage(source string, value string) {
-	log.Info().Msg(fmt.Sprintf("Removing DropStage from promtail config: source=%s, value=%s\n", source, value))
+func (p *PromtailConfig) removeDropStage(source interface{}, value string) {
+	log.Info().Msg(fmt.Sprintf("Removing DropStage from promtail config: source=%v, value=%s\n", source, value))
 
 	for i, scrapeConfig := range p.ScrapeConfigs {
 
@@ -190,13 +217,13 @@ func (p *PromtailConfig) removeDropStage(source string, value string) {
 					if err != nil {
 						log.Error().Caller().Msg("Failed to parse drop stage")
 					}
-					if convertedDropStage.Source != source || convertedDropStage.Value != value {
+					if !reflect.DeepEqual(convertedDropStage.Source, source) || convertedDropStage.Value != value {
 						newPipelineStages = append(newPipelineStages, stage)
 					}
 
 				case *DropStage:
 
-					if ds.Source != source || ds.Value != value {
+					if !reflect.DeepEqual(ds.Source, source) || ds.Value != value {
 						newPipelineStages = append(newPipelineStages, stage)
 					}
 
@@ -213,14 +240,14 @@ func (p *PromtailConfig) removeDropStage(source string, value string) {
 	}
 }
 
-func (pCfg *PromtailConfig) AllowLogs(workloads []string) {
+func (pCfg *PromtailConfig) AllowLogs(selector Selector, workloads []string) {
 
 	log.Info().
 		Str("workloads", fmt.Sprintf("%+v", workloads)).
 		Msg("allowing logs for workloads")
 
 	for _, workload := range workloads {
-		pCfg.removeDropStage("workload", workload)
+		pCfg.removeDropStage(dropSource(selector, workload))
 	}
 
 }
@@ -279,3 +306,35 @@ func (p *PromtailConfig) AllowAllLogs() error {
 	return nil
 
 }
+
+// dropSource returns the source and value of the drop stage of a workload key. Workloads identified by
+// a single label use it as source, otherwise the source lists the namespace and the labels.
+func dropSource(selector Selector, workload string) (source interface{}, value string) {
+	namespace, name := models.SplitWorkloadKey(workload, selector.Namespaced)
+	labels := selector.labels()
+	if namespace == "" && len(labels) == 1 {
+		return labels[0], name
+	}
+	if namespace == "" {
+		return slices.Clone(labels), name
+	}
+	return append([]string{"namespace"}, labels...), namespace + models.WorkloadNameSeparator + name
+}
+
+// droppedWorkload returns the workload key of a drop stage added for a workload of the selector
+func droppedWorkload(selector Selector, dropStage *DropStage) (string, bool) {
+	labels := selector.labels()
+	switch source := dropStage.Source.(type) {
+	case string:
+		return dropStage.Value, len(labels) == 1 && source == labels[0]
+	case []string:
+		if slices.Equal(source, labels) {
+			return dropStage.Value, true
+		}
+		if len(source) == len(labels)+1 && source[0] == "namespace" && slices.Equal(source[1:], labels) {
+			namespace, name, _ := strings.Cut(dropStage.Value, models.WorkloadNameSeparator)
+			return models.WorkloadKey(namespace, name), true
+		}
+	}
+	return "", false
+}
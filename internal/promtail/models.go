@@ -22,9 +22,11 @@ type ScrapeConfig struct {
 type PipelineStage map[string]interface{}
 
 type DropStage struct {
-	Source            string `yaml:"source"`
-	DropCounterReason string `yaml:"drop_counter_reason"`
-	Value             string `yaml:"value"`
+	// Source is the name of the extracted value, or a list of names whose values are joined by Separator
+	Source            interface{} `yaml:"source"`
+	Separator         string      `yaml:"separator,omitempty"`
+	DropCounterReason string      `yaml:"drop_counter_reason"`
+	Value             string      `yaml:"value"`
 }
 
 // MatchStage represents a sampling stage
@@ -3,14 +3,17 @@ package promtail
 import (
 	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/rs/zerolog/log"
 )
 
 var errNotASamplingStage = errors.New("not a sampling stage")
 
-func newSamplingStage(format string, workload string, samplingPercentage float64) (*PipelineStage, error) {
+// errUnknownSelector is returned for sampling stages whose selector does not match the format,
+// e.g. stages written before the selector format was changed
+var errUnknownSelector = errors.New("selector does not match the selector format")
+
+func newSamplingStage(selector Selector, workload string, samplingPercentage float64) (*PipelineStage, error) {
 	// Check if the sampling percentage is valid
 	if samplingPercentage < 0 || samplingPercentage > 100 {
 		return nil, NewOutOfRangePercentageError(samplingPercentage)
@@ -23,7 +26,7 @@ func newSamplingStage(format string, workload string, samplingPercentage float64
 	return &PipelineStage{
 		"match": &MatchStage{
 			PipelineName: "automated_sampling",
-			Selector:     fmt.Sprintf(format, workload),
+			Selector:     formatSelector(selector, workload),
 			Stages: []map[string]Sampling{
 				{
 					"sampling": {
@@ -39,16 +42,14 @@ func newSamplingStage(format string, workload string, samplingPercentage float64
 // Parses a sampling stage from a map to a Sampling struct.
 // It returns the workload and sampling percentage.
 // If the stage is not a match stage or if the sampling stage is not found, it returns an error.
-func parseSamplingStage(s *PipelineStage, format string) (workload string, samplingPercentage float64, err error) {
+func parseSamplingStage(s *PipelineStage, format Selector) (workload string, samplingPercentage float64, err error) {
 
 	// Check if the stage is a match stage
 
-	// extract the prefix and suffix from the format string
-	idx := strings.Index(format, "%s")
-	if idx == -1 {
-		return "", 0, fmt.Errorf("invalid format string: %s", format)
+	pattern, err := selectorPattern(format)
+	if err != nil {
+		return "", 0, err
 	}
-	prefix, suffix := format[:idx], format[idx+2:]
 
 	if matchStage, ok := (*s)["match"]; ok {
 
@@ -56,11 +57,10 @@ func parseSamplingStage(s *PipelineStage, format string) (workload string, sampl
 		case map[interface{}]interface{}:
 
 			if pipelineName, ok := m["pipeline_name"].(string); ok && pipelineName == "automated_sampling" {
-				if selector, ok := m["selector"].(string); ok && len(selector) > len(prefix) {
+				if selector, ok := m["selector"].(string); ok {
 
 					// Extract workload from selector
-					if startIdx, endIdx := len(prefix), len(selector)-len(suffix); endIdx > startIdx {
-						workload = selector[startIdx:endIdx]
+					if workload, ok = parseSelector(pattern, format, selector); ok {
 
 						// Process stages to find sampling rate
 						if stages, ok := m["stages"].([]interface{}); ok && len(stages) >= 1 {
@@ -78,7 +78,7 @@ func parseSamplingStage(s *PipelineStage, format string) (workload string, sampl
 						}
 						return "", 0, fmt.Errorf("stages missing or empty")
 					}
-					return "", 0, fmt.Errorf("failed to extract workload from selector: %v", selector)
+					return "", 0, fmt.Errorf("failed to extract workload from selector %v: %w", selector, errUnknownSelector)
 				}
 			}
 		}
@@ -86,7 +86,7 @@ func parseSamplingStage(s *PipelineStage, format string) (workload string, sampl
 	return "", 0, errNotASamplingStage
 }
 
-func (p *PromtailConfig) AddSamplingStages(newWorkloads map[string]float64, format string) (isConfigUpdated bool) {
+func (p *PromtailConfig) AddSamplingStages(newWorkloads map[string]float64, selector Selector) (isConfigUpdated bool) {
 
 	// check if newWorkloads is empty
 	if len(newWorkloads) == 0 {
@@ -101,7 +101,7 @@ func (p *PromtailConfig) AddSamplingStages(newWorkloads map[string]float64, form
 
 	for i := range p.ScrapeConfigs {
 		for w, s := range newWorkloads {
-			s, err := newSamplingStage(format, w, s)
+			s, err := newSamplingStage(selector, w, s)
 
 			if err != nil {
 				log.Error().Err(err).Msg(fmt.Sprintf("failed to create sampling stage: %v", err))
@@ -118,7 +118,7 @@ func (p *PromtailConfig) AddSamplingStages(newWorkloads map[string]float64, form
 // It iterates through all scrape configurations and their pipeline stages, identifying sampling stages
 // and excluding them from the new set of pipeline stages.
 
-func (p *PromtailConfig) RemoveAllSamplingStages(format string) (isConfigUpdated bool, err error) {
+func (p *PromtailConfig) RemoveAllSamplingStages(selector Selector) (isConfigUpdated bool, err error) {
 
 	log.Debug().Msg("removing all existing sampling stages")
 
@@ -130,10 +130,14 @@ func (p *PromtailConfig) RemoveAllSamplingStages(format string) (isConfigUpdated
 
 		for _, stage := range scrapeConfig.PipelineStages {
 
-			_, _, err := parseSamplingStage(&stage, format)
+			_, _, err := parseSamplingStage(&stage, selector)
 
 			if err == nil { // This is a sampling stage, so we skip it
 				continue
+			} else if errors.Is(err, errUnknownSelector) {
+				log.Warn().Err(err).Msg("removing sampling stage with a selector of another format")
+				isConfigUpdated = true
+				continue
 			} else if errors.Is(err, errNotASamplingStage) {
 				newPipelineStages = append(newPipelineStages, stage) // This is not a sampling stage, so we keep it
 				isConfigUpdated = true
@@ -149,18 +153,20 @@ func (p *PromtailConfig) RemoveAllSamplingStages(format string) (isConfigUpdated
 
 // GetSampledWorkloads returns a map of workload names to their sampling percentages
 // Useful for reporting which workloads are being sampled
-func (p *PromtailConfig) GetSampledWorkloads(format string) (map[string]float64, error) {
+func (p *PromtailConfig) GetSampledWorkloads(selector Selector) (map[string]float64, error) {
 
 	sampledWorkloads := make(map[string]float64)
 
 	for _, scrapeConfig := range p.ScrapeConfigs {
 		for _, stage := range scrapeConfig.PipelineStages {
 
-			workload, samplingPercentage, err := parseSamplingStage(&stage, format)
+			workload, samplingPercentage, err := parseSamplingStage(&stage, selector)
 
 			if err == nil {
 				sampledWorkloads[workload] = samplingPercentage
 
+			} else if errors.Is(err, errUnknownSelector) {
+				log.Warn().Err(err).Msg("ignoring sampling stage with a selector of another format")
 			} else if !errors.Is(err, errNotASamplingStage) {
 				log.Error().Err(err).Msg(fmt.Sprintf("failed to parse sampling stage: %+v", stage))
 				return nil, err
@@ -122,7 +122,7 @@ func TestNewSamplingStage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotStage, err := newSamplingStage(tt.format, tt.workload, tt.samplingPercentage)
+			gotStage, err := newSamplingStage(Selector{Format: tt.format}, tt.workload, tt.samplingPercentage)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("newSamplingStage() error = %v, wantErr %v", err, tt.wantErr)
@@ -319,7 +319,7 @@ func TestParseSamplingStage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotWorkload, gotSamplingPercent, err := parseSamplingStage(&tt.stage, tt.format)
+			gotWorkload, gotSamplingPercent, err := parseSamplingStage(&tt.stage, Selector{Format: tt.format})
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseSamplingStage() error = %v, wantErr %v", err, tt.wantErr)
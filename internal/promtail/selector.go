@@ -0,0 +1,148 @@
+package promtail
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"configurator/internal/models"
+)
+
+// defaultWorkloadLabel identifies workloads if a selector has no labels
+const defaultWorkloadLabel = "workload"
+
+// Placeholders of a selector format for the namespace and name of a workload, ${<label>} is replaced
+// by the value of each workload label. Formats without placeholders use %s for the workload key.
+const (
+	namespacePlaceholder = "${namespace}"
+	workloadPlaceholder  = "${workload}"
+)
+
+// placeholderPattern matches the placeholders of a selector format
+var placeholderPattern = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// Selector is the format of the selectors of sampling stages and the labels workloads are identified by
+type Selector struct {
+	Format string
+	// Labels are the labels whose values, joined by models.WorkloadNameSeparator, name a workload, workload if empty
+	Labels []string
+	// Namespaced is set if workload keys are namespace/name, see models.WorkloadKey
+	Namespaced bool
+}
+
+// labels returns the labels workloads are identified by
+func (s Selector) labels() []string {
+	if len(s.Labels) == 0 {
+		return []string{defaultWorkloadLabel}
+	}
+	return s.Labels
+}
+
+// usesPlaceholders reports whether the format uses placeholders instead of %s
+func usesPlaceholders(format string) bool {
+	return placeholderPattern.MatchString(format)
+}
+
+// formatSelector returns the selector of a workload key
+func formatSelector(s Selector, workload string) string {
+	if !usesPlaceholders(s.Format) {
+		return fmt.Sprintf(s.Format, workload)
+	}
+	namespace, name := models.SplitWorkloadKey(workload, s.Namespaced)
+	labels := s.labels()
+	values := models.SplitWorkloadName(name, len(labels))
+
+	// Label placeholders come first so ${workload} is the value of a workload label if there is one
+	replacements := make([]string, 0, 2*len(labels)+4)
+	for i, label := range labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		replacements = append(replacements, "${"+label+"}", value)
+	}
+	replacements = append(replacements, namespacePlaceholder, namespace, workloadPlaceholder, name)
+	return strings.NewReplacer(replacements...).Replace(s.Format)
+}
+
+// selectorPattern returns a regular expression matching the selectors of the format,
+// with the namespace, the workload name and the label values as named groups
+func selectorPattern(s Selector) (*regexp.Regexp, error) {
+	if !usesPlaceholders(s.Format) {
+		prefix, suffix, ok := strings.Cut(s.Format, "%s")
+		if !ok {
+			return nil, fmt.Errorf("invalid format string: %s", s.Format)
+		}
+		return regexp.Compile("^" + regexp.QuoteMeta(prefix) + "(?P<workload>.+)" + regexp.QuoteMeta(suffix) + "$")
+	}
+	if err := s.validatePlaceholders(); err != nil {
+		return nil, err
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	seen := make(map[string]bool)
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(s.Format, -1) {
+		pattern.WriteString(regexp.QuoteMeta(s.Format[last:m[0]]))
+		name := s.Format[m[2]:m[3]]
+		if seen[name] {
+			pattern.WriteString("(?:.+?)")
+		} else {
+			// Group names are prefixed so label names cannot clash with namespace and workload
+			fmt.Fprintf(&pattern, "(?P<p_%s>.+?)", name)
+			seen[name] = true
+		}
+		last = m[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(s.Format[last:]))
+	pattern.WriteString("$")
+	return regexp.Compile(pattern.String())
+}
+
+// validatePlaceholders checks that the placeholders of the format identify a workload,
+// by ${workload} or by the placeholders of all its labels
+func (s Selector) validatePlaceholders() error {
+	if strings.Contains(s.Format, workloadPlaceholder) {
+		return nil
+	}
+	for _, label := range s.labels() {
+		if !strings.Contains(s.Format, "${"+label+"}") {
+			return fmt.Errorf("invalid format string, %s or ${%s} is missing: %s", workloadPlaceholder, label, s.Format)
+		}
+	}
+	return nil
+}
+
+// parseSelector returns the workload key of a selector matching the pattern of the format
+func parseSelector(pattern *regexp.Regexp, s Selector, selector string) (string, bool) {
+	match := pattern.FindStringSubmatch(selector)
+	if match == nil {
+		return "", false
+	}
+	group := func(name string) (string, bool) {
+		if i := pattern.SubexpIndex("p_" + name); i >= 0 {
+			return match[i], true
+		}
+		return "", false
+	}
+	if !usesPlaceholders(s.Format) {
+		return match[pattern.SubexpIndex("workload")], true
+	}
+
+	namespace, _ := group("namespace")
+	labels := s.labels()
+	values := make([]string, 0, len(labels))
+	for _, label := range labels {
+		value, ok := group(label)
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+	if len(values) == len(labels) {
+		return models.WorkloadKey(namespace, models.JoinWorkloadName(values)), true
+	}
+	workload, _ := group("workload")
+	return models.WorkloadKey(namespace, workload), true
+}
@@ -0,0 +1,149 @@
+package promtail
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		selector     Selector
+		workload     string
+		wantSelector string
+	}{
+		{
+			name:         "Workload format",
+			selector:     Selector{Format: `{workload="%s"} |= ""`},
+			workload:     "checkout",
+			wantSelector: `{workload="checkout"} |= ""`,
+		},
+		{
+			name:         "Namespaced format",
+			selector:     Selector{Format: `{namespace="${namespace}", workload="${workload}"} |= ""`, Namespaced: true},
+			workload:     "shop/checkout",
+			wantSelector: `{namespace="shop", workload="checkout"} |= ""`,
+		},
+		{
+			name:         "Placeholders in another order",
+			selector:     Selector{Format: `{app="${workload}", ns="${namespace}"}`, Namespaced: true},
+			workload:     "shop/checkout",
+			wantSelector: `{app="checkout", ns="shop"}`,
+		},
+		{
+			name:         "Workload placeholder only",
+			selector:     Selector{Format: `{workload="${workload}"}`},
+			workload:     "checkout",
+			wantSelector: `{workload="checkout"}`,
+		},
+		{
+			name:         "Workload name with a slash",
+			selector:     Selector{Format: `{workload="${workload}"}`},
+			workload:     "team/checkout",
+			wantSelector: `{workload="team/checkout"}`,
+		},
+		{
+			name:         "Label placeholder",
+			selector:     Selector{Format: `{app="${app}"} |= ""`, Labels: []string{"app"}},
+			workload:     "checkout",
+			wantSelector: `{app="checkout"} |= ""`,
+		},
+		{
+			name:         "Combination of labels",
+			selector:     Selector{Format: `{namespace="${namespace}", container="${container}", app="${app}"}`, Labels: []string{"app", "container"}, Namespaced: true},
+			workload:     "shop/checkout:server",
+			wantSelector: `{namespace="shop", container="server", app="checkout"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := formatSelector(tt.selector, tt.workload)
+			if selector != tt.wantSelector {
+				t.Fatalf("expected selector %s, got %s", tt.wantSelector, selector)
+			}
+			pattern, err := selectorPattern(tt.selector)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			workload, ok := parseSelector(pattern, tt.selector, selector)
+			if !ok || workload != tt.workload {
+				t.Errorf("expected workload %s, got %s (matched %v)", tt.workload, workload, ok)
+			}
+		})
+	}
+
+	namespaced := Selector{Format: `{namespace="${namespace}", workload="${workload}"} |= ""`, Namespaced: true}
+	pattern, err := selectorPattern(namespaced)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := parseSelector(pattern, namespaced, `{workload="checkout"} |= ""`); ok {
+		t.Errorf("expected a selector of another format not to match")
+	}
+	if _, err := selectorPattern(Selector{Format: `{namespace="${namespace}"}`}); err == nil {
+		t.Errorf("expected an error for a format without ${workload}")
+	}
+	if _, err := selectorPattern(Selector{Format: `{app="${app}"}`, Labels: []string{"app", "container"}}); err == nil {
+		t.Errorf("expected an error for a format without ${container}")
+	}
+}
+
+func TestDropLogsLabels(t *testing.T) {
+	p, err := New(sampleConfig)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	selector := Selector{Labels: []string{"app", "container"}, Namespaced: true}
+
+	if !p.DropLogs(selector, []string{"checkout:server", "shop/search:api"}) {
+		t.Fatalf("expected the config to be updated")
+	}
+	dropped, err := p.getDroppedWorkloads(selector)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := []string{"checkout:server", "shop/search:api"}; !reflect.DeepEqual(dropped[len(dropped)-2:], want) {
+		t.Errorf("expected dropped workloads %v, got %v", want, dropped)
+	}
+
+	// The drop stages survive a round trip through YAML
+	yamlStr, err := p.ToYAML()
+	if err != nil {
+		t.Fatalf("Failed to convert to YAML: %v", err)
+	}
+	if p, err = New(yamlStr); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if p.DropLogs(selector, []string{"checkout:server"}) {
+		t.Errorf("expected an already dropped workload not to be dropped again")
+	}
+
+	p.AllowLogs(selector, []string{"checkout:server"})
+	if dropped, _ = p.getDroppedWorkloads(selector); len(dropped) != 1 || dropped[0] != "shop/search:api" {
+		t.Errorf("expected only shop/search:api to stay dropped, got %v", dropped)
+	}
+}
+
+func TestDropSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		selector   Selector
+		workload   string
+		wantSource interface{}
+		wantValue  string
+	}{
+		{name: "Workload label", selector: Selector{}, workload: "checkout", wantSource: "workload", wantValue: "checkout"},
+		{name: "Workload name with a slash", selector: Selector{}, workload: "team/checkout", wantSource: "workload", wantValue: "team/checkout"},
+		{name: "Namespaced", selector: Selector{Namespaced: true}, workload: "shop/checkout", wantSource: []string{"namespace", "workload"}, wantValue: "shop:checkout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, value := dropSource(tt.selector, tt.workload)
+			if !reflect.DeepEqual(source, tt.wantSource) || value != tt.wantValue {
+				t.Errorf("expected %v=%s, got %v=%s", tt.wantSource, tt.wantValue, source, value)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"sort"
+
 	"configurator/internal/metrics"
 	"configurator/internal/models"
 
@@ -28,13 +30,26 @@ func FindAbusers(currIngestion map[string]int, workloadBudget map[string]int) []
 	return overBudgetWorkloads
 }
 
-func FindAbusersV2(ingestedBytes []models.WorkloadIngestedBytes, workloadBudget map[string]models.GigaBytes) (overBudgetWorkloads []models.OverBudgetWorkload) {
+// poolContributorShare is the share of an over-budget pool's ingestion that the sampled top contributors cover
+const poolContributorShare = 0.8
+
+// FindAbusersV2 returns the workloads exceeding their budget.
+// Members of a pool are not compared with their own budget. If the total ingestion of a pool exceeds
+// the pool budget, its top contributors are returned with the share of the pool budget left for them.
+func FindAbusersV2(ingestedBytes []models.WorkloadIngestedBytes, workloadBudget map[string]models.GigaBytes, pools []models.BudgetPool) (overBudgetWorkloads []models.OverBudgetWorkload) {
 
 	log.Trace().
 		Msg("finding abusers...")
 
+	poolMembers := make(map[string][]models.WorkloadIngestedBytes, len(pools))
+
 	for i, w := range ingestedBytes {
 
+		if pool, ok := PoolOf(pools, w.Workload); ok {
+			poolMembers[pool.Name] = append(poolMembers[pool.Name], w)
+			continue
+		}
+
 		b := workloadBudget[w.Workload]
 
 		if b == 0 {
@@ -49,6 +64,87 @@ func FindAbusersV2(ingestedBytes []models.WorkloadIngestedBytes, workloadBudget
 			})
 		}
 	}
+
+	for _, pool := range pools {
+		overBudgetWorkloads = append(overBudgetWorkloads, findPoolAbusers(pool, poolMembers[pool.Name])...)
+	}
+	return overBudgetWorkloads
+}
+
+// PoolOf returns the first pool the workload is a member of
+func PoolOf(pools []models.BudgetPool, workload string) (models.BudgetPool, bool) {
+	for _, pool := range pools {
+		if pool.Contains(workload) {
+			return pool, true
+		}
+	}
+	return models.BudgetPool{}, false
+}
+
+// findPoolAbusers returns the top contributors of a pool exceeding its budget. The members covering
+// poolContributorShare of the pool's ingestion are sampled at the same rate, so that together with the
+// remaining members the pool ingests its budget.
+func findPoolAbusers(pool models.BudgetPool, members []models.WorkloadIngestedBytes) []models.OverBudgetWorkload {
+	var total models.GigaBytes
+	for _, m := range members {
+		total += models.GigaBytes(m.Value / 1000000000.0)
+	}
+
+	cluster := ""
+	if len(members) > 0 {
+		cluster = members[0].Cluster
+	}
+	metrics.RecordPoolMetrics(pool.Name, cluster, float64(total), float64(pool.Budget))
+
+	log.Info().Str("pool", pool.Name).
+		Float64("budget", float64(pool.Budget)).
+		Float64("ingestion", float64(total)).
+		Int("members", len(members)).
+		Msg("pool budget utilization")
+
+	if pool.Budget <= 0 || total <= pool.Budget {
+		return nil
+	}
+
+	sorted := append([]models.WorkloadIngestedBytes(nil), members...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Value > sorted[j].Value
+	})
+
+	// Pick the top contributors until they cover the share and the other members fit in the budget
+	var selected models.GigaBytes
+	count := 0
+	for _, m := range sorted {
+		if m.Value <= 0 {
+			break
+		}
+		selected += models.GigaBytes(m.Value / 1000000000.0)
+		count++
+		if selected >= total*poolContributorShare && total-selected < pool.Budget {
+			break
+		}
+	}
+
+	// The budget left for the top contributors once the other members are accounted for
+	ratio := float64(pool.Budget-(total-selected)) / float64(selected)
+
+	overBudgetWorkloads := make([]models.OverBudgetWorkload, 0, count)
+	for _, m := range sorted[:count] {
+		ingestion := models.GigaBytes(m.Value / 1000000000.0)
+		overBudgetWorkloads = append(overBudgetWorkloads, models.OverBudgetWorkload{
+			Cluster:          m.Cluster,
+			Workload:         m.Workload,
+			Budget:           models.GigaBytes(ratio) * ingestion,
+			CurrentIngestion: ingestion,
+			Pool:             pool.Name,
+		})
+	}
+
+	log.Info().Str("pool", pool.Name).
+		Int("sampled_members", count).
+		Float64("share_of_ingestion", ratio).
+		Msg("pool is over budget, sampling top contributors")
+
 	return overBudgetWorkloads
 }
 
@@ -70,6 +166,10 @@ func CalculateSamplingRates(overBudgetWorkloads []models.OverBudgetWorkload) map
 		// var samplingPercentage float64
 
 		samplingPercentage := max(minimum, min(maximum, (float64(w.Budget)/float64(w.CurrentIngestion)*100.0)))
+		if w.ProjectedIngestion > w.CurrentIngestion {
+			// Keep the share of the projected ingestion for the rest of the day that fits the remaining budget
+			samplingPercentage = max(minimum, min(maximum, float64(w.Budget-w.CurrentIngestion)/float64(w.ProjectedIngestion-w.CurrentIngestion)*100.0))
+		}
 
 		samplingRates[w.Workload] = samplingPercentage
 
@@ -93,3 +193,173 @@ func CalculateSamplingRates(overBudgetWorkloads []models.OverBudgetWorkload) map
 
 	return samplingRates
 }
+
+// FairShare divides capacity between workloads by weighted max-min fairness. Workloads demanding less than
+// their share, capacity split by weight, get their demand, and the capacity they leave is shared again by the
+// others, so only the largest consumers relative to their weight get less than they demand.
+// Workloads without a positive weight have a weight of 1.
+func FairShare(demands map[string]models.GigaBytes, weights map[string]float64, capacity models.GigaBytes) map[string]models.GigaBytes {
+	weightOf := func(workload string) float64 {
+		if w := weights[workload]; w > 0 {
+			return w
+		}
+		return 1
+	}
+
+	workloads := make([]string, 0, len(demands))
+	var totalWeight float64
+	for workload := range demands {
+		workloads = append(workloads, workload)
+		totalWeight += weightOf(workload)
+	}
+	// Fill the workloads with the smallest demand per weight first
+	sort.Slice(workloads, func(i, j int) bool {
+		a := float64(demands[workloads[i]]) / weightOf(workloads[i])
+		b := float64(demands[workloads[j]]) / weightOf(workloads[j])
+		if a != b {
+			return a < b
+		}
+		return workloads[i] < workloads[j]
+	})
+
+	allocations := make(map[string]models.GigaBytes, len(demands))
+	remaining := max(capacity, 0)
+	for _, workload := range workloads {
+		weight := weightOf(workload)
+		share := remaining * models.GigaBytes(weight/totalWeight)
+		allocation := min(demands[workload], share)
+		allocations[workload] = allocation
+		remaining -= allocation
+		totalWeight -= weight
+	}
+	return allocations
+}
+
+// FindCapAbusers returns the workloads to sample so that the total ingestion of a cluster stays within ingestionCap.
+// Each workload gets its max-min fair share of the cap weighted by its priority, see FairShare, and workloads
+// ingesting more than their share are returned with their share as budget. The ingestion of fixed workloads,
+// e.g. exempt ones, can not be sampled and is taken from the cap first.
+func FindCapAbusers(
+	ingestedBytes []models.WorkloadIngestedBytes,
+	ingestionCap models.GigaBytes,
+	priorities map[string]float64,
+	fixed map[string]bool,
+) []models.OverBudgetWorkload {
+	var total, reserved models.GigaBytes
+	demands := make(map[string]models.GigaBytes, len(ingestedBytes))
+	clusters := make(map[string]string, len(ingestedBytes))
+	for _, w := range ingestedBytes {
+		ingestion := models.GigaBytes(w.Value / 1000000000.0)
+		total += ingestion
+		if fixed[w.Workload] {
+			reserved += ingestion
+			continue
+		}
+		demands[w.Workload] += ingestion
+		clusters[w.Workload] = w.Cluster
+	}
+
+	log.Info().
+		Float64("cap", float64(ingestionCap)).
+		Float64("ingestion", float64(total)).
+		Int("workloads", len(ingestedBytes)).
+		Msg("ingestion cap utilization")
+
+	if ingestionCap <= 0 || total <= ingestionCap {
+		return nil
+	}
+
+	var overBudgetWorkloads []models.OverBudgetWorkload
+	for workload, allocation := range FairShare(demands, priorities, ingestionCap-reserved) {
+		if allocation >= demands[workload] {
+			continue
+		}
+		overBudgetWorkloads = append(overBudgetWorkloads, models.OverBudgetWorkload{
+			Cluster:          clusters[workload],
+			Workload:         workload,
+			Budget:           allocation,
+			CurrentIngestion: demands[workload],
+			Capped:           true,
+		})
+	}
+	sort.Slice(overBudgetWorkloads, func(i, j int) bool {
+		return overBudgetWorkloads[i].Workload < overBudgetWorkloads[j].Workload
+	})
+	return overBudgetWorkloads
+}
+
+// MergeOverBudget joins over-budget workloads found by different checks, keeping the lowest budget of each workload
+func MergeOverBudget(lists ...[]models.OverBudgetWorkload) []models.OverBudgetWorkload {
+	var merged []models.OverBudgetWorkload
+	index := make(map[string]int)
+	for _, list := range lists {
+		for _, w := range list {
+			i, ok := index[w.Workload]
+			if !ok {
+				index[w.Workload] = len(merged)
+				merged = append(merged, w)
+				continue
+			}
+			if w.Budget < merged[i].Budget {
+				merged[i] = w
+			}
+		}
+	}
+	return merged
+}
+
+// BuildWorkloadStatuses joins the budget override, dynamic budget and measured ingestion
+// of every workload seen in a run. Sampling information is filled in by the caller.
+func BuildWorkloadStatuses(
+	ingestedBytes []models.WorkloadIngestedBytes,
+	budgetOverride map[string]models.GigaBytes,
+	dynamicBudget map[string]models.GigaBytes,
+) []models.WorkloadStatus {
+
+	statuses := make(map[string]*models.WorkloadStatus, len(dynamicBudget))
+
+	for workload, b := range dynamicBudget {
+		statuses[workload] = &models.WorkloadStatus{
+			Workload:           workload,
+			DynamicBudget:      b,
+			SamplingPercentage: 100.0,
+		}
+	}
+
+	for _, w := range ingestedBytes {
+		s, ok := statuses[w.Workload]
+		if !ok {
+			s = &models.WorkloadStatus{
+				Workload:           w.Workload,
+				SamplingPercentage: 100.0,
+			}
+			statuses[w.Workload] = s
+		}
+		s.Cluster = w.Cluster
+		s.CurrentIngestion = models.GigaBytes(w.Value / 1000000000.0)
+		s.OverBudget = s.DynamicBudget > 0 && s.CurrentIngestion > s.DynamicBudget
+	}
+
+	for workload, override := range budgetOverride {
+		s, ok := statuses[workload]
+		if !ok {
+			s = &models.WorkloadStatus{
+				Workload:           workload,
+				SamplingPercentage: 100.0,
+			}
+			statuses[workload] = s
+		}
+		o := override
+		s.BudgetOverride = &o
+	}
+
+	result := make([]models.WorkloadStatus, 0, len(statuses))
+	for _, s := range statuses {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Workload < result[j].Workload
+	})
+
+	return result
+}
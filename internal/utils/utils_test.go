@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"configurator/internal/models"
+)
+
+func TestFindAbusersV2Pools(t *testing.T) {
+	pools := []models.BudgetPool{{
+		Name:    "payments",
+		Budget:  50,
+		Members: []string{"payments-api", "payments-worker", "payments-cron"},
+	}}
+	budgets := map[string]models.GigaBytes{
+		"payments-api":    10,
+		"payments-worker": 10,
+		"payments-cron":   10,
+		"search":          5,
+	}
+
+	// Members over their own budget are not sampled while the pool is within budget
+	got := FindAbusersV2([]models.WorkloadIngestedBytes{
+		{Workload: "payments-api", Value: 30e9},
+		{Workload: "payments-worker", Value: 15e9},
+		{Workload: "search", Value: 6e9},
+	}, budgets, pools)
+	if len(got) != 1 || got[0].Workload != "search" {
+		t.Fatalf("expected only search over budget, got %+v", got)
+	}
+
+	// The top contributors share the budget left by the other members
+	got = FindAbusersV2([]models.WorkloadIngestedBytes{
+		{Workload: "payments-api", Value: 60e9},
+		{Workload: "payments-worker", Value: 30e9},
+		{Workload: "payments-cron", Value: 10e9},
+	}, budgets, pools)
+	if len(got) != 2 || got[0].Workload != "payments-api" || got[1].Workload != "payments-worker" {
+		t.Fatalf("expected the two top contributors over budget, got %+v", got)
+	}
+
+	rates := CalculateSamplingRates(got)
+	if len(rates) != 2 {
+		t.Fatalf("expected 2 sampling rates, got %v", rates)
+	}
+	for workload, rate := range rates {
+		// (50 - 10) / 90 of the ingestion of the top contributors is left
+		if math.Abs(rate-40.0/90.0*100.0) > 1e-9 {
+			t.Errorf("expected %s to be sampled at %.2f%%, got %.2f%%", workload, 40.0/90.0*100.0, rate)
+		}
+	}
+	if got[0].Pool != "payments" {
+		t.Errorf("expected pool payments, got %q", got[0].Pool)
+	}
+}
+
+func TestFairShare(t *testing.T) {
+	demands := map[string]models.GigaBytes{"small": 10, "medium": 40, "large": 100, "critical": 100}
+	weights := map[string]float64{"critical": 2}
+
+	// 150 GB shared by weights 1, 1, 1 and 2: small keeps its 10, the 140 left is split 1:1:2
+	got := FairShare(demands, weights, 150)
+
+	want := map[string]models.GigaBytes{"small": 10, "medium": 35, "large": 35, "critical": 70}
+	for workload, w := range want {
+		if math.Abs(float64(got[workload]-w)) > 1e-9 {
+			t.Errorf("expected %s to get %.2f GB, got %.2f GB", workload, float64(w), float64(got[workload]))
+		}
+	}
+}
+
+func TestFindCapAbusers(t *testing.T) {
+	ingestedBytes := []models.WorkloadIngestedBytes{
+		{Cluster: "c1", Workload: "small", Value: 10e9},
+		{Cluster: "c1", Workload: "large", Value: 100e9},
+		{Cluster: "c1", Workload: "exempt", Value: 50e9},
+	}
+
+	if got := FindCapAbusers(ingestedBytes, 200, nil, nil); len(got) != 0 {
+		t.Fatalf("expected no workloads within the cap, got %+v", got)
+	}
+
+	// The exempt workload keeps its 50 GB, large gets the 40 GB small leaves of the remaining 50 GB
+	got := FindCapAbusers(ingestedBytes, 100, nil, map[string]bool{"exempt": true})
+	if len(got) != 1 || got[0].Workload != "large" || !got[0].Capped {
+		t.Fatalf("expected only large to be capped, got %+v", got)
+	}
+	if math.Abs(float64(got[0].Budget)-40) > 1e-9 {
+		t.Errorf("expected large to get 40 GB, got %.2f GB", float64(got[0].Budget))
+	}
+
+	// A workload over its own budget keeps the lower of both budgets
+	merged := MergeOverBudget(
+		[]models.OverBudgetWorkload{{Workload: "large", Budget: 20}, {Workload: "small", Budget: 5}},
+		got,
+	)
+	if len(merged) != 2 || merged[0].Budget != 20 || merged[0].Capped {
+		t.Errorf("expected the own budget of large to be kept, got %+v", merged)
+	}
+}
+
+func TestCalculateSamplingRatesForecast(t *testing.T) {
+	rates := CalculateSamplingRates([]models.OverBudgetWorkload{
+		// 2GB of budget left for the 8GB projected for the rest of the day
+		{Workload: "checkout", Budget: 10, CurrentIngestion: 8, ProjectedIngestion: 16},
+		{Workload: "search", Budget: 5, CurrentIngestion: 10},
+	})
+	if math.Abs(rates["checkout"]-25) > 1e-9 {
+		t.Errorf("expected checkout to keep 25%% for the rest of the day, got %v", rates["checkout"])
+	}
+	if math.Abs(rates["search"]-50) > 1e-9 {
+		t.Errorf("expected search to keep 50%%, got %v", rates["search"])
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"configurator/internal/kubernetes"
+	"configurator/internal/metrics"
+)
+
+var (
+	leaderCancel context.CancelFunc
+	leaderDone   chan struct{}
+)
+
+// startLeaderElection campaigns for the leader Lease in the background and runs the
+// scheduler only while this instance is the leader. Standbys take over once the Lease
+// expires or is released, a leader that loses the Lease exits.
+func startLeaderElection() {
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderCancel = cancel
+	leaderDone = make(chan struct{})
+
+	metrics.RecordLeadership(false)
+
+	go func() {
+		defer close(leaderDone)
+		runLeaderElection(ctx)
+	}()
+}
+
+// runLeaderElection blocks until ctx is cancelled
+func runLeaderElection(ctx context.Context) {
+	identity := leaderIdentity()
+
+	err := k8sClient.RunLeaderElection(
+		ctx,
+		kubernetes.LeaderElectionConfig{
+			LeaseName:      cfg.LeaderElection.LeaseName,
+			LeaseNamespace: cfg.LeaderElection.LeaseNamespace,
+			Identity:       identity,
+			LeaseDuration:  cfg.LeaderElection.LeaseDuration,
+			RenewDeadline:  cfg.LeaderElection.RenewDeadline,
+			RetryPeriod:    cfg.LeaderElection.RetryPeriod,
+		},
+		func(_ context.Context) {
+			log.Info().
+				Str("identity", identity).
+				Msg("Acquired leadership, starting scheduler")
+			metrics.RecordLeadership(true)
+			startScheduler()
+		},
+		func() {
+			metrics.RecordLeadership(false)
+			// The Lease is released on shutdown once the scheduler stopped
+			if ctx.Err() != nil {
+				return
+			}
+			// A run in flight would keep writing while the new leader runs, exit instead of waiting for it.
+			// Kubernetes restarts the instance as a standby.
+			log.Fatal().
+				Str("identity", identity).
+				Msg("💀 Lost leadership, exiting to stop in-flight runs")
+		},
+	)
+	if err != nil {
+		log.Fatal().Err(err).Msg("💀 Leader election failed")
+	}
+}
+
+// stopLeaderElection releases the leader Lease so a standby can take over immediately
+func stopLeaderElection() {
+	if leaderCancel == nil {
+		return
+	}
+
+	log.Info().Msg("Releasing leadership...")
+	leaderCancel()
+	<-leaderDone
+}
+
+// leaderIdentity returns the identity used in the leader Lease,
+// the pod name when running in Kubernetes, or the hostname otherwise
+func leaderIdentity() string {
+	if podName := os.Getenv("POD_NAME"); podName != "" {
+		return podName
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Fatal().Err(err).Msg("💀 Failed to determine leader election identity")
+	}
+	return hostname
+}
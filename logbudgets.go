@@ -0,0 +1,154 @@
+package main
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+
+	"configurator/config"
+	"configurator/internal/budget"
+	"configurator/internal/models"
+)
+
+// fetchLogBudgets lists the LogBudgets in the cluster of a target, none if LogBudgets are disabled
+func fetchLogBudgets(t config.Target) ([]budget.LogBudget, error) {
+	if !cfg.LogBudgets.Enabled {
+		return nil, nil
+	}
+
+	client, err := targetClient(t)
+	if err != nil {
+		return nil, err
+	}
+	logBudgets, err := client.ListLogBudgets()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lb := range logBudgets {
+		if err := lb.Validate(); err != nil {
+			log.Warn().Err(err).
+				Str("target", t.Name).
+				Str("logbudget", lb.Ref()).
+				Msg("Ignoring invalid LogBudget")
+		}
+	}
+	return logBudgets, nil
+}
+
+// applyLogBudgets sets the budget of the workloads selected by a LogBudget unless budget.yaml sets it,
+// and exempts the workloads of LogBudgets in monitor mode from sampling until the next budget reset
+func applyLogBudgets(
+	t config.Target,
+	logBudgets []budget.LogBudget,
+	workloads []string,
+	workloadBudgets map[string]models.GigaBytes,
+	exemptions map[string]budget.Exemption,
+	now time.Time,
+) []budget.LogBudgetAssignment {
+	assignments := budget.AssignLogBudgets(logBudgets, workloads, workloadBudgets, cfg.WorkloadIdentity.Namespaced)
+
+	for _, a := range assignments {
+		if a.OverriddenBy != "" {
+			log.Debug().
+				Str("target", t.Name).
+				Str("workload", a.Workload).
+				Str("logbudget", a.LogBudget.Ref()).
+				Str("overridden_by", a.OverriddenBy).
+				Msg("LogBudget is overridden")
+			continue
+		}
+
+		workloadBudgets[a.Workload] = a.LogBudget.Spec.DailyIngestionBudget.GigaBytes()
+
+		if _, exempt := exemptions[a.Workload]; exempt || a.LogBudget.Mode() != budget.EnforcementModeMonitor {
+			continue
+		}
+		exemptions[a.Workload] = budget.Exemption{
+			Workload:  a.Workload,
+			Owner:     "LogBudget " + a.LogBudget.Ref(),
+			Reason:    "enforcement mode is monitor",
+			ExpiresAt: nextBudgetReset(now),
+		}
+	}
+	return assignments
+}
+
+// updateLogBudgetStatuses reports the ingestion, budget and sampling of the selected workloads to each LogBudget.
+// Failed updates are only logged, the next run updates the status again.
+func updateLogBudgetStatuses(t config.Target, logBudgets []budget.LogBudget, assignments []budget.LogBudgetAssignment, now time.Time) {
+	if len(logBudgets) == 0 {
+		return
+	}
+
+	byLogBudget := make(map[string][]budget.LogBudgetWorkloadStatus, len(logBudgets))
+	for _, a := range assignments {
+		status := budget.LogBudgetWorkloadStatus{
+			Workload:           a.Workload,
+			Target:             t.Name,
+			SamplingPercentage: 100.0,
+			OverriddenBy:       a.OverriddenBy,
+		}
+		if w, ok := enforcementState.TargetWorkload(t.Name, a.Workload); ok {
+			status.CurrentIngestionGB = float64(w.CurrentIngestion)
+			status.BudgetGB = float64(w.DynamicBudget)
+			status.SamplingPercentage = w.SamplingPercentage
+		}
+		ref := a.LogBudget.Ref()
+		byLogBudget[ref] = append(byLogBudget[ref], status)
+	}
+
+	client, err := targetClient(t)
+	if err != nil {
+		log.Warn().Err(err).Str("target", t.Name).Msg("Failed to update LogBudget statuses")
+		return
+	}
+
+	for _, lb := range logBudgets {
+		status := budget.LogBudgetStatus{
+			ObservedGeneration: lb.Generation,
+			LastUpdated:        now.UTC(),
+			EnforcementMode:    lb.Mode(),
+			Workloads:          byLogBudget[lb.Ref()],
+		}
+		if err := lb.Validate(); err != nil {
+			status.Error = err.Error()
+		}
+		if status.Workloads == nil {
+			status.Workloads = []budget.LogBudgetWorkloadStatus{}
+		}
+
+		if err := client.UpdateLogBudgetStatus(lb.Namespace, lb.Name, status); err != nil {
+			log.Warn().Err(err).
+				Str("target", t.Name).
+				Str("logbudget", lb.Ref()).
+				Msg("Failed to update LogBudget status")
+		}
+	}
+}
+
+// nextBudgetReset returns the next activation of the budget reset schedule after now,
+// or a day after now if the schedule can not be parsed
+func nextBudgetReset(now time.Time) time.Time {
+	schedule, err := cron.ParseStandard(cfg.Scheduling.Cron.BudgetReset)
+	if err != nil {
+		return now.Add(24 * time.Hour)
+	}
+	if schedulerLocation != nil {
+		now = now.In(schedulerLocation)
+	}
+	return schedule.Next(now)
+}
+
+// workloadNames returns the names of the workloads with ingestion or resource metrics
+func workloadNames(ingestedBytes []models.WorkloadIngestedBytes, resources []models.WorkloadResourceRequest) []string {
+	names := make([]string, 0, len(ingestedBytes)+len(resources))
+	for _, w := range ingestedBytes {
+		names = append(names, w.Workload)
+	}
+	for _, w := range resources {
+		names = append(names, w.Workload)
+	}
+	return names
+}
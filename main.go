@@ -10,9 +10,13 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -22,11 +26,14 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"configurator/config"
+	"configurator/internal/api"
 	"configurator/internal/budget"
 	"configurator/internal/kubernetes"
+	"configurator/internal/ledger"
 	"configurator/internal/logger"
 	"configurator/internal/metrics"
 	"configurator/internal/models"
+	"configurator/internal/notify"
 	"configurator/internal/promtail"
 	"configurator/internal/utils"
 )
@@ -35,20 +42,45 @@ import (
 const (
 	timeRange      = "24h"
 	retryDelaySecs = 30
+	resetLookback  = 7 * 24 * time.Hour
+
+	taskBudgetReset    = "budget_reset"
+	taskIngestionCheck = "ingestion_check"
+	taskApply          = "apply"
+	taskReset          = "reset"
 )
 
 var (
 	cfg           *config.Config
 	k8sClient     *kubernetes.K8sClient
-	mimirClient   *metrics.Mimir
+	metricsClient metrics.MetricsQuerier
 	budgetConfig  budget.Budget
 	cronMutex     sync.Mutex
 	cronScheduler *cron.Cron
-	metricsPort   = flag.String("metrics-port", "9091", "Port to expose Prometheus metrics on")
+	// schedulerMutex guards starting and stopping cronScheduler
+	schedulerMutex sync.Mutex
+	// schedulerLocation is the time zone the cron jobs are evaluated in
+	schedulerLocation *time.Location
+	// enforcementState is the state exposed by the admin API
+	enforcementState = api.NewState()
+	// runLedger records every run, nil when history is disabled
+	runLedger ledger.Ledger
+	// notifier posts sampling changes, nil when notifications are disabled
+	notifier notify.Notifier
+	// diffOutput receives the Promtail config diff in dry-run mode, used by CLI commands
+	diffOutput  io.Writer
+	metricsPort = flag.String("metrics-port", "9091", "Port to expose Prometheus metrics on")
 )
 
 func main() {
 
+	// Run a one-off CLI command instead of the scheduler if one is given
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+	flag.Parse()
+
 	initConfig()
 	initLogger()
 
@@ -77,7 +109,20 @@ func main() {
 	wg.Wait()
 	log.Info().Msg("All initialization tasks completed successfully")
 
-	startScheduler()
+	initLedger()
+	initNotifier()
+
+	go initEnforcementState()
+
+	startConfigWatchers()
+
+	// Only the leader runs the scheduled tasks when running with multiple replicas
+	if cfg.LeaderElection.Enabled {
+		startLeaderElection()
+	} else {
+		metrics.RecordLeadership(true)
+		startScheduler()
+	}
 
 	handleShutdown()
 }
@@ -85,6 +130,9 @@ func main() {
 func initConfig() {
 	var err error
 	cfg, err = config.Init()
+	if err == nil {
+		err = validateMetricsAuth(cfg.Metrics.Auth)
+	}
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
@@ -112,25 +160,196 @@ func initKubernetes() {
 // initBudget loads the budget configuration
 func initBudget() {
 	var err error
-	budgetConfig, err = budget.New(cfg.Budget.ConfigPath)
+	budgetConfig, err = budget.New(cfg.Budget.ConfigPath, cfg.WorkloadIdentity.Namespaced)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load budget configuration")
 	}
+	if err := budgetConfig.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid budget configuration")
+	}
+	if err := lintBudget(cfg.Budget.ConfigPath, cfg.Targets); err != nil {
+		log.Fatal().Err(err).Msg("Invalid budget configuration")
+	}
 	log.Info().Msg("Budget configuration loaded successfully")
 }
 
-// initMetrics initializes the metrics client
+// lintBudget checks the budget file for problems loading it silently accepts, such as misspelled keys
+// or an org/env pair of a target that is not defined. Every problem is logged with its position.
+func lintBudget(path string, targets []config.Target) error {
+	problems, err := budget.Lint(path, targetOrgEnvs(targets))
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		log.Error().
+			Str("path", path).
+			Int("line", p.Line).
+			Int("column", p.Column).
+			Msg(p.Message)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in %s", len(problems), path)
+	}
+	return nil
+}
+
+// targetOrgEnvs returns the org/env pairs the targets read their budgets from
+func targetOrgEnvs(targets []config.Target) []budget.OrgEnv {
+	var orgEnvs []budget.OrgEnv
+	for _, t := range targets {
+		orgEnv := budget.OrgEnv{Org: t.Org, Env: t.Env}
+		if !slices.Contains(orgEnvs, orgEnv) {
+			orgEnvs = append(orgEnvs, orgEnv)
+		}
+	}
+	return orgEnvs
+}
+
+// initMetrics initializes the metrics client, measuring ingestion in Loki if it is the ingestion source
 func initMetrics() {
-	var err error
-	mimirClient, err = metrics.New(
+	names := metricNames(cfg.Metrics.Names, cfg.WorkloadIdentity.Labels)
+	mimir, err := metrics.New(
 		cfg.Metrics.MimirEndpoint,
 		cfg.Metrics.MimirTenant,
 		cfg.Metrics.QueryTimeout,
+		cfg.WorkloadIdentity.Namespaced,
+		names,
+		metricsAuth(cfg.Metrics.Auth),
 	)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create Mimir client")
 	}
-	log.Info().Msg("Metrics client initialized successfully")
+	metricsClient = mimir
+
+	if cfg.Metrics.IngestionSource == "loki" {
+		metricsClient, err = metrics.NewLoki(
+			cfg.Metrics.Loki.Endpoint,
+			cfg.Metrics.Loki.Tenant,
+			cfg.Metrics.QueryTimeout,
+			cfg.WorkloadIdentity.Namespaced,
+			names,
+			metricsAuth(cfg.Metrics.Auth),
+			mimir,
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create Loki client")
+		}
+	}
+	log.Info().Str("ingestion_source", cfg.Metrics.IngestionSource).Msg("Metrics client initialized successfully")
+}
+
+// metricsAuth converts the auth settings of config.yaml to the auth of the Mimir and Loki clients
+func metricsAuth(a config.MetricsAuth) metrics.Auth {
+	return metrics.Auth{
+		Username:        a.Basic.Username,
+		PasswordFile:    a.Basic.PasswordFile,
+		PasswordEnv:     a.Basic.PasswordEnv,
+		BearerTokenFile: a.Bearer.TokenFile,
+		BearerTokenEnv:  a.Bearer.TokenEnv,
+		CAFile:          a.TLS.CAFile,
+		CertFile:        a.TLS.CertFile,
+		KeyFile:         a.TLS.KeyFile,
+	}
+}
+
+// validateMetricsAuth checks the auth settings of config.yaml before any client is created
+func validateMetricsAuth(a config.MetricsAuth) error {
+	if err := metricsAuth(a).Validate(); err != nil {
+		return fmt.Errorf("invalid metrics.auth: %w", err)
+	}
+	return nil
+}
+
+// metricNames converts the metric names of config.yaml to the names the metrics client queries,
+// workloads are identified by workload_identity.labels unless a target has its own
+func metricNames(n config.MetricNames, workloadLabels []string) metrics.Names {
+	return metrics.Names{
+		LogBytes:       n.LogBytes,
+		CPURequest:     n.CPURequest,
+		MemoryRequest:  n.MemoryRequest,
+		Replicas:       n.Replicas,
+		ClusterMatcher: n.ClusterMatcher,
+		Labels:         metrics.Labels{Cluster: n.Labels.Cluster, Namespace: n.Labels.Namespace, Workload: workloadLabels},
+		ResourceLabels: metrics.Labels{Cluster: n.ResourceLabels.Cluster, Namespace: n.ResourceLabels.Namespace, Workload: n.ResourceLabels.Workload},
+	}
+}
+
+// initLedger opens the enforcement history ledger when history is enabled
+func initLedger() {
+	if !cfg.History.Enabled {
+		log.Debug().Msg("Enforcement history is disabled")
+		return
+	}
+
+	var err error
+	runLedger, err = newLedger()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open enforcement history ledger")
+	}
+	log.Info().
+		Str("backend", cfg.History.Backend).
+		Msg("Enforcement history ledger initialized successfully")
+}
+
+// newLedger creates the ledger for the configured history backend
+func newLedger() (ledger.Ledger, error) {
+	if cfg.History.Backend == "file" {
+		return ledger.NewFileLedger(cfg.History.Path)
+	}
+	return ledger.NewConfigMapLedger(
+		k8sClient,
+		cfg.History.ConfigMap.Namespace,
+		cfg.History.ConfigMap.Name,
+		cfg.History.Retention,
+	)
+}
+
+// initNotifier creates the Slack notifier when a webhook URL or token is configured
+func initNotifier() {
+	if cfg.Slack.WebhookURL == "" && cfg.Slack.Token == "" {
+		log.Debug().Msg("Slack notifications are disabled")
+		return
+	}
+
+	slack, err := notify.NewSlack(notify.SlackOptions{
+		WebhookURL: cfg.Slack.WebhookURL,
+		Token:      cfg.Slack.Token,
+		ProxyURL:   cfg.Slack.ProxyURL,
+		Username:   cfg.Slack.Username,
+		Channel:    cfg.Slack.Channel,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create Slack notifier")
+	}
+	notifier = slack
+	log.Info().Msg("Slack notifier initialized successfully")
+}
+
+// initEnforcementState loads the sampling currently configured in the Promtail of every target
+// so the admin API reports it before the first scheduled run
+func initEnforcementState() {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	for _, t := range cfg.Targets {
+		promtailConfig, err := getPromtailConfig(t)
+		if err != nil {
+			log.Warn().Err(err).Str("target", t.Name).Msg("Failed to load current sampling state")
+			continue
+		}
+
+		sampledWorkloads, err := promtailConfig.GetSampledWorkloads(targetSelector(t))
+		if err != nil {
+			log.Warn().Err(err).Str("target", t.Name).Msg("Failed to get current sampled workloads")
+			continue
+		}
+
+		enforcementState.SetSampling(t.Name, sampledWorkloads)
+		log.Debug().
+			Str("target", t.Name).
+			Int("sampled_workloads", len(sampledWorkloads)).
+			Msg("Loaded current sampling state")
+	}
 }
 
 // handleShutdown sets up signal handling for graceful shutdown
@@ -143,10 +362,10 @@ func handleShutdown() {
 	sig := <-sigChan
 	log.Info().Str("signal", sig.String()).Msg("Received shutdown signal")
 
-	if cronScheduler != nil {
-		log.Info().Msg("Stopping scheduler...")
-		cronScheduler.Stop()
-	}
+	stopConfigWatchers()
+	stopScheduler()
+	stopLeaderElection()
+
 	log.Info().Msg("Shutdown complete")
 	os.Exit(0)
 }
@@ -154,77 +373,347 @@ func handleShutdown() {
 func startScheduler() {
 	log.Info().Msg("Starting scheduler...")
 
-	// Load time zone
-	location, err := time.LoadLocation(cfg.Scheduling.TimeZone)
-	if err != nil {
-		log.Fatal().Err(err).
-			Msg("💀 Failed to load time zone")
-	}
-	log.Debug().
-		Str("timezone", location.String()).
-		Msg("Loaded time zone")
+	location := loadSchedulerLocation()
+
+	schedulerMutex.Lock()
+	defer schedulerMutex.Unlock()
 
 	// Use configured time zone for the cron scheduler
 	cronScheduler = cron.New(cron.WithLocation(location))
 
-	cronScheduler.AddFunc(
+	if _, err := cronScheduler.AddFunc(
 		cfg.Scheduling.Cron.BudgetReset,
 		func() {
 			cronMutex.Lock()
 			defer cronMutex.Unlock()
 			midnightCron()
-		})
+		}); err != nil {
+		log.Fatal().Err(err).
+			Str("cron", cfg.Scheduling.Cron.BudgetReset).
+			Msg("💀 Failed to schedule budget reset")
+	}
+
+	if _, err := cronScheduler.AddFunc(
+		cfg.Scheduling.Cron.IngestionCheck,
+		func() {
+			cronMutex.Lock()
+			defer cronMutex.Unlock()
+			ingestionCheckCron()
+		}); err != nil {
+		log.Fatal().Err(err).
+			Str("cron", cfg.Scheduling.Cron.IngestionCheck).
+			Msg("💀 Failed to schedule ingestion check")
+	}
 
 	cronScheduler.Start()
 
 	log.Info().
 		Str("quota_reset", cfg.Scheduling.Cron.BudgetReset).
+		Str("ingestion_check", cfg.Scheduling.Cron.IngestionCheck).
 		Msg("Scheduler started with cron jobs")
 }
 
-// midnightCron is the main job that runs at the configured schedule to check workload
-// ingestion and apply sampling if needed
+// loadSchedulerLocation loads the configured time zone the scheduled tasks are evaluated in
+func loadSchedulerLocation() *time.Location {
+	location, err := time.LoadLocation(cfg.Scheduling.TimeZone)
+	if err != nil {
+		log.Fatal().Err(err).
+			Msg("💀 Failed to load time zone")
+	}
+	log.Debug().
+		Str("timezone", location.String()).
+		Msg("Loaded time zone")
+	schedulerLocation = location
+
+	return location
+}
+
+// stopScheduler stops the cron scheduler and waits for running jobs to finish
+func stopScheduler() {
+	schedulerMutex.Lock()
+	defer schedulerMutex.Unlock()
+
+	if cronScheduler == nil {
+		return
+	}
+
+	log.Info().Msg("Stopping scheduler...")
+	<-cronScheduler.Stop().Done()
+	cronScheduler = nil
+}
+
+// midnightCron runs at the budget reset schedule and clears all sampling stages
+// added during the previous budget day, so every workload starts with a clean slate
 func midnightCron() {
 	log.Debug().
-		Msg("Starting daily budget check and sampling adjustment")
+		Msg("Starting daily budget reset")
 
-	// Step 1: Get budgets and current ingestion data
-	workloadBudgets, workloadResources, ingestedBytes, err := collectBudgetData()
+	run := newRun(taskBudgetReset)
+
+	err := forEachTarget(run, cfg.Targets, func(t config.Target) error {
+		return resetTarget(run, t, false)
+	})
+	finishRun(run, err)
+	if err != nil {
+		log.Error().Err(err).Msg("Budget reset failed")
+		return
+	}
+
+	log.Info().
+		Msg("Daily budget reset completed successfully")
+}
+
+// resetTarget removes all sampling stages from the Promtail config of a target.
+// If allowAllLogs is set, drop stages added by the configurator are removed as well.
+func resetTarget(run *models.EnforcementRun, t config.Target, allowAllLogs bool) error {
+	promtailConfig, err := getPromtailConfig(t)
+	if err != nil {
+		return err
+	}
+
+	update, err := updateSamplingConfig(t, promtailConfig, map[string]float64{}, allowAllLogs)
+	if err != nil {
+		return fmt.Errorf("failed to reset sampling: %w", err)
+	}
+	notifySamplingChange(run, t, update)
+
+	return nil
+}
+
+// ingestionCheckCron runs periodically during the budget day to check workload
+// ingestion since the last reset and apply sampling to workloads that crossed their budget
+func ingestionCheckCron() {
+	log.Debug().
+		Msg("Starting intra-day budget check")
+
+	run := newRun(taskIngestionCheck)
+	run.TimeRange = budgetDayRange(run.StartedAt)
+
+	enforceBudgets(run, cfg.Targets)
+}
+
+// enforceBudgets runs one enforcement cycle over the run's time range for every target:
+// it finds the workloads exceeding their budget, applies sampling to them and finishes the run.
+// A failing target does not stop the others.
+func enforceBudgets(run *models.EnforcementRun, targets []config.Target) {
+	err := forEachTarget(run, targets, func(t config.Target) error {
+		return enforceTarget(run, t)
+	})
+	finishRun(run, err)
 	if err != nil {
 		log.Error().Err(err).Msg("Budget check failed")
-		metrics.RecordTaskExecution(false)
 		return
 	}
 
+	log.Info().
+		Str("task", run.Task).
+		Str("time_range", run.TimeRange).
+		Msg("Budget check and sampling adjustment completed successfully")
+}
+
+// enforceTarget runs one enforcement cycle for a single target
+func enforceTarget(run *models.EnforcementRun, t config.Target) error {
+	exemptions, err := activeExemptions(t, run.StartedAt, true)
+	if err != nil {
+		return err
+	}
+
+	logBudgets, err := fetchLogBudgets(t)
+	if err != nil {
+		return err
+	}
+
+	// Steps 1-3: Find workloads exceeding their budget
+	overBudgetWorkloads, assignments, err := evaluateBudgets(run, t, logBudgets, exemptions)
+	if err != nil {
+		return err
+	}
+
+	// Exempt workloads may still be sampled from earlier in the budget day
+	if len(overBudgetWorkloads) == 0 && len(exemptions) == 0 {
+		log.Info().Str("target", t.Name).Msg("no workloads are currently over budget")
+	} else if err := applySamplingToWorkloads(run, t, overBudgetWorkloads, exemptions); err != nil {
+		// Step 4: Apply sampling to over-budget workloads
+		return fmt.Errorf("failed to apply sampling: %w", err)
+	}
+
+	updateLogBudgetStatuses(t, logBudgets, assignments, run.StartedAt)
+	return nil
+}
+
+// evaluateBudgets measures the ingestion of a target over the run's time range, compares it
+// with the budget of each workload and returns the workloads exceeding their budget which are not exempt,
+// together with the workloads selected by LogBudgets. Workloads of LogBudgets in monitor mode are added to exemptions.
+func evaluateBudgets(
+	run *models.EnforcementRun,
+	t config.Target,
+	logBudgets []budget.LogBudget,
+	exemptions map[string]budget.Exemption,
+) ([]models.OverBudgetWorkload, []budget.LogBudgetAssignment, error) {
+	// Step 1: Get budgets and current ingestion data
+	workloadBudgets, workloadResources, ingestedBytes, err := collectBudgetData(t, run.TimeRange, run.StartedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Entries listed without a namespace apply to the workload of that name in every namespace
+	workloads := workloadNames(ingestedBytes, workloadResources)
+	namespaced := cfg.WorkloadIdentity.Namespaced
+	workloadBudgets = budget.ResolveWorkloadKeys(workloadBudgets, workloads, namespaced)
+	maps.Copy(exemptions, budget.ResolveWorkloadKeys(exemptions, workloads, namespaced))
+	configured := maps.Clone(workloadBudgets)
+	assignments := applyLogBudgets(t, logBudgets, workloads, workloadBudgets, exemptions, run.StartedAt)
+
 	// Step 2: Calculate dynamic budgets based on resource usage
 	dynamicBudget, err := calculateDynamicBudgets(workloadBudgets, workloadResources)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to calculate dynamic budgets")
-		metrics.RecordTaskExecution(false)
-		return
+		return nil, nil, fmt.Errorf("failed to calculate dynamic budgets: %w", err)
+	}
+	// Budgets from budget.yaml already include the active overrides
+	budget.ApplyOverrides(budgetConfig.ActiveOverrides(t.Org, t.Env, run.StartedAt), t.Org, t.Env, namespaced, dynamicBudget, configured)
+
+	// Weekly and monthly budgets are compared with the ingestion since the start of their period
+	dayIngestion := ingestedBytes
+	ingestedBytes, periods, err := applyBudgetPeriods(t, run.StartedAt, ingestedBytes, dynamicBudget, workloadBudgets)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pools, err := budgetConfig.ExtractPools(t.Org, t.Env)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to extract budget pools: %w", err)
 	}
 
 	// Step 3: Find workloads exceeding their budget
-	overBudgetWorkloads := findOverBudgetWorkloads(ingestedBytes, dynamicBudget)
-	if len(overBudgetWorkloads) == 0 {
-		log.Info().Msg("no workloads are currently over budget")
+	overBudget := findOverBudgetWorkloads(t, ingestedBytes, dynamicBudget, pools, exemptions)
+	if cfg.Budget.Allocation == "fair_share" {
+		overBudget = applyIngestionCap(t, dayIngestion, overBudget, exemptions)
+	}
+
+	// Workloads projected over budget by the end of the budget day are sampled early
+	projected := make(map[string]models.GigaBytes)
+	if forecastEnabled(run) {
+		withForecast, forecasts, err := applyForecast(run, t, dayIngestion, dynamicBudget, periods, pools, exemptions, overBudget)
+		if err != nil {
+			log.Error().Err(err).Str("target", t.Name).Msg("Failed to forecast ingestion, enforcing on the current ingestion")
+		} else {
+			overBudget = withForecast
+			for _, f := range forecasts {
+				projected[f.Workload] = f.Projected
+			}
+		}
+	}
+
+	statuses := utils.BuildWorkloadStatuses(ingestedBytes, workloadBudgets, dynamicBudget)
+	for i := range statuses {
+		// Pool members are only over budget if they are sampled for their pool
+		if pool, ok := utils.PoolOf(pools, statuses[i].Workload); ok {
+			statuses[i].Pool = pool.Name
+			statuses[i].OverBudget = slices.ContainsFunc(overBudget, func(w models.OverBudgetWorkload) bool {
+				return w.Workload == statuses[i].Workload
+			})
+		}
+		statuses[i].Target = t.Name
+		if statuses[i].Cluster == "" {
+			statuses[i].Cluster = t.Cluster
+		}
+		_, statuses[i].Exempt = exemptions[statuses[i].Workload]
+		statuses[i].Period = periods[statuses[i].Workload]
+		statuses[i].Capped = slices.ContainsFunc(overBudget, func(w models.OverBudgetWorkload) bool {
+			return w.Workload == statuses[i].Workload && w.Capped
+		})
+		if p, ok := projected[statuses[i].Workload]; ok {
+			statuses[i].ProjectedIngestion = &p
+		}
+	}
+	run.Workloads = append(run.Workloads, statuses...)
+	enforcementState.SetWorkloads(t.Name, statuses)
+
+	return overBudget, assignments, nil
+}
+
+// newRun starts tracking a new execution of the given task
+func newRun(task string) *models.EnforcementRun {
+	startedAt := time.Now()
+	if schedulerLocation != nil {
+		startedAt = startedAt.In(schedulerLocation)
+	}
+	return &models.EnforcementRun{
+		ID:        fmt.Sprintf("%s-%s", startedAt.UTC().Format("20060102T150405Z"), task),
+		Task:      task,
+		StartedAt: startedAt,
+		DryRun:    cfg.DryRun,
 	}
+}
 
-	// Step 4: Apply sampling to over-budget workloads
-	err = applySamplingToWorkloads(overBudgetWorkloads)
+// finishRun records the outcome of a run in the metrics and the admin API state
+func finishRun(run *models.EnforcementRun, err error) {
+	run.FinishedAt = time.Now().In(run.StartedAt.Location())
+	run.Success = err == nil
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to apply sampling")
-		metrics.RecordTaskExecution(false)
-		return
+		run.Error = err.Error()
 	}
-	metrics.RecordTaskExecution(true)
 
-	log.Info().
-		Msg("Daily budget check and sampling adjustment completed successfully")
+	// Reflect the sampling configured by this run in the recorded workloads
+	for i, w := range run.Workloads {
+		if current, ok := enforcementState.TargetWorkload(w.Target, w.Workload); ok {
+			run.Workloads[i].Sampled = current.Sampled
+			run.Workloads[i].SamplingPercentage = current.SamplingPercentage
+		}
+	}
+
+	metrics.RecordTaskExecution(run.Task, run.Success)
+	enforcementState.SetLastRun(*run)
+
+	if runLedger != nil {
+		if err := runLedger.Append(*run); err != nil {
+			log.Error().Err(err).
+				Str("run_id", run.ID).
+				Msg("Failed to record run in enforcement history")
+		}
+	}
 }
 
-// collectBudgetData gathers all necessary data for budget calculations
-func collectBudgetData() (map[string]models.GigaBytes, []models.WorkloadResourceRequest, []models.WorkloadIngestedBytes, error) {
+// budgetDayRange returns the PromQL range covering ingestion since the most recent
+// budget reset. It falls back to the full day range if the reset schedule can not be
+// resolved.
+func budgetDayRange(now time.Time) string {
+	schedule, err := cron.ParseStandard(cfg.Scheduling.Cron.BudgetReset)
+	if err != nil {
+		log.Warn().Err(err).
+			Str("fallback", timeRange).
+			Msg("Failed to parse budget reset cron, using default time range")
+		return timeRange
+	}
+
+	if schedulerLocation != nil {
+		now = now.In(schedulerLocation)
+	}
+
+	// Walk the reset schedule forward to find the last activation before now
+	var lastReset time.Time
+	for t := schedule.Next(now.Add(-resetLookback)); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		lastReset = t
+	}
+	if lastReset.IsZero() {
+		return timeRange
+	}
+
+	elapsed := int(now.Sub(lastReset).Seconds())
+	if elapsed < 60 {
+		elapsed = 60
+	}
+
+	log.Debug().
+		Time("budget_day_start", lastReset).
+		Int("elapsed_seconds", elapsed).
+		Msg("Resolved start of budget day")
+
+	return fmt.Sprintf("%ds", elapsed)
+}
+
+// collectBudgetData gathers all necessary data for budget calculations.
+// ingestionRange is the PromQL range over which ingested bytes are measured, budget overrides active at now are applied.
+func collectBudgetData(t config.Target, ingestionRange string, now time.Time) (map[string]models.GigaBytes, []models.WorkloadResourceRequest, []models.WorkloadIngestedBytes, error) {
 	var wg sync.WaitGroup
 	wg.Add(3)
 
@@ -237,7 +726,7 @@ func collectBudgetData() (map[string]models.GigaBytes, []models.WorkloadResource
 	// Get configured workload budgets concurrently
 	go func() {
 		defer wg.Done()
-		budgets, err := budgetConfig.ExtractBudget(cfg.Budget.Org, cfg.Budget.Env)
+		budgets, err := budgetConfig.ExtractBudget(t.Org, t.Env, now)
 		if err != nil {
 			errCh <- fmt.Errorf("failed to extract budget: %w", err)
 			return
@@ -248,18 +737,28 @@ func collectBudgetData() (map[string]models.GigaBytes, []models.WorkloadResource
 	// Get resource requests for workloads concurrently
 	go func() {
 		defer wg.Done()
-		resources, err := mimirClient.GetAvgWorkloadResourceRequest(cfg.Cluster, timeRange)
+		resources, err := targetMetrics(t).GetAvgWorkloadResourceRequest(t.Cluster, timeRange)
 		if err != nil {
 			errCh <- fmt.Errorf("failed to get resource requests: %w", err)
 			return
 		}
+		if cfg.Budget.Strategy.Name == "per_replica" {
+			replicas, err := targetMetrics(t).GetAvgWorkloadReplicas(t.Cluster, timeRange)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to get replicas: %w", err)
+				return
+			}
+			for i := range resources {
+				resources[i].Replicas = replicas[resources[i].Workload]
+			}
+		}
 		workloadResourceCh <- resources
 	}()
 
 	// Get current ingestion data concurrently
 	go func() {
 		defer wg.Done()
-		ingested, err := mimirClient.GetIngestedGB(cfg.Cluster, timeRange)
+		ingested, err := targetMetrics(t).GetIngestedGB(t.Cluster, ingestionRange)
 		if err != nil {
 			errCh <- fmt.Errorf("failed to get current ingestion: %w", err)
 			return
@@ -313,20 +812,69 @@ func calculateDynamicBudgets(
 	return budget.CalculateDynamicBudget(
 		workloadResourceRequests,
 		workloadBudgetOverride,
-		cfg.Budget.Multiplier,
+		newBudgetStrategy(cfg.Budget),
 		cfg.Budget.Minimum,
 	)
 }
 
-// findOverBudgetWorkloads identifies workloads that are exceeding their budget
+// newBudgetStrategy creates the dynamic budget strategy selected in budget.strategy.name
+func newBudgetStrategy(b config.Budget) budget.Strategy {
+	s := b.Strategy
+	switch s.Name {
+	case "cpu_memory":
+		return budget.CPUMemoryStrategy{
+			StandardCores:  models.Cores(s.CPUMemory.StandardCores),
+			StandardMemory: models.Bytes(s.CPUMemory.StandardMemoryGB * 1e9),
+			CPUWeight:      s.CPUMemory.CPUWeight,
+			MemoryWeight:   s.CPUMemory.MemoryWeight,
+			Multiplier:     b.Multiplier,
+		}
+	case "per_replica":
+		return budget.PerReplicaStrategy{BudgetPerReplica: models.GigaBytes(s.PerReplica.BudgetPerReplica)}
+	case "flat":
+		return budget.FlatStrategy{DailyIngestionBudget: models.GigaBytes(s.Flat.DailyIngestionBudget)}
+	default:
+		return budget.CPUStrategy{
+			StandardCores: models.Cores(s.CPU.StandardCores),
+			Multiplier:    b.Multiplier,
+		}
+	}
+}
+
+// findOverBudgetWorkloads identifies workloads of a target that are exceeding their budget.
+// Exempt workloads are reported separately and not returned.
 func findOverBudgetWorkloads(
+	t config.Target,
 	ingestedBytes []models.WorkloadIngestedBytes,
 	dynamicBudget map[string]models.GigaBytes,
+	pools []models.BudgetPool,
+	exemptions map[string]budget.Exemption,
 ) []models.OverBudgetWorkload {
-	overBudgetWorkloads := utils.FindAbusersV2(ingestedBytes, dynamicBudget)
+	metrics.ResetExemptOverBudget(t.Name)
+
+	var overBudgetWorkloads []models.OverBudgetWorkload
+	for _, w := range utils.FindAbusersV2(ingestedBytes, dynamicBudget, pools) {
+		e, ok := exemptions[w.Workload]
+		if !ok {
+			overBudgetWorkloads = append(overBudgetWorkloads, w)
+			continue
+		}
+
+		log.Info().
+			Str("target", t.Name).
+			Str("workload", w.Workload).
+			Float64("budget_gb", float64(w.Budget)).
+			Float64("usage_gb", float64(w.CurrentIngestion)).
+			Str("owner", e.Owner).
+			Str("reason", e.Reason).
+			Time("expires_at", e.ExpiresAt).
+			Msg("Workload is over budget but exempt from sampling")
+		metrics.RecordExemptOverBudget(t.Name, w.Workload, e.Owner, e.ExpiresAt)
+	}
 
 	if len(overBudgetWorkloads) > 0 {
 		log.Info().
+			Str("target", t.Name).
 			Int("count", len(overBudgetWorkloads)).
 			Msg("Found workloads over budget")
 	}
@@ -334,32 +882,114 @@ func findOverBudgetWorkloads(
 	return overBudgetWorkloads
 }
 
-// applySamplingToWorkloads configures sampling for workloads that exceed their budget
-func applySamplingToWorkloads(overBudgetWorkloads []models.OverBudgetWorkload) error {
-	// Calculate sampling rates
-	samplingRates := utils.CalculateSamplingRates(overBudgetWorkloads)
-
+// applySamplingToWorkloads configures sampling for workloads that exceed their budget.
+// Sampling stages of workloads already sampled earlier in the budget day are kept.
+func applySamplingToWorkloads(
+	run *models.EnforcementRun,
+	t config.Target,
+	overBudgetWorkloads []models.OverBudgetWorkload,
+	exemptions map[string]budget.Exemption,
+) error {
 	// Get current Promtail config
-	promtailConfig, err := getPromtailConfig()
+	promtailConfig, err := getPromtailConfig(t)
+	if err != nil {
+		return err
+	}
+
+	samplingRates, err := planSamplingRates(t, promtailConfig, overBudgetWorkloads, exemptions)
 	if err != nil {
 		return err
 	}
 
 	// Apply sampling configuration
-	if err := updateSamplingConfig(promtailConfig, samplingRates); err != nil {
+	update, err := updateSamplingConfig(t, promtailConfig, samplingRates, false)
+	if err != nil {
 		return err
 	}
+	notifySamplingChange(run, t, update)
 
 	return nil
 }
 
-// getPromtailConfig retrieves and parses the current Promtail configuration
-func getPromtailConfig() (*promtail.PromtailConfig, error) {
+// planSamplingRates calculates the sampling rates of over-budget workloads and merges them
+// with the workloads already sampled in the Promtail config, newly calculated rates take precedence.
+// Exempt workloads are never sampled.
+func planSamplingRates(
+	t config.Target,
+	p *promtail.PromtailConfig,
+	overBudgetWorkloads []models.OverBudgetWorkload,
+	exemptions map[string]budget.Exemption,
+) (map[string]float64, error) {
+	// Calculate sampling rates
+	samplingRates := utils.CalculateSamplingRates(overBudgetWorkloads)
+
+	// Keep previously sampled workloads
+	sampledWorkloads, err := p.GetSampledWorkloads(targetSelector(t))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current sampled workloads: %w", err)
+	}
+	for workload, rate := range sampledWorkloads {
+		if _, ok := samplingRates[workload]; !ok {
+			samplingRates[workload] = rate
+		}
+	}
+	for workload := range exemptions {
+		if _, ok := samplingRates[workload]; ok {
+			log.Info().
+				Str("target", t.Name).
+				Str("workload", workload).
+				Msg("Releasing sampling of exempt workload")
+			delete(samplingRates, workload)
+		}
+	}
+
+	return samplingRates, nil
+}
+
+// notifySamplingChange posts a summary of the sampling and config changes made by a run.
+// Notification failures are logged and never fail the run.
+func notifySamplingChange(run *models.EnforcementRun, t config.Target, update *samplingUpdate) {
+	if notifier == nil {
+		return
+	}
+	if update.previousRates == nil {
+		log.Warn().Msg("Previous sampling is unknown, skipping notification")
+		return
+	}
+
+	summary := notify.BuildSummary(update.previousRates, update.samplingRates, enforcementState.TargetWorkloads(t.Name))
+	if len(update.diff.ScrapeConfigs) > 0 {
+		summary.ConfigChanges = update.diff.Summary()
+	}
+	if !summary.Changed() {
+		log.Debug().Msg("Sampling did not change, skipping notification")
+		return
+	}
+	summary.RunID = run.ID
+	summary.Task = run.Task
+	summary.Cluster = t.Cluster
+	summary.DryRun = cfg.DryRun
+
+	if err := notifier.Notify(summary); err != nil {
+		log.Error().Err(err).
+			Str("run_id", run.ID).
+			Str("target", t.Name).
+			Msg("Failed to send sampling notification")
+	}
+}
+
+// getPromtailConfig retrieves and parses the current Promtail configuration of a target
+func getPromtailConfig(t config.Target) (*promtail.PromtailConfig, error) {
+	kube, err := targetClient(t)
+	if err != nil {
+		return nil, err
+	}
+
 	// Fetch Promtail config
-	configYaml, err := k8sClient.FetchSecretValue(
-		cfg.Promtail.Secret.Namespace,
-		cfg.Promtail.Secret.Name,
-		cfg.Promtail.Secret.Key,
+	configYaml, err := kube.FetchSecretValue(
+		t.Secret.Namespace,
+		t.Secret.Name,
+		t.Secret.Key,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch Promtail config: %w", err)
@@ -374,67 +1004,137 @@ func getPromtailConfig() (*promtail.PromtailConfig, error) {
 	return config, nil
 }
 
-// updateSamplingConfig updates the Promtail configuration with new sampling rates
-func updateSamplingConfig(p *promtail.PromtailConfig, samplingRates map[string]float64) error {
+// samplingUpdate is the Promtail configuration rendered with new sampling rates
+type samplingUpdate struct {
+	// previousRates are the sampling rates configured before, nil if they could not be determined
+	previousRates map[string]float64
+	samplingRates map[string]float64
+	yamlContent   string
+	diff          *promtail.ConfigDiff
+}
+
+// updateSamplingConfig updates the Promtail configuration with new sampling rates.
+// If allowAllLogs is set, drop stages added by the configurator are removed as well.
+func updateSamplingConfig(t config.Target, p *promtail.PromtailConfig, samplingRates map[string]float64, allowAllLogs bool) (*samplingUpdate, error) {
+	update, err := renderSamplingConfig(t, p, samplingRates, allowAllLogs)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.diff.IsEmpty() {
+		log.Info().Str("target", t.Name).Msg("Promtail configuration is unchanged, skipping secret update")
+		// The sampling read from the unchanged config is the sampling in Promtail
+		if update.previousRates != nil {
+			enforcementState.SetSampling(t.Name, update.previousRates)
+		}
+		return update, nil
+	}
+
+	kube, err := targetClient(t)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update the Promtail secret
+	if err := kube.UpdateSecretValue(
+		t.Secret.Namespace,
+		t.Secret.Name,
+		t.Secret.Key,
+		update.yamlContent,
+		cfg.DryRun,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update Promtail config secret: %w", err)
+	}
+
+	// In dry-run mode the sampling in Promtail is unchanged
+	if !cfg.DryRun {
+		enforcementState.SetSampling(t.Name, samplingRates)
+	}
+
+	log.Debug().
+		Str("target", t.Name).
+		Msg("Successfully updated Promtail configuration with new sampling rates")
+	return update, nil
+}
+
+// renderSamplingConfig replaces the sampling stages of the Promtail configuration with the
+// given sampling rates, validates it and returns the resulting YAML and its diff against
+// the current configuration without writing it.
+// If allowAllLogs is set, drop stages added by the configurator are removed as well.
+func renderSamplingConfig(t config.Target, p *promtail.PromtailConfig, samplingRates map[string]float64, allowAllLogs bool) (*samplingUpdate, error) {
+	// Keep the current config to report what changed
+	currentYaml, err := p.ToYAML()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert current Promtail config to YAML: %w", err)
+	}
+
 	// Get current sampled workloads for tracking/notification
-	sampledWorkloadsMap, err := p.GetSampledWorkloads(cfg.Promtail.Sampling.Selector.Format)
+	sampledWorkloadsMap, err := p.GetSampledWorkloads(targetSelector(t))
 	if err != nil {
 		log.Warn().Err(err).Msg("Failed to get current sampled workloads")
+		sampledWorkloadsMap = nil
 		// Continue despite this error
 	} else {
-		sampledWorkloads := make([]string, 0, len(sampledWorkloadsMap))
-		for workload := range sampledWorkloadsMap {
-			sampledWorkloads = append(sampledWorkloads, workload)
-		}
+		log.Debug().
+			Str("target", t.Name).
+			Interface("sampling", sampledWorkloadsMap).
+			Msg("Current sampling of workloads")
+	}
 
-		if len(sampledWorkloads) == 0 {
-			log.Info().
-				Msg("there are no previously sampled workloads")
+	// Remove all drop stages added for budget enforcement
+	if allowAllLogs {
+		if err := p.AllowAllLogs(); err != nil {
+			return nil, fmt.Errorf("failed to remove existing drop stages: %w", err)
 		}
-		log.Info().
-			Strs("workloads", sampledWorkloads).
-			Msg("resetting sampling for previously sampled workloads")
 	}
 
 	// Remove all existing sampling stages
-	if _, err := p.RemoveAllSamplingStages(cfg.Promtail.Sampling.Selector.Format); err != nil {
-		return fmt.Errorf("failed to remove existing sampling stages: %w", err)
+	if _, err := p.RemoveAllSamplingStages(targetSelector(t)); err != nil {
+		return nil, fmt.Errorf("failed to remove existing sampling stages: %w", err)
 	}
 
 	// Add new sampling stages
-	_ = p.AddSamplingStages(samplingRates, cfg.Promtail.Sampling.Selector.Format)
+	_ = p.AddSamplingStages(samplingRates, targetSelector(t))
 
 	// Validate the updated config
 	if err := p.ValidateConfig(cfg.Promtail.LocalBin); err != nil {
-		return fmt.Errorf("promtail config validation failed: %w", err)
+		return nil, fmt.Errorf("promtail config validation failed: %w", err)
 	}
 
 	// Convert the config to YAML
 	yamlContent, err := p.ToYAML()
 	if err != nil {
-		return fmt.Errorf("failed to convert Promtail config to YAML: %w", err)
+		return nil, fmt.Errorf("failed to convert Promtail config to YAML: %w", err)
 	}
 
-	// // Update the Promtail secret
+	diff, err := promtail.Diff(currentYaml, yamlContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff Promtail config: %w", err)
+	}
 
-	if err := k8sClient.UpdateSecretValue(
-		cfg.Promtail.Secret.Namespace,
-		cfg.Promtail.Secret.Name,
-		cfg.Promtail.Secret.Key,
-		yamlContent,
-		cfg.DryRun,
-	); err != nil {
-		return fmt.Errorf("failed to update Promtail config secret: %w", err)
+	log.Info().
+		Str("target", t.Name).
+		Interface("stage_changes", diff.ScrapeConfigs).
+		Str("diff", diff.Unified).
+		Bool("dry_run", cfg.DryRun).
+		Msg("Promtail config changes")
+
+	if cfg.DryRun && diffOutput != nil {
+		fmt.Fprintf(diffOutput, "Target %s:\n%s\n%s", t.Name, diff.Summary(), diff.Unified)
 	}
 
-	log.Debug().
-		Msg("Successfully updated Promtail configuration with new sampling rates")
-	return nil
+	return &samplingUpdate{
+		previousRates: sampledWorkloadsMap,
+		samplingRates: samplingRates,
+		yamlContent:   yamlContent,
+		diff:          diff,
+	}, nil
 }
 
-// startMetricsServer starts an HTTP server to expose Prometheus metrics
+// startMetricsServer starts an HTTP server to expose Prometheus metrics and the admin API
 func startMetricsServer() {
 	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/api/v1/", api.NewHandler(enforcementState, apiRecommend))
 	log.Info().
 		Str("port", *metricsPort).
 		Msg("Starting metrics server")
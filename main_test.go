@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"configurator/config"
+	"configurator/internal/api"
+	"configurator/internal/budget"
+	"configurator/internal/kubernetes"
+	"configurator/internal/metrics"
+	"configurator/internal/models"
+	"configurator/internal/promtail"
+)
+
+const testPromtailConfig = `
+server:
+  http_listen_port: 3101
+scrape_configs:
+  - job_name: kubernetes-pods
+    pipeline_stages:
+      - cri: {}
+      - match:
+          pipeline_name: automated_sampling
+          selector: '{workload="old"} |= ""'
+          stages:
+            - sampling:
+                rate: 0.5
+`
+
+const testBudget = `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: api
+            daily_ingestion_budget: 10
+          - name: web
+            daily_ingestion_budget: 10
+`
+
+// fakeMetrics returns the same ingestion for every range and the resources of every workload
+type fakeMetrics struct {
+	ingested  []models.WorkloadIngestedBytes
+	resources []models.WorkloadResourceRequest
+}
+
+func (f *fakeMetrics) GetIngestedGB(string, string) ([]models.WorkloadIngestedBytes, error) {
+	return f.ingested, nil
+}
+
+func (f *fakeMetrics) GetIngestedGBOffset(string, string, string) ([]models.WorkloadIngestedBytes, error) {
+	return nil, nil
+}
+
+func (f *fakeMetrics) GetAvgWorkloadResourceRequest(string, string) ([]models.WorkloadResourceRequest, error) {
+	return f.resources, nil
+}
+
+func (f *fakeMetrics) GetAvgWorkloadReplicas(string, string) (map[string]float64, error) {
+	return nil, nil
+}
+
+func (f *fakeMetrics) GetDailyIngestedGB(context.Context, string, int, time.Time) (map[string][]models.GigaBytes, error) {
+	return nil, nil
+}
+
+func (f *fakeMetrics) GetBurnRate(string, time.Duration, time.Time) (map[string]float64, error) {
+	return nil, nil
+}
+
+func (f *fakeMetrics) WithWorkloadLabels([]string) metrics.MetricsQuerier {
+	return f
+}
+
+// newFakeMetrics returns the ingestion in GB of every workload, each requesting a CPU core
+func newFakeMetrics(ingestedGB map[string]float64) *fakeMetrics {
+	f := &fakeMetrics{}
+	for workload, gb := range ingestedGB {
+		f.ingested = append(f.ingested, models.WorkloadIngestedBytes{Cluster: "cluster-001", Workload: workload, Value: gb * 1e9})
+		f.resources = append(f.resources, models.WorkloadResourceRequest{Cluster: "cluster-001", Workload: workload, CPU: 1})
+	}
+	return f
+}
+
+// setupEnforcement replaces the configuration, clients, budget and state with a single target whose
+// Promtail config is testPromtailConfig, and returns the fake clientset holding its secret
+func setupEnforcement(t *testing.T, budgetContent string, m metrics.MetricsQuerier) *fake.Clientset {
+	t.Helper()
+
+	c := &config.Config{}
+	c.Promtail.LocalBin = "true"
+	c.Budget.Strategy.Name = "flat"
+	c.Budget.Strategy.Flat.DailyIngestionBudget = 1
+	c.Scheduling.Cron.BudgetReset = "0 0 * * *"
+	c.Targets = []config.Target{{
+		Name:           "cluster-001",
+		Cluster:        "cluster-001",
+		Secret:         config.Secret{Namespace: "promtail", Name: "promtail", Key: "promtail.yaml"},
+		Org:            "invest",
+		Env:            "prod",
+		SelectorFormat: `{workload="%s"} |= ""`,
+	}}
+	setTestConfig(t, c, time.UTC)
+
+	path := filepath.Join(t.TempDir(), "budget.yaml")
+	if err := os.WriteFile(path, []byte(budgetContent), 0o600); err != nil {
+		t.Fatalf("failed to write budget: %v", err)
+	}
+	b, err := budget.New(path, c.WorkloadIdentity.Namespaced)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	clientset := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "promtail", Name: "promtail"},
+		Data:       map[string][]byte{"promtail.yaml": []byte(testPromtailConfig)},
+	})
+
+	previousClient, previousMetrics, previousBudget, previousState := k8sClient, metricsClient, budgetConfig, enforcementState
+	k8sClient = kubernetes.NewForClientsets(clientset, nil)
+	metricsClient = m
+	budgetConfig = b
+	enforcementState = api.NewState()
+	t.Cleanup(func() {
+		k8sClient, metricsClient, budgetConfig, enforcementState = previousClient, previousMetrics, previousBudget, previousState
+	})
+
+	return clientset
+}
+
+// writeActions returns the actions of the fake clientset which change an object
+func writeActions(clientset *fake.Clientset) []k8stesting.Action {
+	var writes []k8stesting.Action
+	for _, a := range clientset.Actions() {
+		if a.GetVerb() != "get" && a.GetVerb() != "list" && a.GetVerb() != "watch" {
+			writes = append(writes, a)
+		}
+	}
+	return writes
+}
+
+// sampledWorkloads returns the sampling configured in the Promtail secret of the fake clientset
+func sampledWorkloads(t *testing.T, clientset *fake.Clientset) map[string]float64 {
+	t.Helper()
+	secret, err := clientset.CoreV1().Secrets("promtail").Get(context.TODO(), "promtail", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	p, err := promtail.New(string(secret.Data["promtail.yaml"]))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	sampled, err := p.GetSampledWorkloads(promtail.Selector{Format: `{workload="%s"} |= ""`})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return sampled
+}
+
+// setTestConfig replaces the global configuration for the duration of a test
+func setTestConfig(t *testing.T, c *config.Config, location *time.Location) {
+	t.Helper()
+	previousCfg, previousLocation := cfg, schedulerLocation
+	cfg, schedulerLocation = c, location
+	t.Cleanup(func() {
+		cfg, schedulerLocation = previousCfg, previousLocation
+	})
+}
+
+func TestBudgetDayRange(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	est := time.FixedZone("EST", -5*3600)
+
+	tests := []struct {
+		name     string
+		cron     string
+		location *time.Location
+		now      time.Time
+		want     string
+	}{
+		{
+			name:     "Midnight in UTC",
+			cron:     "0 0 * * *",
+			location: time.UTC,
+			now:      time.Date(2025, 3, 5, 14, 30, 0, 0, time.UTC),
+			want:     "52200s",
+		},
+		{
+			name:     "Midnight in IST",
+			cron:     "0 0 * * *",
+			location: ist,
+			now:      time.Date(2025, 3, 5, 14, 30, 0, 0, time.UTC),
+			want:     "72000s",
+		},
+		{
+			name:     "Midnight in EST of the previous UTC day",
+			cron:     "0 0 * * *",
+			location: est,
+			now:      time.Date(2025, 3, 5, 3, 0, 0, 0, time.UTC),
+			want:     "79200s",
+		},
+		{
+			name:     "Reset of the previous day",
+			cron:     "30 18 * * *",
+			location: time.UTC,
+			now:      time.Date(2025, 3, 5, 14, 30, 0, 0, time.UTC),
+			want:     "72000s",
+		},
+		{
+			name:     "Weekly reset",
+			cron:     "0 0 * * 1",
+			location: time.UTC,
+			now:      time.Date(2025, 3, 5, 14, 30, 0, 0, time.UTC),
+			want:     "225000s",
+		},
+		{
+			name:     "Reset just happened",
+			cron:     "0 0 * * *",
+			location: time.UTC,
+			now:      time.Date(2025, 3, 5, 0, 0, 20, 0, time.UTC),
+			want:     "60s",
+		},
+		{
+			name:     "No reset within the lookback",
+			cron:     "0 0 1 1 *",
+			location: time.UTC,
+			now:      time.Date(2025, 3, 5, 14, 30, 0, 0, time.UTC),
+			want:     timeRange,
+		},
+		{
+			name:     "Invalid cron",
+			cron:     "every night",
+			location: time.UTC,
+			now:      time.Date(2025, 3, 5, 14, 30, 0, 0, time.UTC),
+			want:     timeRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config.Config{}
+			c.Scheduling.Cron.BudgetReset = tt.cron
+			setTestConfig(t, c, tt.location)
+
+			if got := budgetDayRange(tt.now); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIngestionCheckCron(t *testing.T) {
+	tests := []struct {
+		name string
+		cron string
+		want string
+	}{
+		// A reset every minute always measures the minimum range
+		{name: "Since the last reset", cron: "* * * * *", want: "60s"},
+		{name: "Invalid reset cron", cron: "every night", want: timeRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config.Config{}
+			c.Scheduling.Cron.BudgetReset = tt.cron
+			setTestConfig(t, c, time.UTC)
+
+			ingestionCheckCron()
+
+			run, ok := enforcementState.LastRun()
+			if !ok {
+				t.Fatal("expected a finished run")
+			}
+			if run.Task != taskIngestionCheck {
+				t.Errorf("expected task %s, got %s", taskIngestionCheck, run.Task)
+			}
+			if run.TimeRange != tt.want {
+				t.Errorf("expected time range %s, got %s", tt.want, run.TimeRange)
+			}
+			if !run.Success {
+				t.Errorf("expected a successful run, got error %q", run.Error)
+			}
+		})
+	}
+}
+
+func TestZeroBudgetNotSampled(t *testing.T) {
+	clientset := setupEnforcement(t, `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: audit
+            daily_ingestion_budget: 0
+`, newFakeMetrics(map[string]float64{"audit": 100}))
+
+	run := newRun(taskApply)
+	run.TimeRange = "1h"
+	enforceBudgets(run, cfg.Targets)
+
+	if !run.Success {
+		t.Fatalf("expected a successful run, got error %q", run.Error)
+	}
+	// The flat strategy would give audit 1 GB
+	if sampled := sampledWorkloads(t, clientset); sampled["audit"] != 0 {
+		t.Errorf("expected audit not to be sampled, got %v", sampled)
+	}
+}
+
+func TestValidateMetricsAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    string
+		wantErr bool
+	}{
+		{name: "No auth", auth: ""},
+		{name: "Bearer token file", auth: "  auth:\n    bearer:\n      token_file: /var/run/secrets/token\n"},
+		{name: "Basic and bearer auth", auth: "  auth:\n    basic:\n      username: configurator\n      password_env: MIMIR_PASSWORD\n    bearer:\n      token_file: /var/run/secrets/token\n", wantErr: true},
+		{name: "Basic auth without password", auth: "  auth:\n    basic:\n      username: configurator\n", wantErr: true},
+		{name: "Client certificate without key", auth: "  auth:\n    tls:\n      cert_file: /etc/mimir/tls.crt\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			content := "cluster: a\nmetrics:\n  mimir_endpoint: http://mimir:8080\n  mimir_tenant: tenant\n" + tt.auth + "budget:\n  org: org\n  env: prod\n"
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			c, err := config.Load(path)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err := validateMetricsAuth(c.Metrics.Auth); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"configurator/config"
+	"configurator/internal/budget"
+	"configurator/internal/models"
+)
+
+// applyBudgetPeriods replaces the budget day ingestion and the budget of workloads with a weekly or monthly period
+// by their ingestion since the start of the period and the budget allowed for it. The period budget is scaled
+// from the daily budget resolved in dynamicBudget, or in listed for workloads without resource metrics.
+// It returns the new ingestion and the period of each of these workloads.
+func applyBudgetPeriods(
+	t config.Target,
+	now time.Time,
+	ingestedBytes []models.WorkloadIngestedBytes,
+	dynamicBudget map[string]models.GigaBytes,
+	listed map[string]models.GigaBytes,
+) ([]models.WorkloadIngestedBytes, map[string]string, error) {
+	byPeriod := make(map[string][]budget.PeriodBudget)
+	for _, p := range budgetConfig.ExtractPeriods(t.Org, t.Env) {
+		byPeriod[p.Period] = append(byPeriod[p.Period], p)
+	}
+	if len(byPeriod) == 0 {
+		return ingestedBytes, nil, nil
+	}
+
+	periodOf := make(map[string]string)
+	for _, period := range []string{budget.PeriodWeekly, budget.PeriodMonthly} {
+		periodBudgets := byPeriod[period]
+		if len(periodBudgets) == 0 {
+			continue
+		}
+
+		start := budget.PeriodStart(period, now)
+		current, err := targetMetrics(t).GetIngestedGB(t.Cluster, promRange(now.Sub(start)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get %s ingestion: %w", period, err)
+		}
+
+		var previous []models.WorkloadIngestedBytes
+		for _, p := range periodBudgets {
+			if !p.CarryOver {
+				continue
+			}
+			previousStart := budget.PeriodStart(period, start.Add(-time.Nanosecond))
+			previous, err = targetMetrics(t).GetIngestedGBOffset(t.Cluster, promRange(start.Sub(previousStart)), promRange(now.Sub(start)))
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get previous %s ingestion: %w", period, err)
+			}
+			break
+		}
+
+		periodBudgets = resolvePeriodBudgets(periodBudgets, current)
+		for _, p := range periodBudgets {
+			daily, ok := dynamicBudget[p.Workload]
+			if !ok {
+				// Workloads without resource metrics, e.g. jobs that did not run in the last day, have no dynamic budget
+				daily, ok = listed[p.Workload]
+			}
+			if !ok {
+				continue
+			}
+			p.Daily = daily
+			allowed := p.Allowed(now, ingestedGB(previous, p.Workload))
+			dynamicBudget[p.Workload] = allowed
+			periodOf[p.Workload] = period
+
+			ingestedBytes = replaceIngestion(ingestedBytes, current, p.Workload)
+
+			log.Debug().
+				Str("target", t.Name).
+				Str("workload", p.Workload).
+				Str("period", period).
+				Time("period_start", start).
+				Float64("period_ingestion_gb", float64(ingestedGB(current, p.Workload))).
+				Float64("daily_budget_gb", float64(daily)).
+				Float64("allowed_gb", float64(allowed)).
+				Msg("Resolved period budget")
+		}
+	}
+	return ingestedBytes, periodOf, nil
+}
+
+// resolvePeriodBudgets applies the period budgets of workloads listed without a namespace
+// to the workloads of that name ingesting in every namespace
+func resolvePeriodBudgets(periodBudgets []budget.PeriodBudget, ingestedBytes []models.WorkloadIngestedBytes) []budget.PeriodBudget {
+	listed := make(map[string]budget.PeriodBudget, len(periodBudgets))
+	for _, p := range periodBudgets {
+		listed[p.Workload] = p
+	}
+	resolved := make([]budget.PeriodBudget, 0, len(periodBudgets))
+	for workload, p := range budget.ResolveWorkloadKeys(listed, workloadNames(ingestedBytes, nil), cfg.WorkloadIdentity.Namespaced) {
+		p.Workload = workload
+		resolved = append(resolved, p)
+	}
+	slices.SortFunc(resolved, func(a, b budget.PeriodBudget) int { return strings.Compare(a.Workload, b.Workload) })
+	return resolved
+}
+
+// replaceIngestion replaces the ingestion of the workload with its ingestion in period
+func replaceIngestion(ingestedBytes, period []models.WorkloadIngestedBytes, workload string) []models.WorkloadIngestedBytes {
+	replaced := ingestedBytes[:0:0]
+	for _, w := range ingestedBytes {
+		if w.Workload != workload {
+			replaced = append(replaced, w)
+		}
+	}
+	for _, w := range period {
+		if w.Workload == workload {
+			replaced = append(replaced, w)
+		}
+	}
+	return replaced
+}
+
+// ingestedGB returns the total ingestion of the workload in gigabytes
+func ingestedGB(ingestedBytes []models.WorkloadIngestedBytes, workload string) models.GigaBytes {
+	var total models.GigaBytes
+	for _, w := range ingestedBytes {
+		if w.Workload == workload {
+			total += models.GigaBytes(w.Value / 1000000000.0)
+		}
+	}
+	return total
+}
+
+// promRange formats a duration as a PromQL range of at least a minute
+func promRange(d time.Duration) string {
+	return fmt.Sprintf("%ds", max(int(d.Seconds()), 60))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"configurator/internal/budget"
+	"configurator/internal/models"
+)
+
+// evaluateStatus evaluates the budgets of the only target at now and returns the status of the workload
+func evaluateStatus(t *testing.T, now time.Time, logBudgets []budget.LogBudget, workload string) models.WorkloadStatus {
+	t.Helper()
+	run := &models.EnforcementRun{Task: taskIngestionCheck, StartedAt: now, TimeRange: "12h"}
+	if _, _, err := evaluateBudgets(run, cfg.Targets[0], logBudgets, map[string]budget.Exemption{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	status, ok := enforcementState.TargetWorkload(cfg.Targets[0].Name, workload)
+	if !ok {
+		t.Fatalf("expected a status for %s", workload)
+	}
+	return status
+}
+
+func TestPeriodWithLogBudget(t *testing.T) {
+	setupEnforcement(t, `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: api
+            namespace: payments
+            period: weekly
+`, newFakeMetrics(map[string]float64{"payments/api": 40}))
+	cfg.WorkloadIdentity.Namespaced = true
+
+	logBudgets := []budget.LogBudget{{
+		Namespace: "payments",
+		Name:      "payments",
+		Spec: budget.LogBudgetSpec{
+			Selector:             budget.LogBudgetSelector{Workloads: []string{"api"}},
+			DailyIngestionBudget: 5,
+		},
+	}}
+	// Wednesday
+	now := time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	status := evaluateStatus(t, now, logBudgets, "payments/api")
+
+	if status.Period != budget.PeriodWeekly {
+		t.Errorf("expected a weekly period, got %q", status.Period)
+	}
+	if status.DynamicBudget != 35 {
+		t.Errorf("expected a weekly budget of 7 days of the LogBudget, 35 GB, got %v", status.DynamicBudget)
+	}
+	if !status.OverBudget {
+		t.Errorf("expected 40 GB to be over the weekly budget, got %+v", status)
+	}
+}
+
+func TestPeriodWithOverride(t *testing.T) {
+	setupEnforcement(t, `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: api
+            daily_ingestion_budget: 10
+            period: weekly
+overrides:
+  - name: sale
+    from: 2025-03-05
+    to: 2025-03-06
+    multiplier: 3
+`, newFakeMetrics(map[string]float64{"api": 100}))
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		want       models.GigaBytes
+		overBudget bool
+	}{
+		{name: "Sale day", now: time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC), want: 210},
+		{name: "Day after the sale", now: time.Date(2025, 3, 7, 12, 0, 0, 0, time.UTC), want: 70, overBudget: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := evaluateStatus(t, tt.now, nil, "api")
+
+			if status.DynamicBudget != tt.want {
+				t.Errorf("expected a weekly budget of %v GB, got %v", tt.want, status.DynamicBudget)
+			}
+			if status.OverBudget != tt.overBudget {
+				t.Errorf("expected over budget to be %v with 100 GB, got %v", tt.overBudget, status.OverBudget)
+			}
+		})
+	}
+}
+
+func TestMonthlyPeriodWithBudgetOverride(t *testing.T) {
+	setupEnforcement(t, `
+orgs:
+  - name: invest
+    envs:
+      - name: prod
+        workloads:
+          - name: batch
+            daily_ingestion_budget: 10
+            period: monthly
+overrides:
+  - name: month-end
+    workloads: [batch]
+    from: 2025-03-01
+    to: 2025-03-31
+    daily_ingestion_budget: 20
+`, newFakeMetrics(map[string]float64{"batch": 400}))
+
+	status := evaluateStatus(t, time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC), nil, "batch")
+
+	if status.Period != budget.PeriodMonthly {
+		t.Errorf("expected a monthly period, got %q", status.Period)
+	}
+	if status.DynamicBudget != 620 {
+		t.Errorf("expected a monthly budget of 31 days of the override, 620 GB, got %v", status.DynamicBudget)
+	}
+	if status.OverBudget {
+		t.Errorf("expected 400 GB to be within the monthly budget, got %+v", status)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"configurator/config"
+	"configurator/internal/api"
+	"configurator/internal/budget"
+	"configurator/internal/metrics"
+)
+
+// recommendBudgets recommends budgets for the workloads of a target from their daily ingestion
+// over the whole days before the current one
+func recommendBudgets(
+	ctx context.Context,
+	t config.Target,
+	b budget.Budget,
+	m metrics.MetricsQuerier,
+	o budget.RecommendOptions,
+	now time.Time,
+) (budget.RecommendationReport, error) {
+	end := budget.PeriodStart(budget.PeriodDaily, now)
+	daily, err := m.WithWorkloadLabels(t.WorkloadLabels).GetDailyIngestedGB(ctx, t.Cluster, o.Days, end)
+	if err != nil {
+		return budget.RecommendationReport{}, err
+	}
+
+	report, err := b.Recommend(t.Org, t.Env, daily, o)
+	if err != nil {
+		return budget.RecommendationReport{}, err
+	}
+	report.Target = t.Name
+	return report, nil
+}
+
+// apiRecommend serves the budget recommendations of the admin API, an empty target selects the only target
+func apiRecommend(ctx context.Context, target string, o budget.RecommendOptions) (budget.RecommendationReport, error) {
+	// Config and budget reloads swap these between runs
+	cronMutex.Lock()
+	targets, b, m, location := cfg.Targets, budgetConfig, metricsClient, schedulerLocation
+	cronMutex.Unlock()
+
+	if target == "" && len(targets) > 1 {
+		return budget.RecommendationReport{}, fmt.Errorf("%w, set the target query parameter", api.ErrUnknownTarget)
+	}
+	for _, t := range targets {
+		if target == "" || t.Name == target {
+			now := time.Now()
+			if location != nil {
+				now = now.In(location)
+			}
+			return recommendBudgets(ctx, t, b, m, o, now)
+		}
+	}
+	return budget.RecommendationReport{}, fmt.Errorf("%w %s", api.ErrUnknownTarget, target)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/knadh/koanf/providers/file"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+
+	"configurator/config"
+	"configurator/internal/budget"
+	"configurator/internal/logger"
+	"configurator/internal/metrics"
+)
+
+const (
+	reloadFileConfig = "config"
+	reloadFileBudget = "budget"
+)
+
+var (
+	// watchMutex guards the file watchers
+	watchMutex    sync.Mutex
+	configWatcher *watchedFile
+	budgetWatcher *watchedFile
+	configPath    string
+)
+
+// watchedFile calls onChange whenever the file at path changes
+type watchedFile struct {
+	path     string
+	onChange func()
+	// provider is nil while the file is not watched
+	provider *file.File
+	stopped  bool
+}
+
+// startConfigWatchers reloads config.yaml and budget.yaml whenever they change on disk
+func startConfigWatchers() {
+	configPath = config.FilePath()
+
+	watchMutex.Lock()
+	defer watchMutex.Unlock()
+
+	configWatcher = &watchedFile{path: configPath, onChange: reloadConfig}
+	configWatcher.start()
+	budgetWatcher = &watchedFile{path: cfg.Budget.ConfigPath, onChange: reloadBudget}
+	budgetWatcher.start()
+}
+
+// stopConfigWatchers stops watching the config files
+func stopConfigWatchers() {
+	watchMutex.Lock()
+	defer watchMutex.Unlock()
+
+	for _, w := range []*watchedFile{configWatcher, budgetWatcher} {
+		if w != nil {
+			w.stop()
+		}
+	}
+}
+
+// start watches the file, callers must hold watchMutex.
+// If the watch fails, e.g. because the file was replaced, it is set up again after retryDelaySecs
+// and the file is reloaded since changes may have been missed in between.
+func (w *watchedFile) start() {
+	provider := file.Provider(w.path)
+	err := provider.Watch(func(_ interface{}, err error) {
+		if err != nil {
+			log.Warn().Err(err).
+				Str("path", w.path).
+				Int("retry_in_secs", retryDelaySecs).
+				Msg("Stopped watching config file, retrying")
+			w.retry(provider)
+			return
+		}
+		w.onChange()
+	})
+	if err != nil {
+		log.Warn().Err(err).
+			Str("path", w.path).
+			Int("retry_in_secs", retryDelaySecs).
+			Msg("Failed to watch config file, retrying")
+		w.provider = nil
+		time.AfterFunc(retryDelaySecs*time.Second, w.restart)
+		return
+	}
+
+	w.provider = provider
+	log.Debug().Str("path", w.path).Msg("Watching config file for changes")
+}
+
+// retry restarts a watch which stopped with an error
+func (w *watchedFile) retry(provider *file.File) {
+	watchMutex.Lock()
+	defer watchMutex.Unlock()
+
+	if w.provider != provider {
+		return
+	}
+	w.provider = nil
+	time.AfterFunc(retryDelaySecs*time.Second, w.restart)
+}
+
+// restart watches the file again after a failed watch and reloads it
+func (w *watchedFile) restart() {
+	watchMutex.Lock()
+	defer watchMutex.Unlock()
+
+	if w.stopped || w.provider != nil {
+		return
+	}
+	w.start()
+	go w.onChange()
+}
+
+// stop stops watching the file, callers must hold watchMutex
+func (w *watchedFile) stop() {
+	w.stopped = true
+	if w.provider != nil {
+		_ = w.provider.Unwatch()
+		w.provider = nil
+	}
+}
+
+// reloadConfig loads and validates config.yaml and swaps it in between runs.
+// An invalid config, or one changing settings that need a restart, is rejected and the current config is kept.
+func reloadConfig() {
+	newCfg, err := config.Load(configPath)
+	if err == nil {
+		err = validateSchedule(newCfg.Scheduling)
+	}
+	if err == nil {
+		err = validateMetricsAuth(newCfg.Metrics.Auth)
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Str("path", configPath).
+			Msg("Rejected config reload, keeping the current config")
+		metrics.RecordConfigReload(reloadFileConfig, false)
+		return
+	}
+
+	cronMutex.Lock()
+	oldCfg := *cfg
+	if reflect.DeepEqual(oldCfg, newCfg) {
+		cronMutex.Unlock()
+		log.Debug().Str("path", configPath).Msg("Config is unchanged, skipping reload")
+		return
+	}
+	// Clients created at startup keep these settings, applying the rest of the config would mix old and new
+	if restart := restartRequired(oldCfg, newCfg); len(restart) > 0 {
+		cronMutex.Unlock()
+		log.Error().
+			Str("path", configPath).
+			Strs("settings", restart).
+			Msg("Rejected config reload, changed settings only take effect after a restart")
+		metrics.RecordConfigReload(reloadFileConfig, false)
+		return
+	}
+	cfg = &newCfg
+	cronMutex.Unlock()
+
+	metrics.RecordConfigReload(reloadFileConfig, true)
+	log.Info().Str("path", configPath).Msg("Config reloaded")
+
+	if oldCfg.Log != newCfg.Log {
+		logger.InitLogger(newCfg.Log.Level, newCfg.Log.Format)
+	}
+
+	if oldCfg.Budget.ConfigPath != newCfg.Budget.ConfigPath {
+		watchMutex.Lock()
+		if budgetWatcher != nil {
+			budgetWatcher.stop()
+		}
+		budgetWatcher = &watchedFile{path: newCfg.Budget.ConfigPath, onChange: reloadBudget}
+		budgetWatcher.start()
+		watchMutex.Unlock()
+		reloadBudget()
+	}
+
+	if oldCfg.Scheduling != newCfg.Scheduling {
+		rescheduleIfRunning()
+	}
+}
+
+// reloadBudget loads and validates budget.yaml and swaps it in between runs.
+// An invalid budget config is rejected and the current one is kept.
+func reloadBudget() {
+	cronMutex.Lock()
+	path := cfg.Budget.ConfigPath
+	targets := cfg.Targets
+	namespaced := cfg.WorkloadIdentity.Namespaced
+	cronMutex.Unlock()
+
+	newBudget, err := budget.New(path, namespaced)
+	if err == nil {
+		err = newBudget.Validate()
+	}
+	if err == nil {
+		err = lintBudget(path, targets)
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Str("path", path).
+			Msg("Rejected budget config reload, keeping the current budget config")
+		metrics.RecordConfigReload(reloadFileBudget, false)
+		return
+	}
+
+	cronMutex.Lock()
+	if reflect.DeepEqual(budgetConfig, newBudget) {
+		cronMutex.Unlock()
+		log.Debug().Str("path", path).Msg("Budget config is unchanged, skipping reload")
+		return
+	}
+	budgetConfig = newBudget
+	cronMutex.Unlock()
+
+	metrics.RecordConfigReload(reloadFileBudget, true)
+	log.Info().Str("path", path).Msg("Budget config reloaded")
+}
+
+// validateSchedule checks the cron expressions and time zone, which would otherwise
+// only fail once the scheduler is restarted
+func validateSchedule(s config.Scheduling) error {
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return err
+	}
+	if _, err := cron.ParseStandard(s.Cron.BudgetReset); err != nil {
+		return err
+	}
+	if _, err := cron.ParseStandard(s.Cron.IngestionCheck); err != nil {
+		return err
+	}
+	return nil
+}
+
+// restartRequired lists the changed settings used to create clients at startup
+func restartRequired(oldCfg, newCfg config.Config) []string {
+	var changed []string
+	if oldCfg.Mode != newCfg.Mode || oldCfg.KubeConfig != newCfg.KubeConfig {
+		changed = append(changed, "kube_config")
+	}
+	if !reflect.DeepEqual(oldCfg.Metrics, newCfg.Metrics) {
+		changed = append(changed, "metrics")
+	}
+	if oldCfg.WorkloadIdentity.Namespaced != newCfg.WorkloadIdentity.Namespaced {
+		changed = append(changed, "workload_identity.namespaced")
+	}
+	if !slices.Equal(oldCfg.WorkloadIdentity.Labels, newCfg.WorkloadIdentity.Labels) {
+		changed = append(changed, "workload_identity.labels")
+	}
+	if oldCfg.LeaderElection != newCfg.LeaderElection {
+		changed = append(changed, "leader_election")
+	}
+	if oldCfg.History != newCfg.History {
+		changed = append(changed, "history")
+	}
+	if oldCfg.Slack != newCfg.Slack {
+		changed = append(changed, "slack")
+	}
+	return changed
+}
+
+// rescheduleIfRunning restarts the scheduler with the current schedule,
+// standbys pick it up once they become the leader
+func rescheduleIfRunning() {
+	schedulerMutex.Lock()
+	running := cronScheduler != nil
+	schedulerMutex.Unlock()
+
+	if !running {
+		return
+	}
+
+	log.Info().Msg("Schedule changed, restarting scheduler")
+	stopScheduler()
+	startScheduler()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"configurator/config"
+)
+
+const testReloadConfig = `
+cluster: cluster-001
+metrics:
+  mimir_endpoint: http://mimir:8080
+  mimir_tenant: tenant
+budget:
+  org: org
+  env: prod
+`
+
+// loadTestConfig writes the content to the watched config file and loads it as the current config
+func loadTestConfig(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	setTestConfig(t, &c, nil)
+
+	previousPath := configPath
+	configPath = path
+	t.Cleanup(func() { configPath = previousPath })
+}
+
+func TestReloadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		reloaded bool
+	}{
+		{name: "Dry run", content: testReloadConfig + "dry_run: true\n", reloaded: true},
+		{name: "Namespaced workloads", content: testReloadConfig + "workload_identity:\n  namespaced: true\n"},
+		{name: "Workload labels", content: testReloadConfig + "workload_identity:\n  labels: [app, container]\n"},
+		{name: "Metrics endpoint", content: "cluster: cluster-001\nmetrics:\n  mimir_endpoint: http://other:8080\n  mimir_tenant: tenant\nbudget:\n  org: org\n  env: prod\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadTestConfig(t, testReloadConfig)
+			previous := cfg
+
+			if err := os.WriteFile(configPath, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			reloadConfig()
+
+			if reloaded := cfg != previous; reloaded != tt.reloaded {
+				t.Fatalf("expected the config to be reloaded: %v, got %v", tt.reloaded, reloaded)
+			}
+			if !tt.reloaded && cfg.WorkloadIdentity.Namespaced {
+				t.Errorf("expected workloads to stay without namespaces")
+			}
+		})
+	}
+}
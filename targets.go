@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"configurator/config"
+	"configurator/internal/kubernetes"
+	"configurator/internal/metrics"
+	"configurator/internal/models"
+	"configurator/internal/promtail"
+)
+
+var (
+	// targetClients caches the Kubernetes clients of the targets by kubeconfig and context
+	targetClients      = make(map[string]*kubernetes.K8sClient)
+	targetClientsMutex sync.Mutex
+)
+
+// targetClient returns the Kubernetes client for the cluster of a target.
+// Targets without their own kubeconfig or context share the configurator's client.
+func targetClient(t config.Target) (*kubernetes.K8sClient, error) {
+	if t.KubeContext == "" && t.KubeConfig == cfg.KubeConfig && k8sClient != nil {
+		return k8sClient, nil
+	}
+
+	targetClientsMutex.Lock()
+	defer targetClientsMutex.Unlock()
+
+	key := t.KubeConfig + "|" + t.KubeContext
+	if client, ok := targetClients[key]; ok {
+		return client, nil
+	}
+
+	var client *kubernetes.K8sClient
+	var err error
+	if t.KubeContext == "" {
+		client, err = kubernetes.New(t.KubeConfig)
+	} else {
+		client, err = kubernetes.NewForContext(t.KubeConfig, t.KubeContext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client for target %s: %w", t.Name, err)
+	}
+
+	targetClients[key] = client
+	log.Debug().
+		Str("target", t.Name).
+		Str("kube_context", t.KubeContext).
+		Msg("Kubernetes client for target initialized successfully")
+	return client, nil
+}
+
+// forEachTarget runs fn for every target and records the outcome of each in the run and the
+// metrics. A failing target does not stop the others, the errors of all targets are returned.
+func forEachTarget(run *models.EnforcementRun, targets []config.Target, fn func(t config.Target) error) error {
+	var errs []error
+	for _, t := range targets {
+		result := models.TargetResult{Target: t.Name, Cluster: t.Cluster, Success: true}
+
+		if err := fn(t); err != nil {
+			log.Error().Err(err).
+				Str("task", run.Task).
+				Str("target", t.Name).
+				Msg("Task failed for target")
+			result.Success = false
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("target %s: %w", t.Name, err))
+		}
+
+		run.Targets = append(run.Targets, result)
+		metrics.RecordTargetExecution(run.Task, t.Name, result.Success)
+	}
+	return errors.Join(errs...)
+}
+
+// selectTargets returns the configured target with the given name, or all targets if name is empty
+func selectTargets(name string) ([]config.Target, error) {
+	if name == "" {
+		return cfg.Targets, nil
+	}
+	for _, t := range cfg.Targets {
+		if t.Name == name {
+			return []config.Target{t}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown target %s", name)
+}
+
+// targetSelector returns the sampling selector format and workload labels of a target
+func targetSelector(t config.Target) promtail.Selector {
+	return promtail.Selector{Format: t.SelectorFormat, Labels: t.WorkloadLabels, Namespaced: cfg.WorkloadIdentity.Namespaced}
+}
+
+// targetMetrics returns the metrics client identifying the workloads of a target by its workload labels
+func targetMetrics(t config.Target) metrics.MetricsQuerier {
+	return metricsClient.WithWorkloadLabels(t.WorkloadLabels)
+}